@@ -1,12 +1,25 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	_ "expvar"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/adapter/breach"
+	"github.com/mar-cial/space-auth/internal/adapter/cli"
 	"github.com/mar-cial/space-auth/internal/adapter/handler"
+	memoryRepo "github.com/mar-cial/space-auth/internal/adapter/repository/memory"
 	redisRepo "github.com/mar-cial/space-auth/internal/adapter/repository/redis"
+	"github.com/mar-cial/space-auth/internal/adapter/sms"
+	"github.com/mar-cial/space-auth/internal/core/port"
 	"github.com/mar-cial/space-auth/internal/core/service"
 	"github.com/redis/go-redis/v9"
 )
@@ -21,9 +34,122 @@ func main() {
 
 	redisClient := redis.NewClient(options)
 
+	keyManager := service.NewKeyManager(keyStoreFromEnv(redisClient), service.KeyRotationPolicy{
+		RotationPeriod: durationFromEnv("KEY_ROTATION_PERIOD", service.DefaultKeyRotationPolicy().RotationPeriod),
+		PrePublish:     durationFromEnv("KEY_PREPUBLISH_PERIOD", service.DefaultKeyRotationPolicy().PrePublish),
+		RetiredGrace:   durationFromEnv("KEY_RETIRED_GRACE", service.DefaultKeyRotationPolicy().RetiredGrace),
+	})
+
+	issuer := envOrDefault("TOKEN_ISSUER", "http://localhost:8080")
+
+	phones, err := service.NewPhoneNormalizer(envOrDefault("PHONE_DEFAULT_REGION", service.DefaultPhoneRegion))
+	if err != nil {
+		log.Fatalf("Invalid PHONE_DEFAULT_REGION: %v", err)
+	}
+
+	loginMode, err := service.ParseLoginMode(envOrDefault("LOGIN_MODE", string(service.LoginModePassword)))
+	if err != nil {
+		log.Fatalf("Invalid LOGIN_MODE: %v", err)
+	}
+
+	argon2Params := service.Argon2Params{
+		Memory:      uint32(intFromEnv("ARGON2_MEMORY_KIB", int(service.DefaultArgon2Params().Memory))),
+		Iterations:  uint32(intFromEnv("ARGON2_ITERATIONS", int(service.DefaultArgon2Params().Iterations))),
+		Parallelism: uint8(intFromEnv("ARGON2_PARALLELISM", int(service.DefaultArgon2Params().Parallelism))),
+		SaltLength:  uint32(intFromEnv("ARGON2_SALT_LENGTH", int(service.DefaultArgon2Params().SaltLength))),
+		KeyLength:   uint32(intFromEnv("ARGON2_KEY_LENGTH", int(service.DefaultArgon2Params().KeyLength))),
+	}
+	if err := argon2Params.Validate(); err != nil {
+		log.Fatalf("Invalid Argon2 parameters: %v", err)
+	}
+
+	smsSender := smsSenderFromEnv()
+	if smsSender == nil && loginMode != service.LoginModePassword {
+		log.Fatalf("LOGIN_MODE=%s texts codes to users: set SMS_SENDER", loginMode)
+	}
+
+	sessionPolicy := service.SessionPolicy{
+		IdleTimeout:     durationFromEnv("SESSION_IDLE_TIMEOUT", service.DefaultSessionPolicy().IdleTimeout),
+		MaxLifetime:     durationFromEnv("SESSION_MAX_LIFETIME", service.DefaultSessionPolicy().MaxLifetime),
+		RefreshLifetime: durationFromEnv("SESSION_REFRESH_LIFETIME", service.DefaultSessionPolicy().RefreshLifetime),
+	}
+	if err := sessionPolicy.Validate(); err != nil {
+		log.Fatalf("Invalid session policy: %v", err)
+	}
+
 	authRepo := redisRepo.NewRedisAuthRepository(redisClient)
-	authService := service.NewAuthService(authRepo)
-	authHandler := handler.NewAuthHandler(authService)
+	serviceOptions := []service.Option{
+		service.WithSessionPolicy(sessionPolicy),
+		service.WithTokenIssuer(service.NewTokenIssuer(
+			issuer,
+			keyManager,
+			durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		)),
+		service.WithPhoneNormalizer(phones),
+		service.WithSMSSender(smsSender),
+		service.WithOneTimeCodePolicy(service.OneTimeCodePolicy{
+			Length:         service.DefaultOneTimeCodePolicy().Length,
+			TTL:            durationFromEnv("OTP_CODE_TTL", service.DefaultOneTimeCodePolicy().TTL),
+			MaxAttempts:    intFromEnv("OTP_MAX_ATTEMPTS", service.DefaultOneTimeCodePolicy().MaxAttempts),
+			MaxSends:       intFromEnv("OTP_MAX_SENDS_PER_HOUR", service.DefaultOneTimeCodePolicy().MaxSends),
+			SendWindow:     time.Hour,
+			ResendInterval: durationFromEnv("OTP_RESEND_INTERVAL", service.DefaultOneTimeCodePolicy().ResendInterval),
+		}),
+		service.WithLoginMode(loginMode),
+		service.WithPasswordPolicy(service.PasswordPolicy{
+			MinLength:          intFromEnv("PASSWORD_MIN_LENGTH", service.DefaultPasswordPolicy().MinLength),
+			MaxLength:          intFromEnv("PASSWORD_MAX_LENGTH", service.DefaultPasswordPolicy().MaxLength),
+			MinClasses:         intFromEnv("PASSWORD_MIN_CLASSES", service.DefaultPasswordPolicy().MinClasses),
+			MinScore:           intFromEnv("PASSWORD_MIN_SCORE", service.DefaultPasswordPolicy().MinScore),
+			RejectPhoneNumbers: service.DefaultPasswordPolicy().RejectPhoneNumbers,
+		}),
+		service.WithArgon2Params(argon2Params),
+		service.WithHashingPool(service.HashingPoolPolicy{
+			Concurrency: intFromEnv("HASHING_CONCURRENCY", service.DefaultHashingPoolPolicy().Concurrency),
+			QueueDepth:  intFromEnv("HASHING_QUEUE_DEPTH", service.DefaultHashingPoolPolicy().QueueDepth),
+		}),
+		service.WithLockoutPolicy(service.LockoutPolicy{
+			FreeAttempts:    intFromEnv("LOCKOUT_FREE_ATTEMPTS", service.DefaultLockoutPolicy().FreeAttempts),
+			BaseDelay:       durationFromEnv("LOCKOUT_BASE_DELAY", service.DefaultLockoutPolicy().BaseDelay),
+			MaxDelay:        durationFromEnv("LOCKOUT_MAX_DELAY", service.DefaultLockoutPolicy().MaxDelay),
+			Threshold:       intFromEnv("LOCKOUT_THRESHOLD", service.DefaultLockoutPolicy().Threshold),
+			LockoutDuration: durationFromEnv("LOCKOUT_DURATION", service.DefaultLockoutPolicy().LockoutDuration),
+			Window:          durationFromEnv("LOCKOUT_WINDOW", service.DefaultLockoutPolicy().Window),
+		}),
+	}
+	if smsSender != nil && boolFromEnv("LOCKOUT_NOTIFY_SMS", true) {
+		serviceOptions = append(serviceOptions, service.WithSecurityNotifiers(sms.NewSecurityNotifier(smsSender)))
+	}
+	if path := os.Getenv("PASSWORD_PEPPER_FILE"); path != "" {
+		serviceOptions = append(serviceOptions, service.WithPeppers(peppersFromFile(path)))
+	}
+	if corpus := os.Getenv("BREACHED_PASSWORDS"); corpus != "" {
+		checker, err := breach.Open(corpus)
+		if err != nil {
+			log.Fatalf("Invalid BREACHED_PASSWORDS: %v", err)
+		}
+		serviceOptions = append(serviceOptions, service.WithBreachedPasswordChecker(checker))
+	}
+	authService := service.NewAuthService(authRepo, serviceOptions...)
+
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), os.Args[1:], keyManager, authService); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	authHandler := handler.NewAuthHandler(authService,
+		handler.WithLoginURL(os.Getenv("LOGIN_URL")),
+		handler.WithIssuer(issuer),
+	)
+
+	janitorInterval := durationFromEnv("SESSION_JANITOR_INTERVAL", 10*time.Minute)
+	if janitorInterval <= 0 {
+		log.Fatalf("Invalid SESSION_JANITOR_INTERVAL %s: must be positive", janitorInterval)
+	}
+	go service.RunSessionJanitor(context.Background(), authService, janitorInterval)
+	go service.RunKeyRotation(context.Background(), keyManager, time.Hour)
 
 	router := gin.Default()
 
@@ -32,8 +158,186 @@ func main() {
 	router.POST("/register", authHandler.Register)
 	router.POST("/login", authHandler.Login)
 	router.POST("/logout", authHandler.Logout)
+	router.POST("/token/refresh", authHandler.RefreshToken)
+	router.POST("/password/forgot", authHandler.ForgotPassword)
+	router.POST("/password/reset", authHandler.ResetPassword)
+	router.GET("/auth/verify", authHandler.Verify)
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	router.GET("/.well-known/openid-configuration", authHandler.Discovery)
+	router.GET("/authorize", authHandler.Authorize)
+	router.POST("/token", authHandler.Token)
+	router.POST("/oauth/token", authHandler.Token)
+	router.POST("/oauth/introspect", authHandler.Introspect)
+	router.POST("/oauth/revoke", authHandler.Revoke)
+	router.GET("/userinfo", authHandler.UserInfo)
+	router.POST("/userinfo", authHandler.UserInfo)
+
+	authenticated := router.Group("/", handler.RequireSession(authService))
+	authenticated.POST("/logout/all", authHandler.LogoutAll)
+	authenticated.GET("/sessions", authHandler.ListSessions)
+	authenticated.DELETE("/sessions/:id", authHandler.RevokeSession)
+	authenticated.POST("/password/change", authHandler.ChangePassword)
+	authenticated.POST("/verify/phone/start", authHandler.StartPhoneVerification)
+	authenticated.POST("/verify/phone/confirm", authHandler.ConfirmPhoneVerification)
+
+	// Metrics are served apart from the API so they are not exposed with it
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go func() {
+			// expvar serves /debug/vars on the default mux
+			if err := http.ListenAndServe(addr, http.DefaultServeMux); err != nil {
+				log.Fatalf("Failed to start metrics server: %v", err)
+			}
+		}()
+	}
 
 	if err := router.Run(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// durationFromEnv parses a duration such as "30m" from the environment,
+// falling back when the variable is unset.
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+
+	return duration
+}
+
+// intFromEnv parses an integer from the environment, falling back when the
+// variable is unset.
+func intFromEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+
+	return n
+}
+
+// boolFromEnv parses a boolean such as "true" or "0" from the environment,
+// falling back when the variable is unset.
+func boolFromEnv(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+
+	return b
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// keyStoreFromEnv picks where signing keys live: Redis by default, or process
+// memory with KEY_STORE=memory. Keys in Redis are encrypted with the key in
+// KEY_ENCRYPTION_KEY_FILE, without which the service refuses to start, and
+// stay available from memory while Redis is down.
+func keyStoreFromEnv(client *redis.Client) port.KeyStore {
+	switch store := envOrDefault("KEY_STORE", "redis"); store {
+	case "redis":
+		path := os.Getenv("KEY_ENCRYPTION_KEY_FILE")
+		if path == "" {
+			log.Fatal("KEY_ENCRYPTION_KEY_FILE is required with KEY_STORE=redis")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Invalid KEY_ENCRYPTION_KEY_FILE: %v", err)
+		}
+		encryptionKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			log.Fatalf("Invalid KEY_ENCRYPTION_KEY_FILE %s: %v", path, err)
+		}
+
+		keyStore, err := redisRepo.NewRedisKeyStore(client, encryptionKey)
+		if err != nil {
+			log.Fatalf("Invalid KEY_ENCRYPTION_KEY_FILE %s: %v", path, err)
+		}
+		return memoryRepo.NewFallbackKeyStore(keyStore)
+	case "memory":
+		return memoryRepo.NewMemoryKeyStore()
+	default:
+		log.Fatalf("Invalid KEY_STORE %q: want redis or memory", store)
+		return nil
+	}
+}
+
+// smsSenderFromEnv picks how texts are delivered. There is no default:
+// without SMS_SENDER nothing is texted and one-time codes are unavailable.
+// Only development senders exist so far, the log or a JSON Lines file with
+// SMS_SENDER=file, and both show codes to whoever can read them.
+func smsSenderFromEnv() port.SMSSender {
+	switch sender := os.Getenv("SMS_SENDER"); sender {
+	case "":
+		return nil
+	case "log":
+		log.Println("SMS_SENDER=log writes one-time codes to the log; use it for development only")
+		return sms.NewLogSender()
+	case "file":
+		log.Println("SMS_SENDER=file writes one-time codes to a file; use it for development only")
+		return sms.NewFileSender(envOrDefault("SMS_FILE", "sms.jsonl"))
+	default:
+		log.Fatalf("Invalid SMS_SENDER %q: want log or file", sender)
+		return nil
+	}
+}
+
+// peppersFromFile reads the password peppers, refusing to start without
+// them: hashes made with a pepper cannot be verified otherwise.
+func peppersFromFile(path string) []service.Pepper {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Invalid PASSWORD_PEPPER_FILE: %v", err)
+	}
+
+	peppers, err := service.ParsePeppers(data)
+	if err != nil {
+		log.Fatalf("Invalid PASSWORD_PEPPER_FILE %s: %v", path, err)
+	}
+	if len(peppers) == 0 {
+		log.Fatalf("Invalid PASSWORD_PEPPER_FILE %s: no peppers", path)
+	}
+	return peppers
+}
+
+// runCommand runs an admin subcommand instead of the server.
+func runCommand(ctx context.Context, args []string, keys port.KeyService, auth port.AuthService) error {
+	switch args[0] {
+	case "keys":
+		return cli.Keys(ctx, keys, args[1:], os.Stdout)
+	case "clients":
+		return cli.Clients(ctx, auth, args[1:], os.Stdout)
+	case "migrate-phones":
+		return cli.MigratePhones(ctx, auth, args[1:], os.Stdout)
+	case "import-users":
+		return cli.ImportUsers(ctx, auth, args[1:], os.Stdout)
+	case "breached-passwords":
+		return cli.BreachedPasswords(ctx, args[1:], os.Stdin, os.Stdout)
+	case "lockout":
+		return cli.Lockout(ctx, auth, args[1:], os.Stdout)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
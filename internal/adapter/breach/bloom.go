@@ -0,0 +1,167 @@
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// bloomMagic starts every Bloom filter file, followed by the number of bits
+// and of hash functions as big-endian uint64 and uint32, then the bits.
+const bloomMagic = "SABLOOM1"
+
+// bloomHeaderSize is the length of the magic and the fields after it.
+const bloomHeaderSize = len(bloomMagic) + 12
+
+// maxBloomHashes bounds the hash functions a filter file may ask for; even
+// a one in a billion false positive rate needs only 30.
+const maxBloomHashes = 64
+
+// bloomFilter answers whether a password may be breached from a fraction of
+// the corpus size, at the cost of a small rate of false positives. Weak but
+// unbreached passwords are sometimes rejected; breached ones never pass.
+type bloomFilter struct {
+	bits   []byte
+	size   uint64
+	hashes uint32
+}
+
+func newBloomFilter(items uint64, falsePositiveRate float64) *bloomFilter {
+	if items == 0 {
+		items = 1
+	}
+	size := uint64(math.Ceil(-float64(items) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	size = (size + 7) / 8 * 8
+	hashes := uint32(math.Max(1, math.Round(float64(size)/float64(items)*math.Ln2)))
+
+	return &bloomFilter{bits: make([]byte, size/8), size: size, hashes: hashes}
+}
+
+// positions derives the filter bits of a SHA-1 by double hashing. SHA-1 is
+// already uniform, so its first 16 bytes serve as the two hashes.
+func (b *bloomFilter) positions(sum [sha1.Size]byte, fn func(bit uint64) bool) bool {
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	for i := uint64(0); i < uint64(b.hashes); i++ {
+		if !fn((h1 + i*h2) % b.size) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomFilter) add(sum [sha1.Size]byte) {
+	b.positions(sum, func(bit uint64) bool {
+		b.bits[bit/8] |= 1 << (bit % 8)
+		return true
+	})
+}
+
+func (b *bloomFilter) contains(sum [sha1.Size]byte) bool {
+	return b.positions(sum, func(bit uint64) bool {
+		return b.bits[bit/8]&(1<<(bit%8)) != 0
+	})
+}
+
+func (b *bloomFilter) IsBreached(ctx context.Context, password string) (bool, error) {
+	return b.contains(sha1.Sum([]byte(password))), nil
+}
+
+func (b *bloomFilter) writeTo(w io.Writer) error {
+	header := make([]byte, 0, bloomHeaderSize)
+	header = append(header, bloomMagic...)
+	header = binary.BigEndian.AppendUint64(header, b.size)
+	header = binary.BigEndian.AppendUint32(header, b.hashes)
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(b.bits)
+	return err
+}
+
+func loadBloomFilter(path string) (*bloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, bloomHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("invalid Bloom filter %s: %w", path, err)
+	}
+
+	filter := &bloomFilter{
+		size:   binary.BigEndian.Uint64(header[len(bloomMagic):]),
+		hashes: binary.BigEndian.Uint32(header[len(bloomMagic)+8:]),
+	}
+	if filter.size == 0 || filter.size%8 != 0 || filter.hashes == 0 || filter.hashes > maxBloomHashes {
+		return nil, fmt.Errorf("invalid Bloom filter %s: bad header", path)
+	}
+
+	// Trust the header with an allocation only once the file backs it up
+	if filter.size/8 != uint64(info.Size()-int64(bloomHeaderSize)) {
+		return nil, fmt.Errorf("invalid Bloom filter %s: header says %d bytes of bits, file has %d", path, filter.size/8, info.Size()-int64(bloomHeaderSize))
+	}
+
+	filter.bits = make([]byte, filter.size/8)
+	if _, err := io.ReadFull(reader, filter.bits); err != nil {
+		return nil, fmt.Errorf("invalid Bloom filter %s: %w", path, err)
+	}
+	return filter, nil
+}
+
+// BloomStats describes a Bloom filter built by BuildBloomFilter.
+type BloomStats struct {
+	Hashes    uint64
+	SizeBytes uint64
+	Functions uint32
+}
+
+// BuildBloomFilter writes to output a Bloom filter of every breached hash in
+// the corpus at source, a range directory or a hash file, sized for the
+// given false positive rate. The corpus is read twice: once to count hashes,
+// once to add them.
+func BuildBloomFilter(source string, output io.Writer, falsePositiveRate float64) (*BloomStats, error) {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, errors.New("false positive rate must be between 0 and 1")
+	}
+
+	var count uint64
+	if err := eachHash(source, func(string) error {
+		count++
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	filter := newBloomFilter(count, falsePositiveRate)
+	if err := eachHash(source, func(hash string) error {
+		sum, ok := decodeHash(hash)
+		if !ok {
+			return fmt.Errorf("invalid SHA-1 %q in %s", hash, source)
+		}
+		filter.add(sum)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := filter.writeTo(output); err != nil {
+		return nil, err
+	}
+	return &BloomStats{Hashes: count, SizeBytes: uint64(len(filter.bits)), Functions: filter.hashes}, nil
+}
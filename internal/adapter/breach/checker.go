@@ -0,0 +1,73 @@
+// Package breach checks passwords against breach corpora kept on disk, such
+// as the Have I Been Pwned "Pwned Passwords" SHA-1 list, without network
+// access.
+package breach
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// prefixLength is how many leading hex characters of a SHA-1 name its range
+// file, as in the k-anonymity range API.
+const prefixLength = 5
+
+// Open returns a checker for the corpus at path, which is one of:
+//   - a directory of range files named by the first five hex characters of
+//     the SHA-1 ("21BD1" or "21BD1.txt"), each line "SUFFIX:COUNT";
+//   - a file of "HASH:COUNT" lines sorted by hash, as downloaded in one piece;
+//   - a Bloom filter built from either by BuildBloomFilter.
+func Open(path string) (port.BreachedPasswordChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &rangeDirectory{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(bloomMagic))
+	if _, err := io.ReadFull(file, magic); err == nil && bytes.Equal(magic, []byte(bloomMagic)) {
+		return loadBloomFilter(path)
+	}
+	return &hashFile{path: path}, nil
+}
+
+// passwordHash returns the upper case hex SHA-1 of password, the form used by
+// breach corpora.
+func passwordHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return fmt.Sprintf("%X", sum[:])
+}
+
+// parseLine splits a corpus line into its hash, or hash suffix, and whether
+// it counts as breached. Range files are padded with zero-count lines.
+func parseLine(line []byte) (string, bool) {
+	hash, count, found := bytes.Cut(bytes.TrimSpace(line), []byte(":"))
+	breached := len(hash) > 0 && (!found || len(bytes.Trim(count, "0")) > 0)
+	return string(bytes.ToUpper(hash)), breached
+}
+
+// decodeHash turns a 40 character hex SHA-1 into its bytes.
+func decodeHash(hash string) ([sha1.Size]byte, bool) {
+	var sum [sha1.Size]byte
+	if len(hash) != 2*sha1.Size {
+		return sum, false
+	}
+	if _, err := hex.Decode(sum[:], []byte(hash)); err != nil {
+		return sum, false
+	}
+	return sum, true
+}
@@ -0,0 +1,127 @@
+package breach
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var breachedPasswords = []string{"password", "123456", "letmein", "correct horse battery staple"}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// corpora writes the breached passwords as a range directory and as a
+// sorted hash file, padded like the real downloads.
+func corpora(t *testing.T) (string, string) {
+	dir := t.TempDir()
+
+	rangeDir := filepath.Join(dir, "ranges")
+	if err := os.Mkdir(rangeDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for i, password := range breachedPasswords {
+		hash := passwordHash(password)
+		lines = append(lines, hash+":42")
+		name := hash[:prefixLength]
+		if i%2 == 0 {
+			name += ".txt"
+		}
+		writeFile(t, filepath.Join(rangeDir, name), "0000000000000000000000000000000000A:0\r\n"+hash[prefixLength:]+":42\r\n")
+	}
+	for i := 0; i < 2000; i++ {
+		lines = append(lines, passwordHash("filler"+strings.Repeat("x", i))+":1")
+	}
+	// A hash with a zero count is padding, not a breach
+	lines = append(lines, passwordHash("padding")+":0")
+	sort.Strings(lines)
+
+	hashFile := filepath.Join(dir, "hashes.txt")
+	writeFile(t, hashFile, strings.Join(lines, "\n")+"\n")
+
+	return rangeDir, hashFile
+}
+
+func TestCheckers(t *testing.T) {
+	ctx := context.Background()
+	rangeDir, hashFile := corpora(t)
+
+	bloomFile := filepath.Join(t.TempDir(), "breached.bloom")
+	var filter bytes.Buffer
+	if _, err := BuildBloomFilter(hashFile, &filter, 0.0001); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, bloomFile, filter.String())
+
+	for name, path := range map[string]string{"range directory": rangeDir, "hash file": hashFile, "bloom filter": bloomFile} {
+		t.Run(name, func(t *testing.T) {
+			checker, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			breached := append([]string{}, breachedPasswords...)
+			if name != "range directory" {
+				breached = append(breached, "filler", "fillerxxxxxxxxxx")
+			}
+			for _, password := range breached {
+				if breached, err := checker.IsBreached(ctx, password); err != nil || !breached {
+					t.Errorf("IsBreached(%q) = %v, %v, want true", password, breached, err)
+				}
+			}
+			for _, password := range []string{"tangerine-orbit-falcon", "padding", ""} {
+				if breached, err := checker.IsBreached(ctx, password); err != nil || breached {
+					t.Errorf("IsBreached(%q) = %v, %v, want false", password, breached, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBloomFilterChecksHeader(t *testing.T) {
+	_, hashFile := corpora(t)
+	var filter bytes.Buffer
+	if _, err := BuildBloomFilter(hashFile, &filter, 0.0001); err != nil {
+		t.Fatal(err)
+	}
+	valid := filter.Bytes()
+
+	withSize := func(size uint64) []byte {
+		data := bytes.Clone(valid)
+		binary.BigEndian.PutUint64(data[len(bloomMagic):], size)
+		return data
+	}
+	withHashes := func(hashes uint32) []byte {
+		data := bytes.Clone(valid)
+		binary.BigEndian.PutUint32(data[len(bloomMagic)+8:], hashes)
+		return data
+	}
+
+	for name, data := range map[string][]byte{
+		"truncated bits":   valid[:len(valid)-1],
+		"trailing data":    append(bytes.Clone(valid), 0),
+		"huge size":        withSize(1 << 62),
+		"size off by one":  withSize(uint64(len(valid)-bloomHeaderSize+1) * 8),
+		"no hashes":        withHashes(0),
+		"too many hashes":  withHashes(1 << 30),
+		"truncated header": valid[:bloomHeaderSize-1],
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "breached.bloom")
+			writeFile(t, path, string(data))
+			if _, err := Open(path); err == nil {
+				t.Fatal("expected the filter to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package breach
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rangeDirectory holds one file per SHA-1 prefix, so a lookup reads a single
+// small file.
+type rangeDirectory struct {
+	dir string
+}
+
+func (r *rangeDirectory) IsBreached(ctx context.Context, password string) (bool, error) {
+	hash := passwordHash(password)
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	file, err := r.openRange(prefix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if candidate, ok := parseLine(scanner.Bytes()); ok && candidate == suffix {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+func (r *rangeDirectory) openRange(prefix string) (*os.File, error) {
+	file, err := os.Open(filepath.Join(r.dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = os.Open(filepath.Join(r.dir, prefix+".txt"))
+	}
+	return file, err
+}
+
+// hashFile is a single file of full hashes sorted in ascending order, looked
+// up by binary search so it never has to fit in memory.
+type hashFile struct {
+	path string
+}
+
+// linearScanWindow is how close binary search narrows the lookup before the
+// remaining lines are read one by one.
+const linearScanWindow = 4096
+
+func (h *hashFile) IsBreached(ctx context.Context, password string) (bool, error) {
+	hash := passwordHash(password)
+
+	file, err := os.Open(h.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// lo is always the start of a line no later than the hash's, if present
+	lo, hi := int64(0), info.Size()
+	for hi-lo > linearScanWindow {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineAfter(file, mid)
+		if err != nil {
+			return false, err
+		}
+		if candidate, _ := parseLine(line); line != nil && candidate < hash {
+			lo = start + int64(len(line))
+		} else {
+			hi = mid
+		}
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(file, lo, info.Size()-lo))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			candidate, breached := parseLine(line)
+			if candidate == hash {
+				return breached, nil
+			}
+			if candidate > hash {
+				return false, nil
+			}
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// lineAfter returns the first line starting at or after offset, with its
+// newline, and where it starts. line is nil past the last line.
+func lineAfter(file *os.File, offset int64) (int64, []byte, error) {
+	start := offset
+	if offset > 0 {
+		// The line starting at offset is the one after the newline at or
+		// after offset-1
+		start = offset - 1
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(file, start, 1<<62))
+	if offset > 0 {
+		skipped, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return 0, nil, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		start += int64(len(skipped))
+	}
+
+	line, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return 0, nil, err
+	}
+	if len(line) == 0 {
+		return 0, nil, nil
+	}
+	return start, line, nil
+}
+
+// eachHash calls fn with every breached hash of the corpus at path, a range
+// directory or a hash file.
+func eachHash(path string, fn func(hash string) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return eachLine(path, "", fn)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		prefix := strings.ToUpper(strings.TrimSuffix(entry.Name(), ".txt"))
+		if entry.IsDir() || len(prefix) != prefixLength {
+			continue
+		}
+		if err := eachLine(filepath.Join(path, entry.Name()), prefix, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func eachLine(path string, prefix string, fn func(hash string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		candidate, ok := parseLine(scanner.Bytes())
+		if !ok {
+			continue
+		}
+		if err := fn(prefix + candidate); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mar-cial/space-auth/internal/adapter/breach"
+)
+
+const breachedPasswordsUsage = `usage:
+  breached-passwords build-bloom --source <path> --output <file> [--false-positive-rate <rate>]
+                      build a compact Bloom filter from a range directory or hash file
+  breached-passwords check --corpus <path>
+                      read passwords from stdin, one per line, and report breached ones`
+
+// BreachedPasswords runs the "breached-passwords" admin command, which
+// prepares and tries out breached password corpora.
+func BreachedPasswords(ctx context.Context, args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(breachedPasswordsUsage)
+	}
+
+	switch args[0] {
+	case "build-bloom":
+		flags := flag.NewFlagSet("breached-passwords build-bloom", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		source := flags.String("source", "", "")
+		output := flags.String("output", "", "")
+		rate := flags.Float64("false-positive-rate", 0.001, "")
+		if err := flags.Parse(args[1:]); err != nil || *source == "" || *output == "" || flags.NArg() > 0 {
+			return errors.New(breachedPasswordsUsage)
+		}
+		return buildBloomFilter(*source, *output, *rate, out)
+
+	case "check":
+		flags := flag.NewFlagSet("breached-passwords check", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		corpus := flags.String("corpus", "", "")
+		if err := flags.Parse(args[1:]); err != nil || *corpus == "" || flags.NArg() > 0 {
+			return errors.New(breachedPasswordsUsage)
+		}
+
+		checker, err := breach.Open(*corpus)
+		if err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(in)
+		for line := 1; scanner.Scan(); line++ {
+			breached, err := checker.IsBreached(ctx, scanner.Text())
+			if err != nil {
+				return err
+			}
+			verdict := "not found"
+			if breached {
+				verdict = "breached"
+			}
+			fmt.Fprintf(out, "line %d: %s\n", line, verdict)
+		}
+		return scanner.Err()
+
+	default:
+		return errors.New(breachedPasswordsUsage)
+	}
+}
+
+// buildBloomFilter writes the filter next to output first, so a running
+// server never sees a half-written file.
+func buildBloomFilter(source string, output string, rate float64, out io.Writer) error {
+	tmp, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	stats, err := breach.BuildBloomFilter(source, writer, rate)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %s: %d hashes, %d bytes, %d hash functions, %g false positive rate\n",
+		output, stats.Hashes, stats.SizeBytes, stats.Functions, rate)
+	return nil
+}
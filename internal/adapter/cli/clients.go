@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+const clientsUsage = `usage:
+  clients create --name <name> --redirect-uri <uri> [--redirect-uri <uri>...]
+                      register an OpenID Connect client
+  clients create --name <name> --grant-type client_credentials --scope <scope> [--scope <scope>...]
+                      register a backend job and print its secret
+  clients list        show every registered client
+  clients delete <id> remove a client`
+
+// stringList is a flag that may be repeated.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Clients runs the "clients" admin command against the client registry.
+func Clients(ctx context.Context, clients port.ClientService, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(clientsUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("clients create", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		name := flags.String("name", "", "")
+		var redirectURIs, grantTypes, scopes stringList
+		flags.Var(&redirectURIs, "redirect-uri", "")
+		flags.Var(&grantTypes, "grant-type", "")
+		flags.Var(&scopes, "scope", "")
+		if err := flags.Parse(args[1:]); err != nil {
+			return errors.New(clientsUsage)
+		}
+
+		client, secret, err := clients.RegisterClient(ctx, domain.ClientRegistration{
+			Name:         *name,
+			RedirectURIs: redirectURIs,
+			GrantTypes:   grantTypes,
+			Scopes:       scopes,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Registered client %s\n", client.ID)
+		if secret != "" {
+			fmt.Fprintf(out, "Client secret: %s\nIt is not stored and cannot be shown again.\n", secret)
+		}
+		return nil
+
+	case "list":
+		list, err := clients.ListClients(ctx)
+		if err != nil {
+			return err
+		}
+		return printClients(out, list)
+
+	case "delete":
+		if len(args) != 2 {
+			return errors.New(clientsUsage)
+		}
+		if err := clients.DeleteClient(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Deleted client %s\n", args[1])
+		return nil
+
+	default:
+		return errors.New(clientsUsage)
+	}
+}
+
+func printClients(out io.Writer, clients []domain.Client) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tCREATED\tGRANT TYPES\tSCOPES\tREDIRECT URIS")
+	for _, client := range clients {
+		grantTypes := client.GrantTypes
+		if len(grantTypes) == 0 {
+			grantTypes = []string{domain.GrantAuthorizationCode}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			client.ID,
+			client.Name,
+			client.CreatedAt.Format(time.RFC3339),
+			strings.Join(grantTypes, ","),
+			strings.Join(client.Scopes, " "),
+			strings.Join(client.RedirectURIs, " "),
+		)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+const keysUsage = `usage:
+  keys list           show every signing key and its lifecycle
+  keys rotate         retire the active key now and start a new one
+  keys revoke <kid>   withdraw a compromised key from signing and the JWKS`
+
+// Keys runs the "keys" admin command against the signing key store.
+func Keys(ctx context.Context, keys port.KeyService, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(keysUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		list, err := keys.ListKeys(ctx)
+		if err != nil {
+			return err
+		}
+		return printKeys(out, list)
+
+	case "rotate":
+		key, err := keys.ForceRotate(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Signing with new key %s\n", key.ID)
+		return nil
+
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New(keysUsage)
+		}
+		if err := keys.Revoke(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Revoked key %s\n", args[1])
+		return nil
+
+	default:
+		return errors.New(keysUsage)
+	}
+}
+
+func printKeys(out io.Writer, keys []domain.SigningKey) error {
+	now := time.Now()
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tSTATUS\tACTIVATES\tRETIRES\tEXPIRES")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			key.ID,
+			keyStatus(key, now),
+			key.ActivatesAt.Format(time.RFC3339),
+			key.RetiresAt.Format(time.RFC3339),
+			key.ExpiresAt.Format(time.RFC3339),
+		)
+	}
+	return w.Flush()
+}
+
+func keyStatus(key domain.SigningKey, now time.Time) string {
+	switch {
+	case key.RevokedAt != nil:
+		return "revoked"
+	case key.ActivatesAt.After(now):
+		return "pending"
+	case key.RetiresAt.After(now):
+		return "active"
+	default:
+		return "retired"
+	}
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+const lockoutUsage = `usage:
+  lockout status <phonenumber>  show failed sign ins and any lockout
+  lockout unlock <phonenumber>  forget failed sign ins, lifting any lockout`
+
+// Lockout runs the "lockout" admin command, which inspects and lifts
+// lockouts after failed sign ins.
+func Lockout(ctx context.Context, lockout port.LockoutService, args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return errors.New(lockoutUsage)
+	}
+
+	switch args[0] {
+	case "status":
+		status, err := lockout.LockoutStatus(ctx, args[1])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "%s: %d failed sign ins\n", status.Phonenumber, status.Failures)
+		if status.Failures > 0 {
+			fmt.Fprintf(out, "Last failure: %s\n", status.LastFailureAt.Format(time.RFC3339))
+		}
+		switch {
+		case status.Locked:
+			fmt.Fprintf(out, "Locked out until %s\n", status.RetryAt.Format(time.RFC3339))
+		case !status.RetryAt.IsZero():
+			fmt.Fprintf(out, "Next attempt allowed at %s\n", status.RetryAt.Format(time.RFC3339))
+		}
+		return nil
+
+	case "unlock":
+		cleared, err := lockout.UnlockAccount(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if !cleared {
+			fmt.Fprintf(out, "No failed sign ins with %s\n", args[1])
+			return nil
+		}
+		fmt.Fprintf(out, "Unlocked %s\n", args[1])
+		return nil
+
+	default:
+		return errors.New(lockoutUsage)
+	}
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+const migratePhonesUsage = `usage:
+  migrate-phones [--dry-run]   rewrite stored phone numbers to E.164`
+
+// MigratePhones runs the "migrate-phones" admin command, which rewrites
+// stored phone numbers to E.164 and reports those it could not.
+func MigratePhones(ctx context.Context, phones port.PhoneMigrationService, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("migrate-phones", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return errors.New(migratePhonesUsage)
+	}
+
+	report, err := phones.MigratePhoneNumbers(ctx, *dryRun)
+	if report != nil {
+		verb := "Rewrote"
+		if *dryRun {
+			verb = "Would rewrite"
+		}
+		for _, change := range report.Rewritten {
+			fmt.Fprintf(out, "%s %s: %s -> %s\n", verb, change.UserID, change.From, change.To)
+		}
+		for _, invalid := range report.Invalid {
+			fmt.Fprintf(out, "Invalid %s: %q cannot be normalized\n", invalid.UserID, invalid.From)
+		}
+		for _, collision := range report.Collisions {
+			fmt.Fprintf(out, "Collision %s: %s\n", collision.Phonenumber, strings.Join(collision.UserIDs, ", "))
+		}
+		fmt.Fprintf(out, "%d users scanned: %d rewritten, %d unchanged, %d invalid, %d in collisions\n",
+			report.Scanned, len(report.Rewritten), report.Unchanged, len(report.Invalid), collidingUsers(report.Collisions))
+	}
+	return err
+}
+
+func collidingUsers(collisions []domain.PhoneCollision) int {
+	count := 0
+	for _, collision := range collisions {
+		count += len(collision.UserIDs)
+	}
+	return count
+}
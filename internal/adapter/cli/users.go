@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+const importUsersUsage = `usage:
+  import-users [--dry-run] [--format csv|jsonl] <file>
+                      create accounts from another system, keeping their password hashes
+
+CSV files need a header row with phonenumber and password_hash columns, and
+may have roles (space separated) and phone_verified (true or false). JSON
+Lines files have one object per line with the same fields, roles as an array.`
+
+// ImportUsers runs the "import-users" admin command, which creates accounts
+// exported from other systems.
+func ImportUsers(ctx context.Context, users port.UserImportService, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import-users", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "")
+	format := flags.String("format", "", "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errors.New(importUsersUsage)
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var imported []domain.ImportedUser
+	var lines []int
+	switch *format {
+	case "csv":
+		imported, lines, err = readUsersCSV(file)
+	case "jsonl", "ndjson":
+		imported, lines, err = readUsersJSONL(file)
+	default:
+		return errors.New(importUsersUsage)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	report, err := users.ImportUsers(ctx, imported, *dryRun)
+	if report != nil {
+		verb := "Imported"
+		if *dryRun {
+			verb = "Would import"
+		}
+		for _, account := range report.Imported {
+			fmt.Fprintf(out, "%s line %d: %s as %s\n", verb, lines[account.Index], account.Phonenumber, account.UserID)
+		}
+		for _, existing := range report.Existing {
+			fmt.Fprintf(out, "Skipped line %d: %s %s\n", lines[existing.Index], existing.Phonenumber, existing.Reason)
+		}
+		for _, rejected := range report.Rejected {
+			fmt.Fprintf(out, "Rejected line %d: %q: %s\n", lines[rejected.Index], rejected.Phonenumber, rejected.Reason)
+		}
+		fmt.Fprintf(out, "%d users read: %d imported, %d already registered, %d rejected\n",
+			len(imported), len(report.Imported), len(report.Existing), len(report.Rejected))
+	}
+	return err
+}
+
+// readUsersCSV reads users and the line each starts on.
+func readUsersCSV(r io.Reader) ([]domain.ImportedUser, []int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"phonenumber", "password_hash"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	var users []domain.ImportedUser
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return users, lines, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		user := domain.ImportedUser{
+			Phonenumber:  field("phonenumber"),
+			PasswordHash: field("password_hash"),
+			Roles:        strings.Fields(field("roles")),
+		}
+		if verified := field("phone_verified"); verified != "" {
+			user.PhoneVerified, err = strconv.ParseBool(verified)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: invalid phone_verified %q", line, verified)
+			}
+		}
+
+		users = append(users, user)
+		lines = append(lines, line)
+	}
+}
+
+// readUsersJSONL reads users and the line each is on.
+func readUsersJSONL(r io.Reader) ([]domain.ImportedUser, []int, error) {
+	var users []domain.ImportedUser
+	var lines []int
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var user domain.ImportedUser
+		if err := json.Unmarshal(scanner.Bytes(), &user); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		users = append(users, user)
+		lines = append(lines, line)
+	}
+	return users, lines, scanner.Err()
+}
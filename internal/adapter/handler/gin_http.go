@@ -1,11 +1,12 @@
 package handler
 
 import (
-	"bytes"
 	"errors"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +18,29 @@ var ErrInternalServer = errors.New("Internal server error")
 
 type authHandler struct {
 	authService port.AuthService
+	loginURL    string
+	issuer      string
+}
+
+// Option customises the handler built by NewAuthHandler.
+type Option func(*authHandler)
+
+// WithLoginURL makes /auth/verify redirect browsers without a session to the
+// given login page instead of answering 401, behind proxies that pass the
+// redirect on. nginx auth_request does not and keeps getting 401, which it
+// has to turn into a redirect with error_page itself.
+func WithLoginURL(loginURL string) Option {
+	return func(a *authHandler) {
+		a.loginURL = loginURL
+	}
+}
+
+// WithIssuer sets the public base URL of the service, used in OpenID Connect
+// discovery and to send users back to /authorize after they sign in.
+func WithIssuer(issuer string) Option {
+	return func(a *authHandler) {
+		a.issuer = strings.TrimSuffix(issuer, "/")
+	}
 }
 
 func (a *authHandler) Register(c *gin.Context) {
@@ -31,12 +55,28 @@ func (a *authHandler) Register(c *gin.Context) {
 
 	user, err := a.authService.CreateUser(ctx, creds)
 	if err != nil {
+		if errors.Is(err, port.ErrInvalidPhoneNumber) {
+			c.HTML(http.StatusBadRequest, "error.html", gin.H{"error": "Invalid phone number"})
+			return
+		}
+		if errors.Is(err, port.ErrHashingBusy) {
+			c.Header("Retry-After", hashingBusyRetryAfter)
+			c.HTML(http.StatusServiceUnavailable, "error.html", gin.H{"error": "Server busy, try again shortly"})
+			return
+		}
+		if violations, ok := passwordViolations(err); ok {
+			c.HTML(http.StatusBadRequest, "error.html", gin.H{
+				"error":  "Password does not meet the requirements",
+				"fields": map[string][]domain.PasswordViolation{"password": violations},
+			})
+			return
+		}
 		log.Println(err)
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": ErrInternalServer})
 		return
 	}
 
-	session, err := a.authService.CreateSession(ctx, user.ID)
+	session, err := a.authService.CreateSession(ctx, user.ID, deviceFromRequest(c))
 	if err != nil {
 		log.Println(err)
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": ErrInternalServer})
@@ -45,44 +85,69 @@ func (a *authHandler) Register(c *gin.Context) {
 
 	// dev only. I know this is bad.
 	// Store the session token as a cookie
-	c.SetCookie(
-		"session_id",
-		session.Token,
-		int(session.ExpiresAt.Sub(time.Now()).Seconds()),
-		"/",
-		"localhost",
-		false,
-		true,
-	)
+	setSessionCookie(c, session)
 
 	c.HTML(http.StatusOK, "user_registered.html", gin.H{"user": user})
 }
 
 func (a *authHandler) Login(c *gin.Context) {
-	// Debug: Read raw request body
-	body, _ := io.ReadAll(c.Request.Body)
-	log.Println("Raw Request Body:", string(body))
-
-	// Reset the request body so Gin can bind it
-	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
-
 	var creds domain.Credentials
-	if err := c.ShouldBindJSON(&creds); err != nil {
+	err := c.ShouldBindJSON(&creds)
+	if err != nil {
 		log.Println("JSON Unmarshal Error:", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
 
-	// Validate user credentials
-	valid, err := a.authService.ValidateUser(c.Request.Context(), creds)
-	if err != nil || !valid {
-		log.Println("Invalid login attempt:", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	var user *domain.User
+	switch {
+	case creds.Code != "":
+		user, err = a.authService.ValidateOTPLogin(c.Request.Context(), creds.Phonenumber, creds.Code)
+		if err != nil {
+			if errors.Is(err, port.ErrLoginMethodDisabled) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Code login is disabled"})
+				return
+			}
+			if errors.Is(err, port.ErrInvalidCode) || errors.Is(err, port.ErrTooManyAttempts) {
+				log.Println("Invalid login attempt:", err)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+				return
+			}
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+			return
+		}
+
+	case creds.Password == "":
+		a.startOTPLogin(c, creds.Phonenumber)
 		return
+
+	default:
+		// Validate user credentials
+		valid, err := a.authService.ValidateUser(c.Request.Context(), creds)
+		if errors.Is(err, port.ErrLoginMethodDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Password login is disabled"})
+			return
+		}
+		if hashingBusy(c, err) || accountLocked(c, err) {
+			return
+		}
+		if err != nil || !valid {
+			log.Println("Invalid login attempt:", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+
+		user, err = a.authService.ReadUserByPhone(c.Request.Context(), creds.Phonenumber)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to sign in"})
+			return
+		}
 	}
 
 	// Create session after successful validation
-	session, err := a.authService.CreateSession(c.Request.Context(), creds.Phonenumber)
+	session, err := a.authService.CreateSession(c.Request.Context(), user.ID, deviceFromRequest(c))
 	if err != nil {
 		log.Println(err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to sign in"})
@@ -90,9 +155,35 @@ func (a *authHandler) Login(c *gin.Context) {
 	}
 
 	// Set session cookie
-	c.SetCookie("session_id", session.Token, int(session.ExpiresAt.Sub(time.Now()).Seconds()), "/", "localhost", false, true)
+	setSessionCookie(c, session)
+
+	response := tokenResponse(session)
+	response["message"] = "Welcome!"
+	c.JSON(http.StatusOK, response)
+}
+
+// startOTPLogin texts a login code. The answer is the same whether or not
+// the number is registered.
+func (a *authHandler) startOTPLogin(c *gin.Context, phonenumber string) {
+	expiresAt, err := a.authService.StartOTPLogin(c.Request.Context(), phonenumber)
+	if err != nil {
+		switch {
+		case errors.Is(err, port.ErrLoginMethodDisabled):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		case errors.Is(err, port.ErrInvalidPhoneNumber):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		case rateLimited(c, err):
+		default:
+			log.Println("Error starting code login:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		}
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Welcome!"})
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "If the number is registered, a login code is on its way",
+		"expires_in": int(time.Until(expiresAt).Seconds()),
+	})
 }
 
 func (a *authHandler) Logout(c *gin.Context) {
@@ -125,6 +216,259 @@ func (a *authHandler) Logout(c *gin.Context) {
 	c.HTML(http.StatusOK, "logout_success.html", gin.H{"message": "Logged out successfully"})
 }
 
-func NewAuthHandler(srv port.AuthService) port.AuthHandler {
-	return &authHandler{authService: srv}
+type sessionResponse struct {
+	ID        string        `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	Device    domain.Device `json:"device"`
+	Current   bool          `json:"current"`
+}
+
+// ListSessions shows every device the current user is signed in on.
+func (a *authHandler) ListSessions(c *gin.Context) {
+	current, ok := CurrentSession(c)
+	if !ok {
+		abortUnauthorized(c, port.ErrSessionNotFound)
+		return
+	}
+
+	sessions, err := a.authService.ListSessions(c.Request.Context(), current.UserID)
+	if err != nil {
+		log.Println("Error listing sessions:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		return
+	}
+
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{
+			ID:        session.ID,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Device:    session.Device,
+			Current:   session.ID == current.ID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+// LogoutAll signs the current user out of every session. With
+// ?keep_current=true the session making the request survives.
+func (a *authHandler) LogoutAll(c *gin.Context) {
+	current, ok := CurrentSession(c)
+	if !ok {
+		abortUnauthorized(c, port.ErrSessionNotFound)
+		return
+	}
+
+	exceptToken := ""
+	keepCurrent := c.Query("keep_current") == "true"
+	if keepCurrent {
+		exceptToken = current.Token
+	}
+
+	revoked, err := a.authService.RevokeAllSessions(c.Request.Context(), current.UserID, exceptToken)
+	if err != nil {
+		log.Println("Error revoking sessions:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	if !keepCurrent {
+		clearSessionCookie(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out everywhere", "revoked": revoked})
+}
+
+// RevokeSession signs the current user out of the session named in the path.
+func (a *authHandler) RevokeSession(c *gin.Context) {
+	current, ok := CurrentSession(c)
+	if !ok {
+		abortUnauthorized(c, port.ErrSessionNotFound)
+		return
+	}
+
+	sessionID := c.Param("id")
+	if err := a.authService.RevokeSessionByID(c.Request.Context(), current.UserID, sessionID); err != nil {
+		if errors.Is(err, port.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		log.Println("Error revoking session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	if sessionID == current.ID {
+		clearSessionCookie(c)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token" binding:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new access session and a new
+// refresh token. Each refresh token works exactly once.
+func (a *authHandler) RefreshToken(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	session, err := a.authService.RefreshSession(c.Request.Context(), req.RefreshToken, deviceFromRequest(c))
+	if err != nil {
+		if errors.Is(err, port.ErrRefreshTokenNotFound) || errors.Is(err, port.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		log.Println("Error refreshing session:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(session))
+}
+
+// Verify is a forward-auth endpoint for reverse proxies (nginx auth_request,
+// Traefik forwardAuth, Caddy forward_auth). It answers 200 with the identity
+// of the caller in X-Auth-* headers, or 401 when there is no live session.
+// Browsers are redirected to the login page instead when one is configured
+// and the proxy passes redirects on.
+func (a *authHandler) Verify(c *gin.Context) {
+	session, user, err := authenticate(c, a.authService, a.authService)
+	if err != nil {
+		if !signedOut(err) {
+			log.Println("Error verifying session:", err)
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		a.denyVerify(c)
+		return
+	}
+
+	c.Header("X-Auth-User-Id", user.ID)
+	c.Header("X-Auth-Phone", user.Phonenumber)
+	c.Header("X-Auth-Session-Id", session.ID)
+	c.Header("X-Auth-Phone-Verified", strconv.FormatBool(user.PhoneVerifiedAt != nil))
+	c.Status(http.StatusOK)
+}
+
+// denyVerify answers a failed forward-auth check. Browsers are redirected to
+// the login page only behind proxies that pass redirects on to the client.
+func (a *authHandler) denyVerify(c *gin.Context) {
+	if a.loginURL != "" && strings.Contains(c.GetHeader("Accept"), "text/html") && forwardsRedirects(c) {
+		c.Redirect(http.StatusFound, a.loginRedirect(c))
+		return
+	}
+
+	c.Header("WWW-Authenticate", `Bearer realm="space-auth"`)
+	c.Status(http.StatusUnauthorized)
+}
+
+// forwardsRedirects reports whether the check comes from a proxy that hands
+// a redirect to the client. Traefik forwardAuth and Caddy forward_auth do,
+// and describe the original request in X-Forwarded-Method and
+// X-Forwarded-Uri. nginx auth_request sends neither by default and fails the
+// request on anything but 2xx, 401 and 403.
+func forwardsRedirects(c *gin.Context) bool {
+	return c.GetHeader("X-Forwarded-Method") != "" && c.GetHeader("X-Forwarded-Uri") != ""
+}
+
+// loginRedirect builds the login URL with the page the proxy was asked for,
+// as reported in the X-Forwarded-* headers, in "rd".
+func (a *authHandler) loginRedirect(c *gin.Context) string {
+	original := ""
+	if host := c.GetHeader("X-Forwarded-Host"); host != "" {
+		proto := c.GetHeader("X-Forwarded-Proto")
+		if proto == "" {
+			proto = "https"
+		}
+		original = proto + "://" + host + c.GetHeader("X-Forwarded-Uri")
+	}
+
+	return a.loginURLFor(original)
+}
+
+// loginURLFor returns the login URL with original, the page to return to
+// after signing in, in "rd".
+func (a *authHandler) loginURLFor(original string) string {
+	if original == "" {
+		return a.loginURL
+	}
+
+	target, err := url.Parse(a.loginURL)
+	if err != nil {
+		return a.loginURL
+	}
+	query := target.Query()
+	query.Set("rd", original)
+	target.RawQuery = query.Encode()
+
+	return target.String()
+}
+
+// JWKS publishes the public keys access token JWTs are signed with.
+func (a *authHandler) JWKS(c *gin.Context) {
+	keys, err := a.authService.PublicKeys(c.Request.Context())
+	if err != nil {
+		log.Println("Error reading public keys:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keys)
+}
+
+// tokenResponse describes a freshly issued session to API clients.
+func tokenResponse(session *domain.Session) gin.H {
+	response := gin.H{
+		"access_token":  session.Token,
+		"token_type":    "Bearer",
+		"expires_in":    int(time.Until(session.ExpiresAt).Seconds()),
+		"refresh_token": session.RefreshToken,
+	}
+	if session.AccessToken != "" {
+		response["access_jwt"] = session.AccessToken
+	}
+	return response
+}
+
+// setSessionCookie stores the session token in a cookie that lives exactly as
+// long as the session.
+func setSessionCookie(c *gin.Context, session *domain.Session) {
+	c.SetCookie(
+		"session_id",
+		session.Token,
+		int(time.Until(session.ExpiresAt).Seconds()),
+		"/",
+		"localhost",
+		false,
+		true,
+	)
+}
+
+func clearSessionCookie(c *gin.Context) {
+	c.SetCookie("session_id", "", -1, "/", "localhost", false, true)
+}
+
+func deviceFromRequest(c *gin.Context) domain.Device {
+	return domain.Device{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
+func NewAuthHandler(srv port.AuthService, opts ...Option) port.AuthHandler {
+	a := &authHandler{authService: srv}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
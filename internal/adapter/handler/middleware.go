@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// Keys under which RequireSession stores the authenticated session and user
+// in the gin.Context.
+const (
+	SessionContextKey = "auth.session"
+	UserContextKey    = "auth.user"
+)
+
+// RequireSession authenticates requests from the session_id cookie or an
+// "Authorization: Bearer" header. The session, and the user when sessions
+// can also look users up, are stored in the context for later handlers; see
+// CurrentSession and CurrentUser. Requests without a live session are
+// aborted with a 401 JSON error.
+func RequireSession(sessions port.SessionService) gin.HandlerFunc {
+	users, _ := sessions.(port.UserService)
+
+	return func(c *gin.Context) {
+		session, user, err := authenticate(c, sessions, users)
+		if err != nil {
+			if signedOut(err) {
+				abortUnauthorized(c, err)
+				return
+			}
+			log.Println("Error authenticating request:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+			return
+		}
+
+		c.Set(SessionContextKey, session)
+		if user != nil {
+			c.Set(UserContextKey, user)
+		}
+
+		c.Next()
+	}
+}
+
+// authenticate reads the session presented with the request and, when users
+// is set, the user it belongs to, without their password hash. A session
+// read from the cookie is sent back, as reading it may have slid its expiry
+// forward. Errors for which signedOut holds mean there is no live session;
+// others are failures to find out.
+func authenticate(c *gin.Context, sessions port.SessionService, users port.UserService) (*domain.Session, *domain.User, error) {
+	ctx := c.Request.Context()
+
+	token, fromCookie := sessionToken(c)
+	if token == "" {
+		return nil, nil, port.ErrSessionNotFound
+	}
+
+	session, err := sessions.ReadSession(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if fromCookie {
+		setSessionCookie(c, session)
+	}
+
+	if users == nil {
+		return session, nil, nil
+	}
+
+	user, err := users.ReadUserById(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, port.ErrUserNotFound) {
+			return nil, nil, port.ErrSessionNotFound
+		}
+		return nil, nil, err
+	}
+	user.Password = ""
+
+	return session, user, nil
+}
+
+// signedOut reports whether an error from authenticate means the request
+// has no live session.
+func signedOut(err error) bool {
+	return errors.Is(err, port.ErrSessionNotFound) || errors.Is(err, port.ErrSessionExpired)
+}
+
+// CurrentSession returns the session stored by RequireSession.
+func CurrentSession(c *gin.Context) (*domain.Session, bool) {
+	value, ok := c.Get(SessionContextKey)
+	if !ok {
+		return nil, false
+	}
+	session, ok := value.(*domain.Session)
+	return session, ok
+}
+
+// CurrentUser returns the user stored by RequireSession.
+func CurrentUser(c *gin.Context) (*domain.User, bool) {
+	value, ok := c.Get(UserContextKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := value.(*domain.User)
+	return user, ok
+}
+
+// sessionToken reads the session token from the Authorization header, falling
+// back to the session cookie. fromCookie reports where it was found.
+func sessionToken(c *gin.Context) (token string, fromCookie bool) {
+	if token := bearerToken(c); token != "" {
+		return token, false
+	}
+
+	cookie, err := c.Cookie("session_id")
+	if err != nil {
+		return "", false
+	}
+	return cookie, true
+}
+
+func abortUnauthorized(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Bearer realm="space-auth"`)
+
+	if errors.Is(err, port.ErrSessionExpired) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Session expired",
+			"code":  "session_expired",
+		})
+		return
+	}
+
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"error": "Session not found",
+		"code":  "session_not_found",
+	})
+}
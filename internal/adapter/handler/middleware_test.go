@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// stubSessions serves a fixed set of sessions; everything else is unused.
+type stubSessions struct {
+	port.SessionService
+	sessions map[string]*domain.Session
+	expired  map[string]bool
+}
+
+func (s *stubSessions) ReadSession(ctx context.Context, token string) (*domain.Session, error) {
+	if s.expired[token] {
+		return nil, port.ErrSessionExpired
+	}
+	session, ok := s.sessions[token]
+	if !ok {
+		return nil, port.ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func TestRequireSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sessions := &stubSessions{
+		sessions: map[string]*domain.Session{
+			"valid": {ID: "session-1", Token: "valid", UserID: "user-1", ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		expired: map[string]bool{"stale": true},
+	}
+
+	router := gin.New()
+	router.GET("/protected", RequireSession(sessions), func(c *gin.Context) {
+		session, _ := CurrentSession(c)
+		c.String(http.StatusOK, session.UserID)
+	})
+
+	tests := []struct {
+		name     string
+		setup    func(r *http.Request)
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "bearer token",
+			setup:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer valid") },
+			wantCode: http.StatusOK,
+			wantBody: "user-1",
+		},
+		{
+			name:     "session cookie",
+			setup:    func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session_id", Value: "valid"}) },
+			wantCode: http.StatusOK,
+			wantBody: "user-1",
+		},
+		{
+			name:     "missing credentials",
+			setup:    func(r *http.Request) {},
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":"session_not_found","error":"Session not found"}`,
+		},
+		{
+			name:     "expired session",
+			setup:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer stale") },
+			wantCode: http.StatusUnauthorized,
+			wantBody: `{"code":"session_expired","error":"Session expired"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			tt.setup(req)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Fatalf("expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// Discovery serves the OpenID Provider metadata (OpenID Connect Discovery
+// section 3).
+func (a *authHandler) Discovery(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                a.issuer,
+		"authorization_endpoint":                a.issuer + "/authorize",
+		"token_endpoint":                        a.issuer + "/token",
+		"userinfo_endpoint":                     a.issuer + "/userinfo",
+		"jwks_uri":                              a.issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "client_credentials"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"EdDSA"},
+		"scopes_supported":                      []string{"openid", "phone"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "sid", "phone_number"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"none", "client_secret_basic", "client_secret_post"},
+		"introspection_endpoint":                a.issuer + "/oauth/introspect",
+		"revocation_endpoint":                   a.issuer + "/oauth/revoke",
+	})
+}
+
+// Authorize is the OAuth 2.0 authorization endpoint. Users signed in through
+// the usual session cookie are sent straight back to the client with a code;
+// everybody else goes through the login page first.
+func (a *authHandler) Authorize(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req domain.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{"error": "Invalid authorization request"})
+		return
+	}
+
+	// Until the client and redirect URI check out, errors are shown to the
+	// user rather than sent to a URI we know nothing about
+	if _, err := a.authService.ValidateAuthorizationRequest(ctx, req); err != nil {
+		if errors.Is(err, port.ErrClientNotFound) || errors.Is(err, port.ErrInvalidRedirectURI) {
+			c.HTML(http.StatusBadRequest, "error.html", gin.H{"error": "Unknown client or redirect URI"})
+			return
+		}
+		log.Println("Error validating authorization request:", err)
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": ErrInternalServer})
+		return
+	}
+
+	session := a.authorizingSession(c)
+
+	code, err := a.authService.Authorize(ctx, req, session)
+	if err != nil {
+		var oauthErr *port.OAuthError
+		if !errors.As(err, &oauthErr) {
+			log.Println("Error authorizing client:", err)
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": ErrInternalServer})
+			return
+		}
+
+		if oauthErr.Code == port.OAuthLoginRequired && req.Prompt != "none" {
+			if a.loginURL == "" {
+				c.HTML(http.StatusUnauthorized, "error.html", gin.H{"error": "Sign in to continue"})
+				return
+			}
+			c.Redirect(http.StatusFound, a.loginURLFor(a.issuer+c.Request.URL.RequestURI()))
+			return
+		}
+
+		c.Redirect(http.StatusFound, redirectWith(req.RedirectURI, url.Values{
+			"error":             {oauthErr.Code},
+			"error_description": {oauthErr.Description},
+			"state":             {req.State},
+		}))
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirectWith(req.RedirectURI, url.Values{
+		"code":  {code.Code},
+		"state": {req.State},
+	}))
+}
+
+// authorizingSession returns the signed-in session of the browser, or nil.
+func (a *authHandler) authorizingSession(c *gin.Context) *domain.Session {
+	token, err := c.Cookie("session_id")
+	if err != nil || token == "" {
+		return nil
+	}
+
+	session, err := a.authService.ReadSession(c.Request.Context(), token)
+	if err != nil {
+		if !errors.Is(err, port.ErrSessionNotFound) && !errors.Is(err, port.ErrSessionExpired) {
+			log.Println("Error reading session:", err)
+		}
+		return nil
+	}
+
+	setSessionCookie(c, session)
+	return session
+}
+
+// Token is the OAuth 2.0 token endpoint. Confidential clients authenticate
+// with HTTP Basic or client_id and client_secret form fields.
+func (a *authHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req domain.TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthErrorResponse(c, &port.OAuthError{Code: port.OAuthInvalidRequest, Description: "malformed request"})
+		return
+	}
+
+	if clientID, clientSecret, ok := basicClientCredentials(c); ok {
+		req.ClientID = clientID
+		req.ClientSecret = clientSecret
+	}
+
+	response, err := a.authService.ExchangeToken(c.Request.Context(), req)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UserInfo returns the claims about the user an access token was issued for.
+func (a *authHandler) UserInfo(c *gin.Context) {
+	token := bearerToken(c)
+	if token == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="space-auth"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	info, err := a.authService.UserInfo(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, port.ErrInvalidToken) || errors.Is(err, port.ErrTokenRevoked) {
+			c.Header("WWW-Authenticate", `Bearer realm="space-auth", error="invalid_token"`)
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		log.Println("Error reading user info:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// Introspect is the RFC 7662 token introspection endpoint.
+func (a *authHandler) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	var req domain.IntrospectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthErrorResponse(c, &port.OAuthError{Code: port.OAuthInvalidRequest, Description: "malformed request"})
+		return
+	}
+
+	if clientID, clientSecret, ok := basicClientCredentials(c); ok {
+		req.ClientID = clientID
+		req.ClientSecret = clientSecret
+	}
+
+	introspection, err := a.authService.IntrospectToken(c.Request.Context(), req)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, introspection)
+}
+
+// Revoke is the RFC 7009 token revocation endpoint.
+func (a *authHandler) Revoke(c *gin.Context) {
+	var req domain.RevocationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthErrorResponse(c, &port.OAuthError{Code: port.OAuthInvalidRequest, Description: "malformed request"})
+		return
+	}
+
+	if clientID, clientSecret, ok := basicClientCredentials(c); ok {
+		req.ClientID = clientID
+		req.ClientSecret = clientSecret
+	}
+
+	if err := a.authService.RevokeToken(c.Request.Context(), req); err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// oauthErrorResponse renders err as an RFC 6749 section 5.2 error response.
+func oauthErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, port.ErrHashingBusy) {
+		c.Header("Retry-After", hashingBusyRetryAfter)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily_unavailable"})
+		return
+	}
+
+	var oauthErr *port.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Println("Error serving token request:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == port.OAuthInvalidClient {
+		c.Header("WWW-Authenticate", `Basic realm="space-auth"`)
+		status = http.StatusUnauthorized
+	}
+
+	c.JSON(status, gin.H{
+		"error":             oauthErr.Code,
+		"error_description": oauthErr.Description,
+	})
+}
+
+// redirectWith adds params to the query of a redirect URI, leaving out empty
+// values.
+func redirectWith(redirectURI string, params url.Values) string {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	target.RawQuery = query.Encode()
+
+	return target.String()
+}
+
+// basicClientCredentials reads client credentials sent with HTTP Basic,
+// which RFC 6749 section 2.3.1 has form-encoded first.
+func basicClientCredentials(c *gin.Context) (string, string, bool) {
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		return "", "", false
+	}
+
+	clientID, err := url.QueryUnescape(username)
+	if err != nil {
+		return "", "", false
+	}
+	clientSecret, err := url.QueryUnescape(password)
+	if err != nil {
+		return "", "", false
+	}
+
+	return clientID, clientSecret, true
+}
+
+// bearerToken reads the token from an "Authorization: Bearer" header.
+func bearerToken(c *gin.Context) string {
+	scheme, credentials, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(credentials)
+}
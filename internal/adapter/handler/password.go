@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+type forgotPasswordRequest struct {
+	Phonenumber string `json:"phonenumber" form:"phonenumber" binding:"required"`
+}
+
+type resetPasswordRequest struct {
+	Phonenumber string `json:"phonenumber" form:"phonenumber" binding:"required"`
+	Code        string `json:"code" form:"code" binding:"required"`
+	Password    string `json:"password" form:"password" binding:"required"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" form:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" form:"new_password" binding:"required"`
+	SignOutOthers   bool   `json:"sign_out_others" form:"sign_out_others"`
+}
+
+// ForgotPassword texts a password reset code. The answer is the same whether
+// or not the number is registered.
+func (a *authHandler) ForgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	expiresAt, err := a.authService.StartPasswordReset(c.Request.Context(), req.Phonenumber)
+	if err != nil {
+		switch {
+		case errors.Is(err, port.ErrInvalidPhoneNumber):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		case rateLimited(c, err):
+		default:
+			log.Println("Error starting password reset:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "If the number is registered, a reset code is on its way",
+		"expires_in": int(time.Until(expiresAt).Seconds()),
+	})
+}
+
+// ResetPassword sets a new password with the code texted by ForgotPassword
+// and signs the user out everywhere.
+func (a *authHandler) ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := a.authService.ResetPassword(c.Request.Context(), req.Phonenumber, req.Code, req.Password); err != nil {
+		if weakPassword(c, err, "password") || codeRejected(c, err) || hashingBusy(c, err) {
+			return
+		}
+		log.Println("Error resetting password:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		return
+	}
+
+	clearSessionCookie(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated, sign in with your new password"})
+}
+
+// ChangePassword sets a new password for the current user, who has to know
+// the current one. With sign_out_others every other session ends.
+func (a *authHandler) ChangePassword(c *gin.Context) {
+	current, ok := CurrentSession(c)
+	if !ok {
+		abortUnauthorized(c, port.ErrSessionNotFound)
+		return
+	}
+
+	var req changePasswordRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	revoked, err := a.authService.ChangePassword(c.Request.Context(), domain.PasswordChange{
+		UserID:          current.UserID,
+		CurrentPassword: req.CurrentPassword,
+		NewPassword:     req.NewPassword,
+		SignOutOthers:   req.SignOutOthers,
+		KeepToken:       current.Token,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, port.ErrInvalidCredentials):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+		case weakPassword(c, err, "new_password"):
+		case accountLocked(c, err):
+		case hashingBusy(c, err):
+		default:
+			log.Println("Error changing password:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed", "revoked": revoked})
+}
+
+// passwordViolations returns why the password policy rejected a password.
+func passwordViolations(err error) ([]domain.PasswordViolation, bool) {
+	var policyErr *port.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return policyErr.Violations, true
+	}
+	if errors.Is(err, port.ErrWeakPassword) {
+		return []domain.PasswordViolation{{Code: "weak_password", Message: err.Error()}}, true
+	}
+	return nil, false
+}
+
+// weakPassword answers 400 with the policy violations of the password sent
+// in field.
+func weakPassword(c *gin.Context, err error, field string) bool {
+	violations, ok := passwordViolations(err)
+	if !ok {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":  "Password does not meet the requirements",
+		"code":   "weak_password",
+		"fields": gin.H{field: violations},
+	})
+	return true
+}
+
+// hashingBusyRetryAfter is how long clients are asked to wait when password
+// hashing is saturated. Hashes take well under a second, so the queue drains
+// quickly.
+const hashingBusyRetryAfter = "1"
+
+// hashingBusy answers 503 with Retry-After when too many passwords are being
+// hashed to take another one.
+func hashingBusy(c *gin.Context, err error) bool {
+	if !errors.Is(err, port.ErrHashingBusy) {
+		return false
+	}
+
+	c.Header("Retry-After", hashingBusyRetryAfter)
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server busy, try again shortly"})
+	return true
+}
+
+// accountLocked answers 429 with Retry-After when earlier failed sign ins
+// hold back another attempt.
+func accountLocked(c *gin.Context, err error) bool {
+	var lockedErr *port.AccountLockedError
+	if !errors.As(err, &lockedErr) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(lockedErr.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": "Too many failed sign in attempts, try again later",
+		"code":  "account_locked",
+	})
+	return true
+}
@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// sessionsAuth serves sessions from a map keyed by token, and the users
+// RequireSession looks up. Methods the session endpoints do not use panic
+// through the nil port.AuthService.
+type sessionsAuth struct {
+	port.AuthService
+	sessions map[string]*domain.Session
+	users    map[string]*domain.User
+}
+
+func (s *sessionsAuth) ReadSession(ctx context.Context, token string) (*domain.Session, error) {
+	session, ok := s.sessions[token]
+	if !ok {
+		return nil, port.ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (s *sessionsAuth) ReadUserById(ctx context.Context, id string) (*domain.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, port.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *sessionsAuth) RevokeAllSessions(ctx context.Context, userid string, exceptToken string) (int, error) {
+	revoked := 0
+	for token, session := range s.sessions {
+		if session.UserID == userid && token != exceptToken {
+			delete(s.sessions, token)
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (s *sessionsAuth) RevokeSessionByID(ctx context.Context, userid string, sessionid string) error {
+	for token, session := range s.sessions {
+		if session.UserID == userid && session.ID == sessionid {
+			delete(s.sessions, token)
+			return nil
+		}
+	}
+	return port.ErrSessionNotFound
+}
+
+func (s *sessionsAuth) ListSessions(ctx context.Context, userid string) ([]domain.Session, error) {
+	var sessions []domain.Session
+	for _, session := range s.sessions {
+		if session.UserID == userid {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+// newSessionsRouter serves the session endpoints to the holders of the
+// "phone" and "laptop" sessions of user-1.
+func newSessionsRouter(auth *sessionsAuth) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	expiresAt := time.Now().Add(time.Hour)
+	auth.sessions = map[string]*domain.Session{
+		"phone":  {ID: "session-1", Token: "phone", UserID: "user-1", ExpiresAt: expiresAt, Device: domain.Device{UserAgent: "phone"}},
+		"laptop": {ID: "session-2", Token: "laptop", UserID: "user-1", ExpiresAt: expiresAt, Device: domain.Device{UserAgent: "laptop"}},
+		"other":  {ID: "session-3", Token: "other", UserID: "user-2", ExpiresAt: expiresAt},
+	}
+	auth.users = map[string]*domain.User{
+		"user-1": {ID: "user-1", Phonenumber: "+12025550123"},
+		"user-2": {ID: "user-2", Phonenumber: "+12025550124"},
+	}
+
+	handler := NewAuthHandler(auth)
+	router := gin.New()
+	authenticated := router.Group("/", RequireSession(auth))
+	authenticated.GET("/sessions", handler.ListSessions)
+	authenticated.POST("/logout/all", handler.LogoutAll)
+	authenticated.DELETE("/sessions/:id", handler.RevokeSession)
+	return router
+}
+
+func TestListSessions(t *testing.T) {
+	router := newSessionsRouter(&sessionsAuth{})
+
+	t.Run("lists the user's sessions", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/sessions", nil)
+		req.Header.Set("Authorization", "Bearer phone")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		var body struct {
+			Sessions []sessionResponse `json:"sessions"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if len(body.Sessions) != 2 {
+			t.Fatalf("expected the 2 sessions of user-1, got %+v", body.Sessions)
+		}
+		for _, session := range body.Sessions {
+			if current := session.ID == "session-1"; session.Current != current {
+				t.Errorf("session %s: current = %v, want %v", session.ID, session.Current, current)
+			}
+			if session.Device.UserAgent == "" || session.ExpiresAt.IsZero() {
+				t.Errorf("session %s: expected its device and expiry, got %+v", session.ID, session)
+			}
+		}
+	})
+
+	t.Run("requires a session", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/sessions", nil)
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+		}
+	})
+}
+
+// clearsSessionCookie reports whether the response deletes the session cookie.
+func clearsSessionCookie(rec *httptest.ResponseRecorder) bool {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "session_id" && cookie.MaxAge < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLogoutAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		wantRevoked int
+		wantKept    []string
+		wantCleared bool
+	}{
+		{
+			name:        "everywhere",
+			target:      "/logout/all",
+			wantRevoked: 2,
+			wantKept:    []string{"other"},
+			wantCleared: true,
+		},
+		{
+			name:        "keep current",
+			target:      "/logout/all?keep_current=true",
+			wantRevoked: 1,
+			wantKept:    []string{"phone", "other"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &sessionsAuth{}
+			router := newSessionsRouter(auth)
+
+			req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			req.Header.Set("Authorization", "Bearer phone")
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			var body struct {
+				Revoked int `json:"revoked"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if body.Revoked != tt.wantRevoked {
+				t.Errorf("revoked = %d, want %d", body.Revoked, tt.wantRevoked)
+			}
+			if len(auth.sessions) != len(tt.wantKept) {
+				t.Errorf("expected sessions %v kept, got %d", tt.wantKept, len(auth.sessions))
+			}
+			for _, token := range tt.wantKept {
+				if _, ok := auth.sessions[token]; !ok {
+					t.Errorf("session %q revoked", token)
+				}
+			}
+			if cleared := clearsSessionCookie(rec); cleared != tt.wantCleared {
+				t.Errorf("cookie cleared = %v, want %v", cleared, tt.wantCleared)
+			}
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionID   string
+		wantCode    int
+		wantRevoked string
+		wantCleared bool
+	}{
+		{
+			name:        "another device",
+			sessionID:   "session-2",
+			wantCode:    http.StatusNoContent,
+			wantRevoked: "laptop",
+		},
+		{
+			name:        "this device",
+			sessionID:   "session-1",
+			wantCode:    http.StatusNoContent,
+			wantRevoked: "phone",
+			wantCleared: true,
+		},
+		{
+			name:      "another user's session",
+			sessionID: "session-3",
+			wantCode:  http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &sessionsAuth{}
+			router := newSessionsRouter(auth)
+
+			req := httptest.NewRequest(http.MethodDelete, "/sessions/"+tt.sessionID, nil)
+			req.Header.Set("Authorization", "Bearer phone")
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			wantLeft := 3
+			if tt.wantRevoked != "" {
+				wantLeft = 2
+				if _, ok := auth.sessions[tt.wantRevoked]; ok {
+					t.Errorf("session %q not revoked", tt.wantRevoked)
+				}
+			}
+			if len(auth.sessions) != wantLeft {
+				t.Errorf("expected %d sessions left, got %d", wantLeft, len(auth.sessions))
+			}
+			if cleared := clearsSessionCookie(rec); cleared != tt.wantCleared {
+				t.Errorf("cookie cleared = %v, want %v", cleared, tt.wantCleared)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+type confirmCodeRequest struct {
+	Code string `json:"code" form:"code" binding:"required"`
+}
+
+// StartPhoneVerification texts the current user a code to prove they own
+// their phone number.
+func (a *authHandler) StartPhoneVerification(c *gin.Context) {
+	current, ok := CurrentSession(c)
+	if !ok {
+		abortUnauthorized(c, port.ErrSessionNotFound)
+		return
+	}
+
+	expiresAt, err := a.authService.StartPhoneVerification(c.Request.Context(), current.UserID)
+	if err != nil {
+		if errors.Is(err, port.ErrPhoneAlreadyVerified) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Phone number already verified"})
+			return
+		}
+		if rateLimited(c, err) {
+			return
+		}
+		log.Println("Error starting phone verification:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Code sent",
+		"expires_in": int(time.Until(expiresAt).Seconds()),
+	})
+}
+
+// ConfirmPhoneVerification marks the current user's phone number verified
+// with the code texted to it.
+func (a *authHandler) ConfirmPhoneVerification(c *gin.Context) {
+	current, ok := CurrentSession(c)
+	if !ok {
+		abortUnauthorized(c, port.ErrSessionNotFound)
+		return
+	}
+
+	var req confirmCodeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := a.authService.ConfirmPhoneVerification(c.Request.Context(), current.UserID, req.Code); err != nil {
+		if codeRejected(c, err) {
+			return
+		}
+		log.Println("Error confirming phone verification:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": ErrInternalServer.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Phone number verified"})
+}
+
+// rateLimited answers 429 with Retry-After when err is a rate limit.
+func rateLimited(c *gin.Context, err error) bool {
+	var limitErr *port.RateLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+
+	retryAfter := int(limitErr.RetryAfter.Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+	return true
+}
+
+// codeRejected answers a wrong, expired or exhausted one-time code.
+func codeRejected(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, port.ErrInvalidCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code", "code": "invalid_code"})
+	case errors.Is(err, port.ErrTooManyAttempts):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many attempts, request a new code", "code": "too_many_attempts"})
+	default:
+		return false
+	}
+	return true
+}
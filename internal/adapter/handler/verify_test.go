@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// stubAuth serves the sessions of stubSessions and a fixed set of users;
+// everything else is unused.
+type stubAuth struct {
+	port.AuthService
+	stubSessions
+	users map[string]*domain.User
+	err   error
+}
+
+func (s *stubAuth) ReadSession(ctx context.Context, token string) (*domain.Session, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.stubSessions.ReadSession(ctx, token)
+}
+
+func (s *stubAuth) ReadUserById(ctx context.Context, id string) (*domain.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, port.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func TestVerify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	auth := &stubAuth{
+		stubSessions: stubSessions{
+			sessions: map[string]*domain.Session{
+				"valid":  {ID: "session-1", Token: "valid", UserID: "user-1", ExpiresAt: time.Now().Add(time.Hour)},
+				"orphan": {ID: "session-2", Token: "orphan", UserID: "deleted", ExpiresAt: time.Now().Add(time.Hour)},
+			},
+		},
+		users: map[string]*domain.User{
+			"user-1": {ID: "user-1", Phonenumber: "+12025550123", Password: "argon2id$..."},
+		},
+	}
+
+	router := gin.New()
+	router.GET("/auth/verify", NewAuthHandler(auth, WithLoginURL("https://auth.example.com/login")).Verify)
+
+	traefik := func(r *http.Request) {
+		r.Header.Set("Accept", "text/html")
+		r.Header.Set("X-Forwarded-Method", "GET")
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("X-Forwarded-Host", "wiki.example.com")
+		r.Header.Set("X-Forwarded-Uri", "/page?id=1")
+	}
+
+	tests := []struct {
+		name         string
+		setup        func(r *http.Request)
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:     "bearer token",
+			setup:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer valid") },
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "no session",
+			setup:    func(r *http.Request) {},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "deleted user",
+			setup:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer orphan") },
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "browser behind traefik",
+			setup:        traefik,
+			wantCode:     http.StatusFound,
+			wantLocation: "https://auth.example.com/login?rd=https%3A%2F%2Fwiki.example.com%2Fpage%3Fid%3D1",
+		},
+		{
+			// nginx auth_request cannot pass a redirect on
+			name: "browser behind nginx",
+			setup: func(r *http.Request) {
+				r.Header.Set("Accept", "text/html")
+				r.Header.Set("X-Original-URL", "https://wiki.example.com/page")
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auth/verify", nil)
+			tt.setup(req)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if location := rec.Header().Get("Location"); location != tt.wantLocation {
+				t.Fatalf("expected location %q, got %q", tt.wantLocation, location)
+			}
+		})
+	}
+
+	t.Run("identity headers", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/auth/verify", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: "valid"})
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		for header, want := range map[string]string{
+			"X-Auth-User-Id":        "user-1",
+			"X-Auth-Phone":          "+12025550123",
+			"X-Auth-Session-Id":     "session-1",
+			"X-Auth-Phone-Verified": "false",
+		} {
+			if got := rec.Header().Get(header); got != want {
+				t.Errorf("%s = %q, want %q", header, got, want)
+			}
+		}
+	})
+
+	t.Run("session store down", func(t *testing.T) {
+		auth.err = errors.New("connection refused")
+		defer func() { auth.err = nil }()
+
+		req := httptest.NewRequest(http.MethodGet, "/auth/verify", nil)
+		req.Header.Set("Authorization", "Bearer valid")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// fallbackKeyStore fronts a shared key store with a copy of its keys in
+// process memory. When the primary cannot be read, the keys last read from
+// it are served instead, so tokens keep being signed and verified through
+// an outage. Writes and rotation locking only go to the primary and fail
+// while it is down.
+type fallbackKeyStore struct {
+	primary port.KeyStore
+
+	mu     sync.RWMutex
+	keys   []domain.SigningKey
+	loaded bool
+}
+
+func (f *fallbackKeyStore) SaveKey(ctx context.Context, key domain.SigningKey) error {
+	if err := f.primary.SaveKey(ctx, key); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.keys = slices.DeleteFunc(f.keys, func(k domain.SigningKey) bool { return k.ID == key.ID })
+	f.keys = append(f.keys, key)
+	return nil
+}
+
+func (f *fallbackKeyStore) ListKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	keys, err := f.primary.ListKeys(ctx)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		f.keys = slices.Clone(keys)
+		f.loaded = true
+		return keys, nil
+	}
+	if !f.loaded {
+		return nil, err
+	}
+
+	log.Println("Key store unavailable, using signing keys kept in memory:", err)
+	return slices.Clone(f.keys), nil
+}
+
+func (f *fallbackKeyStore) DeleteKey(ctx context.Context, kid string) error {
+	if err := f.primary.DeleteKey(ctx, kid); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.keys = slices.DeleteFunc(f.keys, func(k domain.SigningKey) bool { return k.ID == kid })
+	return nil
+}
+
+func (f *fallbackKeyStore) LockRotation(ctx context.Context, ttl time.Duration) (string, bool, error) {
+	return f.primary.LockRotation(ctx, ttl)
+}
+
+func (f *fallbackKeyStore) UnlockRotation(ctx context.Context, token string) error {
+	return f.primary.UnlockRotation(ctx, token)
+}
+
+// NewFallbackKeyStore keeps serving the keys of primary from memory while
+// it cannot be read.
+func NewFallbackKeyStore(primary port.KeyStore) port.KeyStore {
+	return &fallbackKeyStore{primary: primary}
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// memoryKeyStore keeps signing keys in process memory. Keys are lost on
+// restart, so it suits development and single-instance deployments that
+// would rather not keep private keys in Redis.
+type memoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]domain.SigningKey
+
+	lockToken   string
+	lockExpires time.Time
+}
+
+func (m *memoryKeyStore) SaveKey(ctx context.Context, key domain.SigningKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[key.ID] = key
+	return nil
+}
+
+func (m *memoryKeyStore) ListKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]domain.SigningKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *memoryKeyStore) DeleteKey(ctx context.Context, kid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.keys[kid]; !ok {
+		return port.ErrKeyNotFound
+	}
+	delete(m.keys, kid)
+	return nil
+}
+
+func (m *memoryKeyStore) LockRotation(ctx context.Context, ttl time.Duration) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lockToken != "" && time.Now().Before(m.lockExpires) {
+		return "", false, nil
+	}
+	m.lockToken = uuid.NewString()
+	m.lockExpires = time.Now().Add(ttl)
+	return m.lockToken, true, nil
+}
+
+func (m *memoryKeyStore) UnlockRotation(ctx context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lockToken == token {
+		m.lockToken = ""
+	}
+	return nil
+}
+
+func NewMemoryKeyStore() port.KeyStore {
+	return &memoryKeyStore{keys: make(map[string]domain.SigningKey)}
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// flakyKeyStore is a memory key store that fails while down is set.
+type flakyKeyStore struct {
+	port.KeyStore
+	down bool
+}
+
+var errStoreDown = errors.New("store down")
+
+func (f *flakyKeyStore) SaveKey(ctx context.Context, key domain.SigningKey) error {
+	if f.down {
+		return errStoreDown
+	}
+	return f.KeyStore.SaveKey(ctx, key)
+}
+
+func (f *flakyKeyStore) ListKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	if f.down {
+		return nil, errStoreDown
+	}
+	return f.KeyStore.ListKeys(ctx)
+}
+
+func TestMemoryKeyStoreRotationLock(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryKeyStore()
+
+	token, acquired, err := store.LockRotation(ctx, time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("expected to take the free lock, got %v, %v", acquired, err)
+	}
+	if _, acquired, _ := store.LockRotation(ctx, time.Minute); acquired {
+		t.Fatal("expected the held lock to be refused")
+	}
+
+	if err := store.UnlockRotation(ctx, "someone-else"); err != nil {
+		t.Fatalf("unlocking: %v", err)
+	}
+	if _, acquired, _ := store.LockRotation(ctx, time.Minute); acquired {
+		t.Fatal("expected the lock kept when released with another token")
+	}
+
+	if err := store.UnlockRotation(ctx, token); err != nil {
+		t.Fatalf("unlocking: %v", err)
+	}
+	if _, acquired, _ := store.LockRotation(ctx, -time.Second); !acquired {
+		t.Fatal("expected the lock free once released")
+	}
+	if _, acquired, _ := store.LockRotation(ctx, time.Minute); !acquired {
+		t.Fatal("expected an expired lock to be taken over")
+	}
+}
+
+func TestFallbackKeyStore(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyKeyStore{KeyStore: NewMemoryKeyStore()}
+	store := NewFallbackKeyStore(primary)
+
+	t.Run("fails before the primary was read", func(t *testing.T) {
+		primary.down = true
+		defer func() { primary.down = false }()
+
+		if _, err := store.ListKeys(ctx); !errors.Is(err, errStoreDown) {
+			t.Fatalf("expected the primary's error, got %v", err)
+		}
+	})
+
+	t.Run("serves the last keys while the primary is down", func(t *testing.T) {
+		if err := store.SaveKey(ctx, domain.SigningKey{ID: "kid-1"}); err != nil {
+			t.Fatalf("saving key: %v", err)
+		}
+		if _, err := store.ListKeys(ctx); err != nil {
+			t.Fatalf("listing keys: %v", err)
+		}
+		if err := store.SaveKey(ctx, domain.SigningKey{ID: "kid-2"}); err != nil {
+			t.Fatalf("saving key: %v", err)
+		}
+
+		primary.down = true
+		defer func() { primary.down = false }()
+
+		keys, err := store.ListKeys(ctx)
+		if err != nil {
+			t.Fatalf("expected the keys kept in memory, got %v", err)
+		}
+		if len(keys) != 2 {
+			t.Fatalf("expected 2 keys, got %d", len(keys))
+		}
+
+		if err := store.SaveKey(ctx, domain.SigningKey{ID: "kid-3"}); !errors.Is(err, errStoreDown) {
+			t.Fatalf("expected writes to fail while the primary is down, got %v", err)
+		}
+	})
+
+	t.Run("forgets deleted keys", func(t *testing.T) {
+		if err := store.DeleteKey(ctx, "kid-1"); err != nil {
+			t.Fatalf("deleting key: %v", err)
+		}
+
+		primary.down = true
+		defer func() { primary.down = false }()
+
+		keys, err := store.ListKeys(ctx)
+		if err != nil {
+			t.Fatalf("listing keys: %v", err)
+		}
+		if len(keys) != 1 || keys[0].ID != "kid-2" {
+			t.Fatalf("expected only kid-2, got %+v", keys)
+		}
+	})
+}
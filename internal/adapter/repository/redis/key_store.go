@@ -0,0 +1,172 @@
+package redis
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+	"github.com/redis/go-redis/v9"
+)
+
+// signingKeysKey is a hash of kid to sealed signing key.
+var signingKeysKey = "signing:keys"
+
+// keyRotationLockKey holds the token of the process rotating signing keys.
+var keyRotationLockKey = "signing:keys:lock"
+
+// sealedKeyPrefix marks a signing key encrypted with the key encryption key.
+// Values without it are plaintext JSON written before keys were encrypted.
+const sealedKeyPrefix = "v1:"
+
+// KeyEncryptionKeySize is the length of the AES-256 key signing keys are
+// encrypted with.
+const KeyEncryptionKeySize = 32
+
+// unlockRotationScript releases the rotation lock only for its holder.
+var unlockRotationScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type redisKeyStore struct {
+	client *redis.Client
+	aead   cipher.AEAD
+}
+
+func (r *redisKeyStore) SaveKey(ctx context.Context, key domain.SigningKey) error {
+	sealed, err := r.seal(key)
+	if err != nil {
+		return err
+	}
+
+	return r.client.HSet(ctx, signingKeysKey, key.ID, sealed).Err()
+}
+
+func (r *redisKeyStore) ListKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	values, err := r.client.HGetAll(ctx, signingKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]domain.SigningKey, 0, len(values))
+	for kid, value := range values {
+		key, err := r.open(kid, value)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", kid, err)
+		}
+
+		if !strings.HasPrefix(value, sealedKeyPrefix) {
+			if err := r.SaveKey(ctx, key); err != nil {
+				log.Println("Failed to encrypt stored signing key:", err)
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (r *redisKeyStore) DeleteKey(ctx context.Context, kid string) error {
+	deleted, err := r.client.HDel(ctx, signingKeysKey, kid).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return port.ErrKeyNotFound
+	}
+	return nil
+}
+
+func (r *redisKeyStore) LockRotation(ctx context.Context, ttl time.Duration) (string, bool, error) {
+	token := uuid.NewString()
+	acquired, err := r.client.SetNX(ctx, keyRotationLockKey, token, ttl).Result()
+	if err != nil {
+		return "", false, err
+	}
+	if !acquired {
+		return "", false, nil
+	}
+	return token, true, nil
+}
+
+func (r *redisKeyStore) UnlockRotation(ctx context.Context, token string) error {
+	return unlockRotationScript.Run(ctx, r.client, []string{keyRotationLockKey}, token).Err()
+}
+
+// seal encrypts a signing key, bound to its kid so a sealed key cannot be
+// moved to another entry of the hash.
+func (r *redisKeyStore) seal(key domain.SigningKey) (string, error) {
+	keyBytes, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := r.aead.Seal(nonce, nonce, keyBytes, []byte(key.ID))
+	return sealedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a value stored under kid. Plaintext keys written before
+// encryption are read as they are.
+func (r *redisKeyStore) open(kid string, value string) (domain.SigningKey, error) {
+	var key domain.SigningKey
+
+	keyBytes := []byte(value)
+	if encoded, ok := strings.CutPrefix(value, sealedKeyPrefix); ok {
+		sealed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return key, err
+		}
+		if len(sealed) < r.aead.NonceSize() {
+			return key, errors.New("sealed key too short")
+		}
+
+		nonce, ciphertext := sealed[:r.aead.NonceSize()], sealed[r.aead.NonceSize():]
+		keyBytes, err = r.aead.Open(nil, nonce, ciphertext, []byte(kid))
+		if err != nil {
+			return key, errors.New("cannot decrypt: wrong key encryption key?")
+		}
+	}
+
+	if err := json.Unmarshal(keyBytes, &key); err != nil {
+		return key, err
+	}
+	return key, nil
+}
+
+// NewRedisKeyStore keeps signing keys in Redis, encrypted with AES-256-GCM
+// under encryptionKey, which must be KeyEncryptionKeySize bytes and should
+// be kept outside Redis and its backups.
+func NewRedisKeyStore(client *redis.Client, encryptionKey []byte) (port.KeyStore, error) {
+	if len(encryptionKey) != KeyEncryptionKeySize {
+		return nil, fmt.Errorf("key encryption key must be %d bytes, got %d", KeyEncryptionKeySize, len(encryptionKey))
+	}
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redisKeyStore{client: client, aead: aead}, nil
+}
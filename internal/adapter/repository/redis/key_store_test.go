@@ -0,0 +1,88 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+func TestKeyStore(t *testing.T) {
+	ctx := context.Background()
+	db, mock := redismock.NewClientMock()
+
+	encryptionKey := bytes.Repeat([]byte{7}, KeyEncryptionKeySize)
+	store, err := NewRedisKeyStore(db, encryptionKey)
+	if err != nil {
+		t.Fatalf("creating key store: %v", err)
+	}
+	keys := store.(*redisKeyStore)
+
+	key := domain.SigningKey{ID: "kid-1", PrivateKey: []byte("private-key-material")}
+
+	t.Run("rejects a short encryption key", func(t *testing.T) {
+		if _, err := NewRedisKeyStore(db, []byte("short")); err == nil {
+			t.Fatal("expected an error for a short key")
+		}
+	})
+
+	t.Run("seals keys", func(t *testing.T) {
+		sealed, err := keys.seal(key)
+		if err != nil {
+			t.Fatalf("sealing key: %v", err)
+		}
+		if !strings.HasPrefix(sealed, sealedKeyPrefix) || strings.Contains(sealed, base64.StdEncoding.EncodeToString(key.PrivateKey)) {
+			t.Fatalf("expected an encrypted value, got %q", sealed)
+		}
+
+		opened, err := keys.open(key.ID, sealed)
+		if err != nil {
+			t.Fatalf("opening key: %v", err)
+		}
+		if opened.ID != key.ID || !bytes.Equal(opened.PrivateKey, key.PrivateKey) {
+			t.Fatalf("expected %+v, got %+v", key, opened)
+		}
+
+		if _, err := keys.open("kid-2", sealed); err == nil {
+			t.Fatal("expected a key sealed for another kid not to open")
+		}
+
+		other, _ := NewRedisKeyStore(db, bytes.Repeat([]byte{8}, KeyEncryptionKeySize))
+		if _, err := other.(*redisKeyStore).open(key.ID, sealed); err == nil {
+			t.Fatal("expected a key sealed under another encryption key not to open")
+		}
+	})
+
+	t.Run("encrypts plaintext keys it reads", func(t *testing.T) {
+		plaintext, _ := json.Marshal(key)
+		mock.ExpectHGetAll(signingKeysKey).SetVal(map[string]string{key.ID: string(plaintext)})
+		mock.Regexp().ExpectHSet(signingKeysKey, key.ID, "^v1:").SetVal(0)
+
+		listed, err := store.ListKeys(ctx)
+		if err != nil {
+			t.Fatalf("listing keys: %v", err)
+		}
+		if len(listed) != 1 || !bytes.Equal(listed[0].PrivateKey, key.PrivateKey) {
+			t.Fatalf("expected the plaintext key, got %+v", listed)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("releases only its own rotation lock", func(t *testing.T) {
+		mock.ExpectEvalSha(unlockRotationScript.Hash(), []string{keyRotationLockKey}, "token-1").SetVal(int64(0))
+
+		if err := store.UnlockRotation(ctx, "token-1"); err != nil {
+			t.Fatalf("unlocking: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+var loginFailureKeyPrefix = "user:login-failures:"
+
+// reserveLoginAttemptScript counts an attempt as failed if the count is
+// still the expected one, stamps when it happened and pushes back the expiry
+// of the counter. It returns -1 when another attempt got there first.
+var reserveLoginAttemptScript = redis.NewScript(`
+local count = tonumber(redis.call('HGET', KEYS[1], 'count') or '0')
+if count ~= tonumber(ARGV[1]) then
+	return -1
+end
+count = redis.call('HINCRBY', KEYS[1], 'count', 1)
+redis.call('HSET', KEYS[1], 'last', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return count
+`)
+
+// releaseLoginAttemptScript uncounts a reserved attempt, deleting the counter
+// when none are left.
+var releaseLoginAttemptScript = redis.NewScript(`
+if redis.call('HINCRBY', KEYS[1], 'count', -1) <= 0 then
+	redis.call('DEL', KEYS[1])
+end
+return 1
+`)
+
+func (r *redisAuthRepo) ReserveLoginAttempt(ctx context.Context, phonenumber string, expected int, at time.Time, ttl time.Duration) (*domain.LoginFailures, error) {
+	count, err := reserveLoginAttemptScript.Run(ctx, r.client,
+		[]string{loginFailureKeyPrefix + phonenumber},
+		expected,
+		at.UnixMilli(),
+		ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, nil
+	}
+
+	return &domain.LoginFailures{Count: count, LastFailureAt: at}, nil
+}
+
+func (r *redisAuthRepo) ReleaseLoginAttempt(ctx context.Context, phonenumber string) error {
+	return releaseLoginAttemptScript.Run(ctx, r.client, []string{loginFailureKeyPrefix + phonenumber}).Err()
+}
+
+func (r *redisAuthRepo) ReadLoginFailures(ctx context.Context, phonenumber string) (*domain.LoginFailures, error) {
+	values, err := r.client.HMGet(ctx, loginFailureKeyPrefix+phonenumber, "count", "last").Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	failures := &domain.LoginFailures{}
+	if count, ok := values[0].(string); ok {
+		failures.Count, _ = strconv.Atoi(count)
+	}
+	if last, ok := values[1].(string); ok {
+		if ms, err := strconv.ParseInt(last, 10, 64); err == nil {
+			failures.LastFailureAt = time.UnixMilli(ms)
+		}
+	}
+
+	return failures, nil
+}
+
+func (r *redisAuthRepo) ClearLoginFailures(ctx context.Context, phonenumber string) (bool, error) {
+	deleted, err := r.client.Del(ctx, loginFailureKeyPrefix+phonenumber).Result()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
@@ -0,0 +1,132 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	clientKeyPrefix            = "oauth:client:"
+	clientsKey                 = "oauth:clients"
+	authorizationCodeKeyPrefix = "oauth:code:"
+)
+
+func (r *redisAuthRepo) SaveClient(ctx context.Context, client domain.Client) error {
+	clientBytes, err := json.Marshal(client)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Set(ctx, clientKeyPrefix+client.ID, clientBytes, 0)
+	pipe.SAdd(ctx, clientsKey, client.ID)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisAuthRepo) ReadClient(ctx context.Context, id string) (*domain.Client, error) {
+	data, err := r.client.Get(ctx, clientKeyPrefix+id).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, port.ErrClientNotFound
+		}
+		return nil, err
+	}
+
+	client := &domain.Client{}
+	if err := json.Unmarshal([]byte(data), client); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (r *redisAuthRepo) ListClients(ctx context.Context) ([]domain.Client, error) {
+	ids, err := r.client.SMembers(ctx, clientsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	clients := []domain.Client{}
+	if len(ids) == 0 {
+		return clients, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = clientKeyPrefix + id
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var client domain.Client
+		if err := json.Unmarshal([]byte(data), &client); err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+func (r *redisAuthRepo) DeleteClient(ctx context.Context, id string) error {
+	pipe := r.client.TxPipeline()
+	deleted := pipe.Del(ctx, clientKeyPrefix+id)
+	pipe.SRem(ctx, clientsKey, id)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if deleted.Val() == 0 {
+		return port.ErrClientNotFound
+	}
+	return nil
+}
+
+func (r *redisAuthRepo) SaveAuthorizationCode(ctx context.Context, code domain.AuthorizationCode) error {
+	ttl := time.Until(code.ExpiresAt)
+	if ttl <= 0 {
+		return port.ErrAuthorizationCodeNotFound
+	}
+
+	codeBytes, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, authorizationCodeKeyPrefix+hashToken(code.Code), codeBytes, ttl).Err()
+}
+
+func (r *redisAuthRepo) ConsumeAuthorizationCode(ctx context.Context, code string) (*domain.AuthorizationCode, error) {
+	data, err := r.client.GetDel(ctx, authorizationCodeKeyPrefix+hashToken(code)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, port.ErrAuthorizationCodeNotFound
+		}
+		return nil, err
+	}
+
+	authorizationCode := &domain.AuthorizationCode{}
+	if err := json.Unmarshal([]byte(data), authorizationCode); err != nil {
+		return nil, err
+	}
+	authorizationCode.Code = code
+
+	return authorizationCode, nil
+}
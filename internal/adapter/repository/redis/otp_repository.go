@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+	"github.com/redis/go-redis/v9"
+)
+
+var rateLimitKeyPrefix = "ratelimit:"
+
+// attemptOneTimeCodeScript increments the attempt counter of a code and
+// returns the updated code.
+var attemptOneTimeCodeScript = redis.NewScript(`
+local data = redis.call('GET', KEYS[1])
+if not data then
+	return false
+end
+local code = cjson.decode(data)
+code.attempts = (tonumber(code.attempts) or 0) + 1
+data = cjson.encode(code)
+redis.call('SET', KEYS[1], data, 'KEEPTTL')
+return data
+`)
+
+// hitRateLimitScript counts a hit in a fixed window that starts with the
+// first hit, returning the count and the milliseconds left in the window.
+var hitRateLimitScript = redis.NewScript(`
+local hits = redis.call('INCR', KEYS[1])
+if hits == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {hits, redis.call('PTTL', KEYS[1])}
+`)
+
+func oneTimeCodeKey(purpose string, subject string) string {
+	return verificationTokenKeyPrefix + purpose + ":" + subject
+}
+
+func (r *redisAuthRepo) SaveOneTimeCode(ctx context.Context, code domain.OneTimeCode) error {
+	ttl := time.Until(code.ExpiresAt)
+	if ttl <= 0 {
+		return port.ErrOneTimeCodeNotFound
+	}
+
+	codeBytes, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, oneTimeCodeKey(code.Purpose, code.Subject), codeBytes, ttl).Err()
+}
+
+func (r *redisAuthRepo) AttemptOneTimeCode(ctx context.Context, purpose string, subject string) (*domain.OneTimeCode, error) {
+	data, err := attemptOneTimeCodeScript.Run(ctx, r.client, []string{oneTimeCodeKey(purpose, subject)}).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, port.ErrOneTimeCodeNotFound
+		}
+		return nil, err
+	}
+
+	code := &domain.OneTimeCode{}
+	if err := json.Unmarshal([]byte(data), code); err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+func (r *redisAuthRepo) DeleteOneTimeCode(ctx context.Context, purpose string, subject string) (bool, error) {
+	deleted, err := r.client.Del(ctx, oneTimeCodeKey(purpose, subject)).Result()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+func (r *redisAuthRepo) HitRateLimit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	result, err := hitRateLimitScript.Run(ctx, r.client, []string{rateLimitKeyPrefix + key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	retryAfter := time.Duration(result[1]) * time.Millisecond
+	if retryAfter < 0 {
+		retryAfter = window
+	}
+
+	return result[0], retryAfter, nil
+}
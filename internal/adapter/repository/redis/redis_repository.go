@@ -2,9 +2,14 @@ package redis
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mar-cial/space-auth/internal/core/domain"
 	"github.com/mar-cial/space-auth/internal/core/port"
@@ -20,9 +25,19 @@ var (
 	accountKeyPrefix           = "user:account:"
 	accountByUserIdPrefix      = "user:account:by-user-id:"
 	sessionByUserIdKeyPrefix   = "user:session:by-user-id:"
+	phoneKeyPrefix             = "user:phone:"
 	phoneByUserIdKeyPrefix     = "user:phone:by-user-id:"
+
+	refreshTokenKeyPrefix            = "user:refresh:"
+	refreshFamilyKeyPrefix           = "user:refresh:family:"
+	refreshFamiliesByUserIdKeyPrefix = "user:refresh:by-user-id:"
+	eventsByUserIdKeyPrefix          = "user:events:by-user-id:"
+	revokedSessionKeyPrefix          = "user:session:revoked:"
 )
 
+// listUsersBatchSize is how many keys ListUsers scans and reads at a time.
+const listUsersBatchSize = 500
+
 type redisAuthRepo struct {
 	client *redis.Client
 }
@@ -36,6 +51,7 @@ func (r *redisAuthRepo) SaveUser(ctx context.Context, user domain.User) (string,
 	// Construct Redis keys using prefixes
 	userKey := userKeyPrefix + user.ID
 	phoneKey := phoneByUserIdKeyPrefix + user.ID
+	userByPhoneKey := phoneKeyPrefix + user.Phonenumber
 	accountKey := accountKeyPrefix + user.ID
 
 	// Create a pipeline for multiple commands
@@ -44,6 +60,7 @@ func (r *redisAuthRepo) SaveUser(ctx context.Context, user domain.User) (string,
 	// Set the user, phone, and account details
 	pipe.Set(ctx, userKey, marshalledUser, 0)
 	pipe.Set(ctx, phoneKey, user.Phonenumber, 0)
+	pipe.Set(ctx, userByPhoneKey, user.ID, 0)
 	pipe.Set(ctx, accountKey, user.ID, 0)
 
 	// Execute the pipeline
@@ -56,10 +73,13 @@ func (r *redisAuthRepo) SaveUser(ctx context.Context, user domain.User) (string,
 }
 
 func (r *redisAuthRepo) ReadUserByID(ctx context.Context, id string) (*domain.User, error) {
-	userkey := fmt.Sprintf("%s:%s", userKeyPrefix, id)
+	userkey := userKeyPrefix + id
 
 	userResponse, err := r.client.Get(ctx, userkey).Result()
 	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, port.ErrUserNotFound
+		}
 		return nil, err
 	}
 
@@ -72,9 +92,9 @@ func (r *redisAuthRepo) ReadUserByID(ctx context.Context, id string) (*domain.Us
 }
 
 func (r *redisAuthRepo) ReadUserByPhone(ctx context.Context, phone string) (*domain.User, error) {
-	userkey := fmt.Sprintf("%s:%s", phoneByUserIdKeyPrefix, phone)
+	phonekey := phoneKeyPrefix + phone
 
-	userResponse, err := r.client.Get(ctx, userkey).Result()
+	userID, err := r.client.Get(ctx, phonekey).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			// Return nil, nil when the user is not found
@@ -83,14 +103,26 @@ func (r *redisAuthRepo) ReadUserByPhone(ctx context.Context, phone string) (*dom
 		return nil, err
 	}
 
-	user := &domain.User{}
-	if err := json.Unmarshal([]byte(userResponse), user); err != nil {
+	user, err := r.ReadUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, port.ErrUserNotFound) {
+			// Dangling phone index entry
+			return nil, nil
+		}
 		return nil, err
 	}
 
 	return user, nil
 }
 
+func (r *redisAuthRepo) PhoneOwner(ctx context.Context, phone string) (string, error) {
+	userID, err := r.client.Get(ctx, phoneKeyPrefix+phone).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return userID, err
+}
+
 func (r *redisAuthRepo) UpdateUser(ctx context.Context, user domain.User) (*domain.User, error) {
 	// Get existing user to check for phone number changes
 	existingUser, err := r.ReadUserByID(ctx, user.ID)
@@ -113,7 +145,8 @@ func (r *redisAuthRepo) UpdateUser(ctx context.Context, user domain.User) (*doma
 
 		// Create new phone mapping
 		newPhoneKey := fmt.Sprintf("user:phone:%s", user.Phonenumber)
-		pipe.Set(ctx, newPhoneKey, userData, 0)
+		pipe.Set(ctx, newPhoneKey, user.ID, 0)
+		pipe.Set(ctx, phoneByUserIdKeyPrefix+user.ID, user.Phonenumber, 0)
 	}
 
 	_, err = pipe.Exec(ctx)
@@ -124,6 +157,102 @@ func (r *redisAuthRepo) UpdateUser(ctx context.Context, user domain.User) (*doma
 	return &user, nil
 }
 
+// compareAndSetScript replaces a value only if it is still the one read.
+var compareAndSetScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2])
+return 1
+`)
+
+// UpdatePasswordHash rewrites the user record with the new hash unless the
+// record changed since it was read, so a password set in the meantime, or any
+// other change to the user, is never overwritten.
+func (r *redisAuthRepo) UpdatePasswordHash(ctx context.Context, userID string, oldHash string, newHash string) (bool, error) {
+	userKey := userKeyPrefix + userID
+
+	stored, err := r.client.Get(ctx, userKey).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, port.ErrUserNotFound
+		}
+		return false, err
+	}
+
+	var user domain.User
+	if err := json.Unmarshal([]byte(stored), &user); err != nil {
+		return false, err
+	}
+	if user.Password != oldHash {
+		return false, nil
+	}
+
+	user.Password = newHash
+	updated, err := json.Marshal(user)
+	if err != nil {
+		return false, err
+	}
+
+	set, err := compareAndSetScript.Run(ctx, r.client, []string{userKey}, stored, string(updated)).Int()
+	if err != nil {
+		return false, err
+	}
+	return set == 1, nil
+}
+
+// ListUsers scans every user record. User keys are the only keys under
+// userKeyPrefix without a further colon.
+func (r *redisAuthRepo) ListUsers(ctx context.Context) ([]domain.User, error) {
+	users := []domain.User{}
+
+	iter := r.client.Scan(ctx, 0, userKeyPrefix+"*", listUsersBatchSize).Iterator()
+	var keys []string
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		values, err := r.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		for _, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				continue
+			}
+			var user domain.User
+			if err := json.Unmarshal([]byte(data), &user); err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+		keys = keys[:0]
+		return nil
+	}
+
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if strings.Contains(strings.TrimPrefix(key, userKeyPrefix), ":") {
+			continue
+		}
+		keys = append(keys, key)
+		if len(keys) == listUsersBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (r *redisAuthRepo) DeleteUser(ctx context.Context, user domain.User) error {
 	pipe := r.client.TxPipeline()
 
@@ -137,24 +266,102 @@ func (r *redisAuthRepo) DeleteUser(ctx context.Context, user domain.User) error
 	return err
 }
 
-// Fix session key generation in redis/repository.go
+// saveSessionScript writes the session with a TTL matching its expiry, adds
+// it to the user's index, drops index entries that have already expired and
+// keeps the index itself alive exactly as long as its last session.
+var saveSessionScript = redis.NewScript(`
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', '(' .. ARGV[5])
+local last = redis.call('ZRANGE', KEYS[2], -1, -1, 'WITHSCORES')
+if last[2] then
+	redis.call('EXPIREAT', KEYS[2], math.ceil(tonumber(last[2])))
+end
+return 1
+`)
+
+// SaveSession stores the session under the hash of its token and adds the
+// hash to the user's session index, a sorted set scored by expiry. Both keys
+// expire on their own once the session does.
 func (r *redisAuthRepo) SaveSession(ctx context.Context, session domain.Session, userid string) (string, error) {
-	sessionKey := fmt.Sprintf("user:session:%s", session.Token) // Use Token instead of ID
-	sessionByIDKey := fmt.Sprintf("user:session:by-user-id:%s", userid)
+	tokenHash := hashToken(session.Token)
+	sessionKey := sessionKeyPrefix + tokenHash
+	sessionByUserKey := sessionByUserIdKeyPrefix + userid
+
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return "", port.ErrSessionExpired
+	}
 
 	sessionBytes, err := json.Marshal(session)
 	if err != nil {
 		return "", err
 	}
 
-	return r.client.MSet(ctx, sessionKey, sessionBytes, sessionByIDKey, sessionBytes).Result()
+	err = saveSessionScript.Run(ctx, r.client,
+		[]string{sessionKey, sessionByUserKey},
+		sessionBytes,
+		ttl.Milliseconds(),
+		tokenHash,
+		session.ExpiresAt.Unix(),
+		time.Now().Unix(),
+	).Err()
+	if err != nil {
+		return "", err
+	}
+
+	return session.ID, nil
 }
 
-// Add proper error type to port
+// extendSessionScript is saveSessionScript for sessions that must already
+// exist: a session revoked while it was being extended stays revoked.
+var extendSessionScript = redis.NewScript(`
+if not redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2], 'XX') then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[3])
+local last = redis.call('ZRANGE', KEYS[2], -1, -1, 'WITHSCORES')
+if last[2] then
+	redis.call('EXPIREAT', KEYS[2], math.ceil(tonumber(last[2])))
+end
+return 1
+`)
+
+func (r *redisAuthRepo) ExtendSession(ctx context.Context, session domain.Session) error {
+	tokenHash := hashToken(session.Token)
+	sessionKey := sessionKeyPrefix + tokenHash
+	sessionByUserKey := sessionByUserIdKeyPrefix + session.UserID
+
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return port.ErrSessionExpired
+	}
+
+	sessionBytes, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	extended, err := extendSessionScript.Run(ctx, r.client,
+		[]string{sessionKey, sessionByUserKey},
+		sessionBytes,
+		ttl.Milliseconds(),
+		tokenHash,
+		session.ExpiresAt.Unix(),
+	).Int()
+	if err != nil {
+		return err
+	}
+
+	if extended == 0 {
+		return port.ErrSessionNotFound
+	}
+
+	return nil
+}
 
-// Update FindSessionByToken in redis/repository.go
 func (r *redisAuthRepo) FindSessionByToken(ctx context.Context, token string) (*domain.Session, error) {
-	sessionKey := fmt.Sprintf("user:session:%s", token)
+	sessionKey := sessionKeyPrefix + hashToken(token)
 	data, err := r.client.Get(ctx, sessionKey).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
@@ -166,25 +373,258 @@ func (r *redisAuthRepo) FindSessionByToken(ctx context.Context, token string) (*
 	if err := json.Unmarshal([]byte(data), session); err != nil {
 		return nil, err
 	}
+	session.Token = token
 	return session, nil
 }
 
+// ListSessions returns the user's sessions that have not yet expired,
+// latest expiry first.
+func (r *redisAuthRepo) ListSessions(ctx context.Context, userid string) ([]domain.Session, error) {
+	sessionByUserKey := sessionByUserIdKeyPrefix + userid
+
+	tokenHashes, err := r.client.ZRevRangeByScore(ctx, sessionByUserKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(time.Now().Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokenHashes) == 0 {
+		return []domain.Session{}, nil
+	}
+
+	keys := make([]string, len(tokenHashes))
+	for i, tokenHash := range tokenHashes {
+		keys[i] = sessionKeyPrefix + tokenHash
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]domain.Session, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// Session key is gone but the index entry is still around
+			continue
+		}
+
+		var session domain.Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// luaDenySession is prepended to scripts that delete sessions: it puts the
+// session ID on the denylist for as long as the session had left to live, so
+// access tokens minted for the session stop verifying with it.
+const luaDenySession = `
+local function denySession(sessionKey, session, revokedPrefix)
+	local ttl = redis.call('PTTL', sessionKey)
+	if ttl > 0 then
+		redis.call('SET', revokedPrefix .. session.id, 1, 'PX', ttl)
+	end
+end
+`
+
+// deleteSessionScript deletes a session, its index entry and the refresh
+// token family it was issued with.
+var deleteSessionScript = redis.NewScript(luaRevokeFamily + luaDenySession + `
+local data = redis.call('GET', KEYS[1])
+if not data then
+	return 0
+end
+local session = cjson.decode(data)
+denySession(KEYS[1], session, ARGV[3])
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+if type(session.family_id) == 'string' then
+	revokeFamily(ARGV[2], KEYS[3], session.family_id)
+end
+return 1
+`)
+
 func (r *redisAuthRepo) DeleteSession(ctx context.Context, token string) error {
-	sessionKey := fmt.Sprintf("user:session:%s", token)
+	tokenHash := hashToken(token)
+	sessionKey := sessionKeyPrefix + tokenHash
 
 	session, err := r.FindSessionByToken(ctx, token)
 	if err != nil {
 		return err
 	}
 
-	// 2. Delete both keys (adjust if session lacks UserID)
-	sessionByUserKey := fmt.Sprintf("user:session:by-user-id:%s", session.UserID) // Assumes UserID exists
-	if err := r.client.Del(ctx, sessionKey, sessionByUserKey).Err(); err != nil {
+	return deleteSessionScript.Run(ctx, r.client,
+		[]string{
+			sessionKey,
+			sessionByUserIdKeyPrefix + session.UserID,
+			refreshFamiliesByUserIdKeyPrefix + session.UserID,
+		},
+		tokenHash, refreshFamilyKeyPrefix, revokedSessionKeyPrefix,
+	).Err()
+}
+
+// revokeAllSessionsScript deletes every session in a user's index except
+// ARGV[2], along with every refresh token family but the kept session's.
+// Running it as a script keeps a concurrent login from being saved halfway
+// through the revocation.
+var revokeAllSessionsScript = redis.NewScript(luaRevokeFamily + luaDenySession + `
+local keepFamily = ''
+local kept = redis.call('GET', ARGV[1] .. ARGV[2])
+if ARGV[2] ~= '' and kept then
+	local session = cjson.decode(kept)
+	if type(session.family_id) == 'string' then
+		keepFamily = session.family_id
+	end
+end
+
+local tokens = redis.call('ZRANGE', KEYS[1], 0, -1)
+local revoked = 0
+for _, token in ipairs(tokens) do
+	if token ~= ARGV[2] then
+		local data = redis.call('GET', ARGV[1] .. token)
+		if data then
+			denySession(ARGV[1] .. token, cjson.decode(data), ARGV[4])
+		end
+		redis.call('DEL', ARGV[1] .. token)
+		redis.call('ZREM', KEYS[1], token)
+		revoked = revoked + 1
+	end
+end
+
+local families = redis.call('ZRANGE', KEYS[2], 0, -1)
+for _, familyID in ipairs(families) do
+	if familyID ~= keepFamily then
+		revokeFamily(ARGV[3], KEYS[2], familyID)
+	end
+end
+return revoked
+`)
+
+// revokeSessionByIDScript deletes the session in a user's index whose stored
+// ID matches ARGV[2], and its refresh token family. Returns 1 when a session
+// was removed, 0 otherwise.
+var revokeSessionByIDScript = redis.NewScript(luaRevokeFamily + luaDenySession + `
+local tokens = redis.call('ZRANGE', KEYS[1], 0, -1)
+for _, token in ipairs(tokens) do
+	local data = redis.call('GET', ARGV[1] .. token)
+	if data then
+		local session = cjson.decode(data)
+		if session.id == ARGV[2] then
+			denySession(ARGV[1] .. token, session, ARGV[4])
+			redis.call('DEL', ARGV[1] .. token)
+			redis.call('ZREM', KEYS[1], token)
+			if type(session.family_id) == 'string' then
+				revokeFamily(ARGV[3], KEYS[2], session.family_id)
+			end
+			return 1
+		end
+	end
+end
+return 0
+`)
+
+func (r *redisAuthRepo) RevokeAllSessions(ctx context.Context, userid string, exceptToken string) (int, error) {
+	exceptHash := ""
+	if exceptToken != "" {
+		exceptHash = hashToken(exceptToken)
+	}
+
+	revoked, err := revokeAllSessionsScript.Run(ctx, r.client,
+		[]string{
+			sessionByUserIdKeyPrefix + userid,
+			refreshFamiliesByUserIdKeyPrefix + userid,
+		},
+		sessionKeyPrefix, exceptHash, refreshFamilyKeyPrefix, revokedSessionKeyPrefix,
+	).Int()
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, nil
+}
+
+func (r *redisAuthRepo) RevokeSessionByID(ctx context.Context, userid string, sessionid string) error {
+	revoked, err := revokeSessionByIDScript.Run(ctx, r.client,
+		[]string{
+			sessionByUserIdKeyPrefix + userid,
+			refreshFamiliesByUserIdKeyPrefix + userid,
+		},
+		sessionKeyPrefix, sessionid, refreshFamilyKeyPrefix, revokedSessionKeyPrefix,
+	).Int()
+	if err != nil {
 		return err
 	}
+
+	if revoked == 0 {
+		return port.ErrSessionNotFound
+	}
+
 	return nil
 }
 
+// pruneSessionIndexScript removes entries from a user's session index that
+// have expired or whose session key no longer exists.
+var pruneSessionIndexScript = redis.NewScript(`
+local pruned = redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', '(' .. ARGV[2])
+local tokens = redis.call('ZRANGE', KEYS[1], 0, -1)
+for _, token in ipairs(tokens) do
+	if redis.call('EXISTS', ARGV[1] .. token) == 0 then
+		pruned = pruned + redis.call('ZREM', KEYS[1], token)
+	end
+end
+return pruned
+`)
+
+// PruneSessions walks every user's session index and reaps orphaned entries,
+// returning how many were removed.
+func (r *redisAuthRepo) PruneSessions(ctx context.Context) (int, error) {
+	pruned := 0
+	now := time.Now().Unix()
+
+	iter := r.client.Scan(ctx, 0, sessionByUserIdKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		removed, err := pruneSessionIndexScript.Run(ctx, r.client,
+			[]string{iter.Val()},
+			sessionKeyPrefix, now,
+		).Int()
+		if err != nil {
+			return pruned, err
+		}
+		pruned += removed
+	}
+
+	if err := iter.Err(); err != nil {
+		return pruned, err
+	}
+
+	return pruned, nil
+}
+
+// IsSessionRevoked reports whether the session ID is on the denylist of
+// sessions revoked before they expired.
+func (r *redisAuthRepo) IsSessionRevoked(ctx context.Context, sessionid string) (bool, error) {
+	exists, err := r.client.Exists(ctx, revokedSessionKeyPrefix+sessionid).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+// hashToken is how session and refresh tokens are stored. Tokens never reach
+// Redis in the clear, so a read of the database or a dump of it cannot be
+// replayed as a live credential.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func NewRedisAuthRepository(client *redis.Client) port.AuthRepository {
 	return &redisAuthRepo{client: client}
 }
@@ -2,13 +2,17 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redismock/v9"
+	"github.com/mar-cial/space-auth/internal/core/port"
 )
 
 func TestAuthRepository(t *testing.T) {
 	db, mock := redismock.NewClientMock()
+	repo := NewRedisAuthRepository(db)
 
 	t.Run("Ping", func(t *testing.T) {
 		expected := "PONG"
@@ -31,6 +35,160 @@ func TestAuthRepository(t *testing.T) {
 		})
 	})
 
+	t.Run("FindSessionByToken", func(t *testing.T) {
+		t.Run("looks up the token hash", func(t *testing.T) {
+			token := "raw-session-token"
+			mock.ExpectGet(sessionKeyPrefix + hashToken(token)).RedisNil()
+
+			_, err := repo.FindSessionByToken(context.Background(), token)
+			if !errors.Is(err, port.ErrSessionNotFound) {
+				t.Fatalf("expected ErrSessionNotFound, got %v", err)
+			}
+		})
+	})
+
+	t.Run("PhoneOwner", func(t *testing.T) {
+		mock.ExpectGet(phoneKeyPrefix + "+12025550123").SetVal("user-1")
+		mock.ExpectGet(phoneKeyPrefix + "+12025550199").RedisNil()
+
+		if owner, err := repo.PhoneOwner(context.Background(), "+12025550123"); err != nil || owner != "user-1" {
+			t.Fatalf("expected user-1, got %q, %v", owner, err)
+		}
+		if owner, err := repo.PhoneOwner(context.Background(), "+12025550199"); err != nil || owner != "" {
+			t.Fatalf("expected no owner, got %q, %v", owner, err)
+		}
+	})
+
+	t.Run("UpdatePasswordHash", func(t *testing.T) {
+		key := userKeyPrefix + "user-1"
+		stored := `{"id":"user-1","phonenumber":"+12025550123","password":"old"}`
+		updated := `{"id":"user-1","phonenumber":"+12025550123","password":"new"}`
+
+		t.Run("updated", func(t *testing.T) {
+			mock.ExpectGet(key).SetVal(stored)
+			mock.ExpectEvalSha(compareAndSetScript.Hash(), []string{key}, stored, updated).SetVal(int64(1))
+
+			ok, err := repo.UpdatePasswordHash(context.Background(), "user-1", "old", "new")
+			if err != nil || !ok {
+				t.Fatalf("expected the hash to be updated, got %v, %v", ok, err)
+			}
+		})
+
+		t.Run("password changed before reading", func(t *testing.T) {
+			mock.ExpectGet(key).SetVal(updated)
+
+			ok, err := repo.UpdatePasswordHash(context.Background(), "user-1", "old", "newer")
+			if err != nil || ok {
+				t.Fatalf("expected no update, got %v, %v", ok, err)
+			}
+		})
+
+		t.Run("user changed after reading", func(t *testing.T) {
+			mock.ExpectGet(key).SetVal(stored)
+			mock.ExpectEvalSha(compareAndSetScript.Hash(), []string{key}, stored, updated).SetVal(int64(0))
+
+			ok, err := repo.UpdatePasswordHash(context.Background(), "user-1", "old", "new")
+			if err != nil || ok {
+				t.Fatalf("expected no update, got %v, %v", ok, err)
+			}
+		})
+	})
+
+	t.Run("RevokeSessionByID", func(t *testing.T) {
+		t.Run("not found", func(t *testing.T) {
+			mock.ExpectEvalSha(
+				revokeSessionByIDScript.Hash(),
+				[]string{
+					sessionByUserIdKeyPrefix + "user-1",
+					refreshFamiliesByUserIdKeyPrefix + "user-1",
+				},
+				sessionKeyPrefix, "missing", refreshFamilyKeyPrefix, revokedSessionKeyPrefix,
+			).SetVal(int64(0))
+
+			err := repo.RevokeSessionByID(context.Background(), "user-1", "missing")
+			if !errors.Is(err, port.ErrSessionNotFound) {
+				t.Fatalf("expected ErrSessionNotFound, got %v", err)
+			}
+		})
+	})
+
+	t.Run("PruneSessions", func(t *testing.T) {
+		first := sessionByUserIdKeyPrefix + "user-1"
+		second := sessionByUserIdKeyPrefix + "user-2"
+		mock.ExpectScan(0, sessionByUserIdKeyPrefix+"*", 100).SetVal([]string{first, second}, 0)
+		// The last argument is the current time
+		mock.Regexp().ExpectEvalSha(pruneSessionIndexScript.Hash(), []string{first}, sessionKeyPrefix, `^\d+$`).SetVal(int64(2))
+		mock.Regexp().ExpectEvalSha(pruneSessionIndexScript.Hash(), []string{second}, sessionKeyPrefix, `^\d+$`).SetVal(int64(1))
+
+		pruned, err := repo.PruneSessions(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pruned != 3 {
+			t.Fatalf("expected 3 entries pruned, got %d", pruned)
+		}
+	})
+
+	t.Run("ReadLoginFailures", func(t *testing.T) {
+		t.Run("none recorded", func(t *testing.T) {
+			mock.ExpectHMGet(loginFailureKeyPrefix+"+15555550100", "count", "last").SetVal([]interface{}{nil, nil})
+
+			failures, err := repo.ReadLoginFailures(context.Background(), "+15555550100")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if failures.Count != 0 || !failures.LastFailureAt.IsZero() {
+				t.Fatalf("expected no failures, got %+v", failures)
+			}
+		})
+
+		t.Run("recorded", func(t *testing.T) {
+			mock.ExpectHMGet(loginFailureKeyPrefix+"+15555550100", "count", "last").SetVal([]interface{}{"4", "1714564800000"})
+
+			failures, err := repo.ReadLoginFailures(context.Background(), "+15555550100")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if failures.Count != 4 || failures.LastFailureAt.UnixMilli() != 1714564800000 {
+				t.Fatalf("expected 4 failures at 1714564800000, got %+v", failures)
+			}
+		})
+	})
+
+	t.Run("ReserveLoginAttempt", func(t *testing.T) {
+		at := time.UnixMilli(1714564800000)
+		key := []string{loginFailureKeyPrefix + "+15555550100"}
+
+		t.Run("reserved", func(t *testing.T) {
+			mock.ExpectEvalSha(reserveLoginAttemptScript.Hash(), key, 3, int64(1714564800000), int64(3600000)).SetVal(int64(4))
+
+			failures, err := repo.ReserveLoginAttempt(context.Background(), "+15555550100", 3, at, time.Hour)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if failures == nil || failures.Count != 4 || !failures.LastFailureAt.Equal(at) {
+				t.Fatalf("expected 4 failures at %v, got %+v", at, failures)
+			}
+		})
+
+		t.Run("count changed", func(t *testing.T) {
+			mock.ExpectEvalSha(reserveLoginAttemptScript.Hash(), key, 3, int64(1714564800000), int64(3600000)).SetVal(int64(-1))
+
+			failures, err := repo.ReserveLoginAttempt(context.Background(), "+15555550100", 3, at, time.Hour)
+			if err != nil || failures != nil {
+				t.Fatalf("expected no reservation, got %+v, %v", failures, err)
+			}
+		})
+
+		t.Run("released", func(t *testing.T) {
+			mock.ExpectEvalSha(releaseLoginAttemptScript.Hash(), key).SetVal(int64(1))
+
+			if err := repo.ReleaseLoginAttempt(context.Background(), "+15555550100"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	})
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("Expectations were not met: %v", err)
 	}
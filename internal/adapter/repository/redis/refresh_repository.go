@@ -0,0 +1,192 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxEventsPerUser caps the audit trail kept for each user.
+const maxEventsPerUser = 100
+
+// luaRevokeFamily is prepended to scripts that need to revoke a refresh
+// token family: it deletes every token of the family, the family set and
+// its entry in the user's family index.
+const luaRevokeFamily = `
+local function revokeFamily(familyPrefix, familiesKey, familyID)
+	local familyKey = familyPrefix .. familyID
+	for _, key in ipairs(redis.call('SMEMBERS', familyKey)) do
+		redis.call('DEL', key)
+	end
+	redis.call('DEL', familyKey)
+	redis.call('ZREM', familiesKey, familyID)
+end
+`
+
+// saveRefreshTokenScript stores a refresh token, adds it to its family and
+// records the family in the user's family index, a sorted set scored by
+// expiry.
+var saveRefreshTokenScript = redis.NewScript(`
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+redis.call('SADD', KEYS[2], KEYS[1])
+redis.call('PEXPIRE', KEYS[2], ARGV[2])
+redis.call('ZADD', KEYS[3], ARGV[4], ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[3], '-inf', '(' .. ARGV[5])
+local last = redis.call('ZRANGE', KEYS[3], -1, -1, 'WITHSCORES')
+if last[2] then
+	redis.call('EXPIREAT', KEYS[3], math.ceil(tonumber(last[2])))
+end
+return 1
+`)
+
+// useRefreshTokenScript stamps used_at on a refresh token the first time it
+// is presented and ends the session the token was issued with, which the
+// caller replaces. It returns the token as it was before, and whether this
+// was its first use.
+var useRefreshTokenScript = redis.NewScript(luaDenySession + `
+local data = redis.call('GET', KEYS[1])
+if not data then
+	return false
+end
+local token = cjson.decode(data)
+if type(token.used_at) == 'string' then
+	return {0, data}
+end
+token.used_at = ARGV[1]
+redis.call('SET', KEYS[1], cjson.encode(token), 'KEEPTTL')
+
+local index = ARGV[2] .. token.user_id
+for _, tokenHash in ipairs(redis.call('ZRANGE', index, 0, -1)) do
+	local sessionData = redis.call('GET', ARGV[3] .. tokenHash)
+	if sessionData then
+		local session = cjson.decode(sessionData)
+		if session.id == token.session_id then
+			denySession(ARGV[3] .. tokenHash, session, ARGV[4])
+			redis.call('DEL', ARGV[3] .. tokenHash)
+			redis.call('ZREM', index, tokenHash)
+			break
+		end
+	end
+end
+return {1, data}
+`)
+
+// revokeRefreshFamilyScript revokes a refresh token family and every
+// session of the user that was issued from it.
+var revokeRefreshFamilyScript = redis.NewScript(luaRevokeFamily + luaDenySession + `
+revokeFamily(ARGV[1], KEYS[2], ARGV[2])
+local tokens = redis.call('ZRANGE', KEYS[1], 0, -1)
+for _, token in ipairs(tokens) do
+	local data = redis.call('GET', ARGV[3] .. token)
+	if data then
+		local session = cjson.decode(data)
+		if session.family_id == ARGV[2] then
+			denySession(ARGV[3] .. token, session, ARGV[4])
+			redis.call('DEL', ARGV[3] .. token)
+			redis.call('ZREM', KEYS[1], token)
+		end
+	end
+end
+return 1
+`)
+
+func (r *redisAuthRepo) SaveRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	ttl := time.Until(token.ExpiresAt)
+	if ttl <= 0 {
+		return port.ErrRefreshTokenNotFound
+	}
+
+	tokenBytes, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return saveRefreshTokenScript.Run(ctx, r.client,
+		[]string{
+			refreshTokenKeyPrefix + hashToken(token.Token),
+			refreshFamilyKeyPrefix + token.FamilyID,
+			refreshFamiliesByUserIdKeyPrefix + token.UserID,
+		},
+		tokenBytes,
+		ttl.Milliseconds(),
+		token.FamilyID,
+		token.ExpiresAt.Unix(),
+		time.Now().Unix(),
+	).Err()
+}
+
+func (r *redisAuthRepo) UseRefreshToken(ctx context.Context, token string) (*domain.RefreshToken, bool, error) {
+	result, err := useRefreshTokenScript.Run(ctx, r.client,
+		[]string{refreshTokenKeyPrefix + hashToken(token)},
+		time.Now().Format(time.RFC3339Nano),
+		sessionByUserIdKeyPrefix, sessionKeyPrefix, revokedSessionKeyPrefix,
+	).Slice()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, port.ErrRefreshTokenNotFound
+		}
+		return nil, false, err
+	}
+
+	first, _ := result[0].(int64)
+	data, _ := result[1].(string)
+
+	refresh := &domain.RefreshToken{}
+	if err := json.Unmarshal([]byte(data), refresh); err != nil {
+		return nil, false, err
+	}
+	refresh.Token = token
+
+	return refresh, first == 1, nil
+}
+
+func (r *redisAuthRepo) FindRefreshToken(ctx context.Context, token string) (*domain.RefreshToken, error) {
+	data, err := r.client.Get(ctx, refreshTokenKeyPrefix+hashToken(token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, port.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+
+	refresh := &domain.RefreshToken{}
+	if err := json.Unmarshal([]byte(data), refresh); err != nil {
+		return nil, err
+	}
+	refresh.Token = token
+
+	return refresh, nil
+}
+
+func (r *redisAuthRepo) RevokeRefreshFamily(ctx context.Context, userid string, familyid string) error {
+	return revokeRefreshFamilyScript.Run(ctx, r.client,
+		[]string{
+			sessionByUserIdKeyPrefix + userid,
+			refreshFamiliesByUserIdKeyPrefix + userid,
+		},
+		refreshFamilyKeyPrefix, familyid, sessionKeyPrefix, revokedSessionKeyPrefix,
+	).Err()
+}
+
+// SaveEvent appends the event to the user's audit trail, keeping only the
+// most recent entries.
+func (r *redisAuthRepo) SaveEvent(ctx context.Context, event domain.SecurityEvent) error {
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	eventsKey := eventsByUserIdKeyPrefix + event.UserID
+
+	pipe := r.client.TxPipeline()
+	pipe.LPush(ctx, eventsKey, eventBytes)
+	pipe.LTrim(ctx, eventsKey, 0, maxEventsPerUser-1)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,49 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+type fileSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+type fileMessage struct {
+	To      string    `json:"to"`
+	Message string    `json:"message"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+func (f *fileSender) SendSMS(ctx context.Context, to string, message string) error {
+	line, err := json.Marshal(fileMessage{To: to, Message: message, SentAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// NewFileSender appends text messages to a JSON Lines file instead of
+// sending them, so tests and local setups can read codes back.
+func NewFileSender(path string) port.SMSSender {
+	return &fileSender{path: path}
+}
@@ -0,0 +1,21 @@
+package sms
+
+import (
+	"context"
+	"log"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+type logSender struct{}
+
+func (logSender) SendSMS(ctx context.Context, to string, message string) error {
+	log.Printf("SMS to %s: %s", to, message)
+	return nil
+}
+
+// NewLogSender writes text messages to the log instead of sending them. It
+// is meant for local development only: codes end up in plain text in logs.
+func NewLogSender() port.SMSSender {
+	return logSender{}
+}
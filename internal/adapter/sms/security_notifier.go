@@ -0,0 +1,34 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+type securityNotifier struct {
+	sender port.SMSSender
+}
+
+func (n *securityNotifier) NotifySecurityEvent(ctx context.Context, event domain.SecurityEvent) error {
+	if event.Type != domain.EventAccountLocked {
+		return nil
+	}
+
+	// The service withholds the number once its send limits are reached
+	to := event.Phonenumber
+	if to == "" {
+		return nil
+	}
+	message := fmt.Sprintf("Sign in to your account was locked after %s failed attempts. If this was not you, reset your password.",
+		event.Details["failures"])
+	return n.sender.SendSMS(ctx, to, message)
+}
+
+// NewSecurityNotifier texts users when their account is locked out after
+// failed sign ins, so they learn someone is guessing their password.
+func NewSecurityNotifier(sender port.SMSSender) port.SecurityNotifier {
+	return &securityNotifier{sender: sender}
+}
@@ -5,20 +5,242 @@ import (
 )
 
 type User struct {
-	ID          string `json:"id"`
-	Phonenumber string `json:"phonenumber"`
-	Password    string `json:"password,omitempty"`
+	ID          string   `json:"id"`
+	Phonenumber string   `json:"phonenumber"`
+	Password    string   `json:"password,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	// PhoneVerifiedAt is when the user proved they own Phonenumber.
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty"`
 }
 
 type Session struct {
-	ID        string    `json:"id"`
-	Token     string    `json:"token"`
+	ID string `json:"id"`
+	// Token is the bearer credential handed to the client. Only its hash is
+	// ever persisted.
+	Token     string    `json:"-"`
 	UserID    string    `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	// SignedInAt is when the user signed in. Sessions that replace it on
+	// refresh keep it, so MaxLifetime counts from the sign in.
+	SignedInAt time.Time `json:"signed_in_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Device     Device    `json:"device"`
+	// FamilyID links the session to the refresh tokens issued with it.
+	FamilyID string `json:"family_id,omitempty"`
+	// RefreshToken and AccessToken are only set on sessions fresh from
+	// CreateSession or RefreshSession and are never persisted with the
+	// session. AccessToken is a signed JWT for services that cannot reach
+	// Redis.
+	RefreshToken string `json:"-"`
+	AccessToken  string `json:"-"`
 }
 
+// Device describes the client a session was created from.
+type Device struct {
+	UserAgent string `json:"user_agent,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+}
+
+// Credentials sign a user in with a password or, where enabled, with a
+// one-time code texted to them; with neither, a code is requested.
 type Credentials struct {
 	Phonenumber string `json:"phonenumber" form:"phonenumber" binding:"required"`
 	Password    string `json:"password" form:"password"`
+	Code        string `json:"code,omitempty" form:"code"`
+}
+
+// PasswordChange is a signed-in user's request to change their password.
+type PasswordChange struct {
+	UserID          string
+	CurrentPassword string
+	NewPassword     string
+	// SignOutOthers revokes every other session of the user; the session
+	// with KeepToken survives.
+	SignOutOthers bool
+	KeepToken     string
+}
+
+// PasswordViolation is one reason a password was rejected, with a code for
+// clients and a message for people.
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// RefreshToken is a single-use credential exchanged for a new session. Every
+// token rotated out of one login shares the same FamilyID.
+type RefreshToken struct {
+	// Token is only known to the client; only its hash is ever persisted.
+	Token     string    `json:"-"`
+	FamilyID  string    `json:"family_id"`
+	UserID    string    `json:"user_id"`
+	SessionID string    `json:"session_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// SignedInAt is when the family started, carried through rotation.
+	SignedInAt time.Time  `json:"signed_in_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+}
+
+const (
+	EventRefreshTokenReuse = "refresh_token_reuse"
+	EventPasswordReset     = "password_reset"
+	EventPasswordChanged   = "password_changed"
+	EventAccountLocked     = "account_locked"
+	EventAccountUnlocked   = "account_unlocked"
+)
+
+// SecurityEvent is an entry in a user's security audit trail.
+type SecurityEvent struct {
+	Type       string            `json:"type"`
+	UserID     string            `json:"user_id"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Details    map[string]string `json:"details,omitempty"`
+	// Phonenumber is where notifiers text the user about the event. It is
+	// kept out of the audit trail and the logs.
+	Phonenumber string `json:"-"`
+}
+
+// LoginFailures counts recent failed password sign ins with a phone number.
+type LoginFailures struct {
+	Count         int
+	LastFailureAt time.Time
+}
+
+// LockoutStatus tells whether sign ins with a phone number are held back
+// after failed attempts. RetryAt is when the next attempt is allowed, zero
+// when it already is.
+type LockoutStatus struct {
+	Phonenumber   string
+	Failures      int
+	LastFailureAt time.Time
+	RetryAt       time.Time
+	Locked        bool
+}
+
+// TokenClaims is the payload of the JWTs issued by the service, both access
+// tokens and OpenID Connect ID tokens.
+type TokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  string   `json:"aud,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+
+	// ID token claims
+	AuthTime    int64  `json:"auth_time,omitempty"`
+	Nonce       string `json:"nonce,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+}
+
+// JSONWebKey is a public signing key in JWK form (RFC 7517).
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// SigningKey is an Ed25519 key used to sign JWTs. A key signs between
+// ActivatesAt and RetiresAt and is published for verification until
+// ExpiresAt, unless it is revoked first.
+type SigningKey struct {
+	ID          string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	PrivateKey  []byte     `json:"private_key"`
+	PublicKey   []byte     `json:"public_key"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiresAt   time.Time  `json:"retires_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// PhoneMigration reports what rewriting stored phone numbers to E.164 did,
+// or would do on a dry run.
+type PhoneMigration struct {
+	Scanned    int
+	Unchanged  int
+	Rewritten  []PhoneChange
+	Invalid    []PhoneChange
+	Collisions []PhoneCollision
+}
+
+// PhoneChange is a user's phone number before and after normalization. To is
+// empty for numbers that cannot be normalized.
+type PhoneChange struct {
+	UserID string
+	From   string
+	To     string
+}
+
+// PhoneCollision lists accounts whose numbers normalize to the same E.164
+// number, or an account and the user ID the phone index already maps its
+// number to. They have to be merged or fixed by hand.
+type PhoneCollision struct {
+	Phonenumber string
+	UserIDs     []string
+}
+
+// ImportedUser is an account brought over from another system, with its
+// password hash as that system stored it.
+type ImportedUser struct {
+	Phonenumber   string   `json:"phonenumber"`
+	PasswordHash  string   `json:"password_hash"`
+	Roles         []string `json:"roles"`
+	PhoneVerified bool     `json:"phone_verified"`
+}
+
+// UserImport reports what importing users did, or would do on a dry run.
+// Rejections refer to users by their position in the import.
+type UserImport struct {
+	Imported []ImportedAccount
+	Existing []ImportRejection
+	Rejected []ImportRejection
+}
+
+// ImportedAccount is the ID a user was created with.
+type ImportedAccount struct {
+	Index       int
+	Phonenumber string
+	UserID      string
+}
+
+// ImportRejection is a user left out of an import, and why.
+type ImportRejection struct {
+	Index       int
+	Phonenumber string
+	Reason      string
+}
+
+// Purposes a one-time code can be issued for.
+const (
+	CodePurposeVerifyPhone = "verify_phone"
+	CodePurposeLogin       = "login"
+	CodePurposeReset       = "reset_password"
+)
+
+// OneTimeCode is a short code sent by SMS to prove control of a phone
+// number. Each purpose and subject has at most one outstanding code.
+type OneTimeCode struct {
+	// Code is only sent to the user; only its hash is ever persisted.
+	Code        string    `json:"-"`
+	CodeHash    string    `json:"code_hash"`
+	Purpose     string    `json:"purpose"`
+	Subject     string    `json:"subject"`
+	UserID      string    `json:"user_id,omitempty"`
+	Phonenumber string    `json:"phonenumber"`
+	Attempts    int       `json:"attempts"`
+	ExpiresAt   time.Time `json:"expires_at"`
 }
@@ -0,0 +1,122 @@
+package domain
+
+import "time"
+
+// OAuth 2.0 grant types a client can be registered for.
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+)
+
+// Client is an application registered to sign users in through the
+// service, or a backend job that gets tokens of its own.
+type Client struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// SecretHash is the Argon2id hash of the client secret. Public clients
+	// have none and rely on PKCE instead.
+	SecretHash   string   `json:"secret_hash,omitempty"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// GrantTypes the client may use. Clients registered before grant types
+	// were recorded use the authorization code grant.
+	GrantTypes []string `json:"grant_types,omitempty"`
+	// Scopes the client may request with the client credentials grant.
+	Scopes    []string  `json:"scopes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ClientRegistration describes a client to register.
+type ClientRegistration struct {
+	Name         string
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+}
+
+// AuthorizationRequest is a request to /authorize (RFC 6749 section 4.1.1,
+// with PKCE from RFC 7636).
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	Prompt              string `form:"prompt"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+}
+
+// AuthorizationCode is the single-use grant handed to a client at the end of
+// /authorize and redeemed at /token.
+type AuthorizationCode struct {
+	// Code is only known to the client; only its hash is ever persisted.
+	Code                string    `json:"-"`
+	ClientID            string    `json:"client_id"`
+	RedirectURI         string    `json:"redirect_uri"`
+	UserID              string    `json:"user_id"`
+	SessionID           string    `json:"session_id"`
+	SessionExpiresAt    time.Time `json:"session_expires_at"`
+	AuthTime            time.Time `json:"auth_time"`
+	Scope               string    `json:"scope"`
+	Nonce               string    `json:"nonce,omitempty"`
+	CodeChallenge       string    `json:"code_challenge"`
+	CodeChallengeMethod string    `json:"code_challenge_method"`
+	ExpiresAt           time.Time `json:"expires_at"`
+}
+
+// TokenRequest is a request to /token (RFC 6749 sections 4.1.3 and 4.4.2).
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	CodeVerifier string `form:"code_verifier"`
+	Scope        string `form:"scope"`
+}
+
+// TokenResponse is a successful /token response (RFC 6749 section 5.1).
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	IDToken     string `json:"id_token,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// UserInfo is the /userinfo response (OpenID Connect Core section 5.3).
+type UserInfo struct {
+	Subject     string `json:"sub"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+}
+
+// IntrospectionRequest is a request to /oauth/introspect (RFC 7662 section
+// 2.1).
+type IntrospectionRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// Introspection describes a token to the resource server that asked about it
+// (RFC 7662 section 2.2). Inactive tokens carry no other information.
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Subject   string `json:"sub,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+}
+
+// RevocationRequest is a request to /oauth/revoke (RFC 7009 section 2.1).
+type RevocationRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
@@ -3,15 +3,26 @@ package port
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mar-cial/space-auth/internal/core/domain"
 )
 
 var (
-	ErrUserNotFound    = errors.New("user not found")
-	ErrSessionNotFound = errors.New("session not found")
-	ErrSessionExpired  = errors.New("session expired")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidPhoneNumber = errors.New("invalid phone number")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionExpired     = errors.New("session expired")
+
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenRevoked = errors.New("token revoked")
+	ErrKeyNotFound  = errors.New("signing key not found")
+	ErrKeyStoreBusy = errors.New("signing keys are being rotated elsewhere")
 )
 
 // auth core
@@ -19,16 +30,41 @@ type AuthHandler interface {
 	Register(ctx *gin.Context)
 	Login(ctx *gin.Context)
 	Logout(ctx *gin.Context)
+	LogoutAll(ctx *gin.Context)
+	ListSessions(ctx *gin.Context)
+	RevokeSession(ctx *gin.Context)
+	RefreshToken(ctx *gin.Context)
+	Verify(ctx *gin.Context)
+	JWKS(ctx *gin.Context)
+	OAuthHandler
+	VerificationHandler
+	PasswordResetHandler
 }
 
 type AuthService interface {
 	UserService
 	SessionService
+	TokenService
+	OAuthService
+	ClientService
+	PhoneMigrationService
+	UserImportService
+	LockoutService
+	VerificationService
+	OTPLoginService
+	PasswordResetService
 }
 
 type AuthRepository interface {
 	UserRepository
 	SessionRepository
+	RefreshTokenRepository
+	EventRepository
+	ClientRepository
+	AuthorizationCodeRepository
+	OneTimeCodeRepository
+	RateLimitRepository
+	LoginFailureRepository
 }
 
 // service layer
@@ -37,14 +73,58 @@ type UserService interface {
 	ValidateUser(ctx context.Context, creds domain.Credentials) (bool, error)
 	ReadUserById(ctx context.Context, id string) (*domain.User, error)
 	ReadUserByPhone(ctx context.Context, phonenumber string) (*domain.User, error)
+	// UpdateUser changes the user's profile. It never touches the password,
+	// which only ChangePassword and ResetPassword set.
 	UpdateUser(ctx context.Context, user domain.User) (*domain.User, error)
 	DeleteUser(ctx context.Context, id string) error
+	// ChangePassword sets a new password after checking the current one. It
+	// returns how many other sessions were signed out.
+	ChangePassword(ctx context.Context, change domain.PasswordChange) (int, error)
+}
+
+type PhoneMigrationService interface {
+	// MigratePhoneNumbers rewrites stored phone numbers to E.164. Numbers
+	// that would collide with another account, or cannot be normalized, are
+	// reported and left alone. With dryRun nothing is written.
+	MigratePhoneNumbers(ctx context.Context, dryRun bool) (*domain.PhoneMigration, error)
+}
+
+type UserImportService interface {
+	// ImportUsers creates accounts brought over from other systems, keeping
+	// their password hashes, which are upgraded as the users sign in. Users
+	// whose phone number is already registered, or appears earlier in the
+	// import, are skipped. With dryRun nothing is written.
+	ImportUsers(ctx context.Context, users []domain.ImportedUser, dryRun bool) (*domain.UserImport, error)
 }
 
 type SessionService interface {
-	CreateSession(ctx context.Context, userid string) (*domain.Session, error)
+	CreateSession(ctx context.Context, userid string, device domain.Device) (*domain.Session, error)
 	ReadSession(ctx context.Context, token string) (*domain.Session, error)
+	// RefreshSession exchanges a refresh token for a new session and a new
+	// refresh token. Presenting an already used token revokes its family.
+	RefreshSession(ctx context.Context, refreshToken string, device domain.Device) (*domain.Session, error)
+	ListSessions(ctx context.Context, userid string) ([]domain.Session, error)
 	DeleteSession(ctx context.Context, token string) error
+	RevokeAllSessions(ctx context.Context, userid string, exceptToken string) (int, error)
+	RevokeSessionByID(ctx context.Context, userid string, sessionid string) error
+	PruneSessions(ctx context.Context) (int, error)
+}
+
+type TokenService interface {
+	// VerifyAccessToken checks the signature and expiry of an access token
+	// JWT and that its session has not been revoked.
+	VerifyAccessToken(ctx context.Context, token string) (*domain.TokenClaims, error)
+	// PublicKeys returns the keys access tokens can be verified with.
+	PublicKeys(ctx context.Context) (*domain.JSONWebKeySet, error)
+}
+
+// KeyService manages the keys JWTs are signed with.
+type KeyService interface {
+	ListKeys(ctx context.Context) ([]domain.SigningKey, error)
+	// ForceRotate retires the active key now in favour of a new one.
+	ForceRotate(ctx context.Context) (*domain.SigningKey, error)
+	// Revoke withdraws a compromised key from signing and verification.
+	Revoke(ctx context.Context, kid string) error
 }
 
 // repo layer
@@ -52,12 +132,66 @@ type UserRepository interface {
 	SaveUser(ctx context.Context, user domain.User) (string, error)
 	ReadUserByID(ctx context.Context, id string) (*domain.User, error)
 	ReadUserByPhone(ctx context.Context, phone string) (*domain.User, error)
+	// PhoneOwner returns the user ID the phone index maps phone to, even
+	// when no such user exists any more, or "" for numbers not indexed.
+	PhoneOwner(ctx context.Context, phone string) (string, error)
 	UpdateUser(ctx context.Context, user domain.User) (*domain.User, error)
+	// UpdatePasswordHash replaces the user's password hash only if it is
+	// still oldHash, reporting whether it did.
+	UpdatePasswordHash(ctx context.Context, userID string, oldHash string, newHash string) (bool, error)
 	DeleteUser(ctx context.Context, user domain.User) error
+	ListUsers(ctx context.Context) ([]domain.User, error)
 }
 
 type SessionRepository interface {
 	SaveSession(ctx context.Context, session domain.Session, userid string) (string, error)
 	FindSessionByToken(ctx context.Context, token string) (*domain.Session, error)
+	// ExtendSession moves the expiry of an existing session, returning
+	// ErrSessionNotFound if it was deleted in the meantime.
+	ExtendSession(ctx context.Context, session domain.Session) error
+	ListSessions(ctx context.Context, userid string) ([]domain.Session, error)
 	DeleteSession(ctx context.Context, token string) error
+	// RevokeAllSessions atomically deletes every session of the user except
+	// the one identified by exceptToken, returning how many were removed.
+	RevokeAllSessions(ctx context.Context, userid string, exceptToken string) (int, error)
+	// RevokeSessionByID atomically deletes one session of the user, returning
+	// ErrSessionNotFound if the user has no session with that ID.
+	RevokeSessionByID(ctx context.Context, userid string, sessionid string) error
+	// PruneSessions removes index entries pointing at sessions that have
+	// expired or no longer exist, returning how many were removed.
+	PruneSessions(ctx context.Context) (int, error)
+	// IsSessionRevoked reports whether the session was revoked before it
+	// expired.
+	IsSessionRevoked(ctx context.Context, sessionid string) (bool, error)
+}
+
+type RefreshTokenRepository interface {
+	SaveRefreshToken(ctx context.Context, token domain.RefreshToken) error
+	// UseRefreshToken marks the token as used and returns it as it was
+	// before. first is false when the token had already been used. On first
+	// use the session the token was issued with is revoked, as the caller
+	// replaces it.
+	UseRefreshToken(ctx context.Context, token string) (refresh *domain.RefreshToken, first bool, err error)
+	// FindRefreshToken looks a refresh token up without using it.
+	FindRefreshToken(ctx context.Context, token string) (*domain.RefreshToken, error)
+	// RevokeRefreshFamily deletes every refresh token of the family together
+	// with the sessions issued from it.
+	RevokeRefreshFamily(ctx context.Context, userid string, familyid string) error
+}
+
+type EventRepository interface {
+	SaveEvent(ctx context.Context, event domain.SecurityEvent) error
+}
+
+// KeyStore persists the keys JWTs are signed with.
+type KeyStore interface {
+	SaveKey(ctx context.Context, key domain.SigningKey) error
+	ListKeys(ctx context.Context) ([]domain.SigningKey, error)
+	DeleteKey(ctx context.Context, kid string) error
+	// LockRotation takes the lock key rotation runs under, so processes
+	// sharing the store do not rotate at once. acquired is false while
+	// another holder has it. The lock lapses after ttl unless released
+	// with UnlockRotation and the returned token.
+	LockRotation(ctx context.Context, ttl time.Duration) (token string, acquired bool, err error)
+	UnlockRotation(ctx context.Context, token string) error
 }
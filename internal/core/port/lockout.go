@@ -0,0 +1,58 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+var ErrAccountLocked = errors.New("too many failed sign in attempts")
+
+// AccountLockedError is returned when a sign in is attempted before the
+// delay earned by earlier failures has passed. It matches ErrAccountLocked.
+type AccountLockedError struct {
+	RetryAfter time.Duration
+	// Locked is set when the account is locked out rather than slowed down.
+	Locked bool
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrAccountLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *AccountLockedError) Is(target error) bool {
+	return target == ErrAccountLocked
+}
+
+// LoginFailureRepository counts failed sign ins by phone number, whether or
+// not the number is registered.
+type LoginFailureRepository interface {
+	// ReserveLoginAttempt counts a sign in as failed, at the given time,
+	// before its password is checked, so concurrent attempts cannot all pass
+	// the same lockout check. It counts only while the failures still number
+	// expected, the count the attempt was checked against, and otherwise
+	// returns nil. The count is forgotten after ttl without another attempt.
+	ReserveLoginAttempt(ctx context.Context, phonenumber string, expected int, at time.Time, ttl time.Duration) (*domain.LoginFailures, error)
+	// ReleaseLoginAttempt takes back a reserved attempt whose password could
+	// not be checked.
+	ReleaseLoginAttempt(ctx context.Context, phonenumber string) error
+	// ReadLoginFailures returns a zero count when there is none.
+	ReadLoginFailures(ctx context.Context, phonenumber string) (*domain.LoginFailures, error)
+	ClearLoginFailures(ctx context.Context, phonenumber string) (bool, error)
+}
+
+type LockoutService interface {
+	LockoutStatus(ctx context.Context, phonenumber string) (*domain.LockoutStatus, error)
+	// UnlockAccount forgets the failed sign ins with a phone number. It
+	// reports whether there were any.
+	UnlockAccount(ctx context.Context, phonenumber string) (bool, error)
+}
+
+// SecurityNotifier is told about security events as they are recorded, to
+// alert users or operators.
+type SecurityNotifier interface {
+	NotifySecurityEvent(ctx context.Context, event domain.SecurityEvent) error
+}
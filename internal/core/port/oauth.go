@@ -0,0 +1,92 @@
+package port
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+var (
+	ErrClientNotFound            = errors.New("client not found")
+	ErrInvalidRedirectURI        = errors.New("invalid redirect uri")
+	ErrAuthorizationCodeNotFound = errors.New("authorization code not found")
+)
+
+// Error codes from RFC 6749 sections 4.1.2.1 and 5.2 and OpenID Connect Core
+// section 3.1.2.6.
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthLoginRequired           = "login_required"
+	OAuthUnsupportedTokenType    = "unsupported_token_type"
+)
+
+// OAuthError is an error the client is told about in an OAuth 2.0 error
+// response.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// OpenID Connect provider
+type OAuthHandler interface {
+	Discovery(ctx *gin.Context)
+	Authorize(ctx *gin.Context)
+	Token(ctx *gin.Context)
+	UserInfo(ctx *gin.Context)
+	Introspect(ctx *gin.Context)
+	Revoke(ctx *gin.Context)
+}
+
+type OAuthService interface {
+	// ValidateAuthorizationRequest checks the client and redirect URI of an
+	// authorization request. Until it passes, errors must not be sent to the
+	// redirect URI.
+	ValidateAuthorizationRequest(ctx context.Context, req domain.AuthorizationRequest) (*domain.Client, error)
+	// Authorize issues an authorization code to the client for the user of
+	// session. Failures are *OAuthError to be sent to the redirect URI.
+	Authorize(ctx context.Context, req domain.AuthorizationRequest, session *domain.Session) (*domain.AuthorizationCode, error)
+	// ExchangeToken serves the token endpoint. Failures are *OAuthError.
+	ExchangeToken(ctx context.Context, req domain.TokenRequest) (*domain.TokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (*domain.UserInfo, error)
+	// IntrospectToken describes a session token, refresh token or access
+	// token JWT to an authenticated confidential client. Failures are
+	// *OAuthError.
+	IntrospectToken(ctx context.Context, req domain.IntrospectionRequest) (*domain.Introspection, error)
+	// RevokeToken invalidates a session token, refresh token or access token
+	// JWT. Unknown tokens are not an error. Failures are *OAuthError.
+	RevokeToken(ctx context.Context, req domain.RevocationRequest) error
+}
+
+type ClientService interface {
+	// RegisterClient returns the new client and, for confidential clients,
+	// its secret. Only a hash of the secret is kept.
+	RegisterClient(ctx context.Context, registration domain.ClientRegistration) (*domain.Client, string, error)
+	ListClients(ctx context.Context) ([]domain.Client, error)
+	DeleteClient(ctx context.Context, id string) error
+}
+
+type ClientRepository interface {
+	SaveClient(ctx context.Context, client domain.Client) error
+	ReadClient(ctx context.Context, id string) (*domain.Client, error)
+	ListClients(ctx context.Context) ([]domain.Client, error)
+	DeleteClient(ctx context.Context, id string) error
+}
+
+type AuthorizationCodeRepository interface {
+	SaveAuthorizationCode(ctx context.Context, code domain.AuthorizationCode) error
+	// ConsumeAuthorizationCode returns the code and deletes it, so each code
+	// is redeemed at most once.
+	ConsumeAuthorizationCode(ctx context.Context, code string) (*domain.AuthorizationCode, error)
+}
@@ -0,0 +1,16 @@
+package port
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrHashingBusy is returned when too many passwords are being hashed at
+// once to queue another one. Retrying shortly may succeed.
+var ErrHashingBusy = errors.New("password hashing is busy")
+
+// BreachedPasswordChecker tells whether a password is known from data
+// breaches, and so among the first ones attackers try.
+type BreachedPasswordChecker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
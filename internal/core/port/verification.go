@@ -0,0 +1,116 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+var (
+	ErrOneTimeCodeNotFound  = errors.New("one-time code not found")
+	ErrInvalidCode          = errors.New("invalid or expired code")
+	ErrTooManyAttempts      = errors.New("too many attempts")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrPhoneAlreadyVerified = errors.New("phone number already verified")
+	ErrLoginMethodDisabled  = errors.New("login method disabled")
+	ErrWeakPassword         = errors.New("password does not meet the requirements")
+)
+
+// RateLimitError is returned when something was attempted too often. It
+// matches ErrRateLimited.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// PasswordPolicyError lists every way a password falls short of the
+// password policy. It matches ErrWeakPassword.
+type PasswordPolicyError struct {
+	Violations []domain.PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return ErrWeakPassword.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// SMSSender delivers text messages to phone numbers in E.164 form.
+type SMSSender interface {
+	SendSMS(ctx context.Context, to string, message string) error
+}
+
+// Phone verification
+type VerificationHandler interface {
+	StartPhoneVerification(ctx *gin.Context)
+	ConfirmPhoneVerification(ctx *gin.Context)
+}
+
+type VerificationService interface {
+	// StartPhoneVerification texts the user a code and returns when it
+	// expires.
+	StartPhoneVerification(ctx context.Context, userid string) (time.Time, error)
+	ConfirmPhoneVerification(ctx context.Context, userid string, code string) error
+}
+
+// Passwordless login
+type OTPLoginService interface {
+	// StartOTPLogin texts a login code to the account registered with the
+	// phone number. It answers the same whether or not there is one.
+	StartOTPLogin(ctx context.Context, phonenumber string) (time.Time, error)
+	// ValidateOTPLogin returns the user a login code was texted to.
+	ValidateOTPLogin(ctx context.Context, phonenumber string, code string) (*domain.User, error)
+}
+
+// Password recovery
+type PasswordResetHandler interface {
+	ForgotPassword(ctx *gin.Context)
+	ResetPassword(ctx *gin.Context)
+	ChangePassword(ctx *gin.Context)
+}
+
+type PasswordResetService interface {
+	// StartPasswordReset texts a reset code to the account registered with
+	// the phone number. It answers the same whether or not there is one.
+	StartPasswordReset(ctx context.Context, phonenumber string) (time.Time, error)
+	// ResetPassword sets a new password with a reset code and signs the user
+	// out everywhere.
+	ResetPassword(ctx context.Context, phonenumber string, code string, password string) error
+}
+
+type OneTimeCodeRepository interface {
+	// SaveOneTimeCode stores a code, replacing any outstanding code of the
+	// same purpose and subject.
+	SaveOneTimeCode(ctx context.Context, code domain.OneTimeCode) error
+	// AttemptOneTimeCode counts an attempt at a code and returns it with the
+	// attempt included.
+	AttemptOneTimeCode(ctx context.Context, purpose string, subject string) (*domain.OneTimeCode, error)
+	// DeleteOneTimeCode removes a code and reports whether it was still
+	// there, so a code is only ever redeemed once.
+	DeleteOneTimeCode(ctx context.Context, purpose string, subject string) (bool, error)
+}
+
+type RateLimitRepository interface {
+	// HitRateLimit counts a hit against key in a fixed window starting at the
+	// first hit, returning the hits so far and the time until the window
+	// resets.
+	HitRateLimit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+}
@@ -11,7 +11,19 @@ import (
 )
 
 type authService struct {
-	authRepo port.AuthRepository
+	authRepo       port.AuthRepository
+	sessionPolicy  SessionPolicy
+	tokens         *TokenIssuer
+	phones         *PhoneNormalizer
+	sms            port.SMSSender
+	codePolicy     OneTimeCodePolicy
+	loginMode      LoginMode
+	passwordPolicy PasswordPolicy
+	// breachedPasswords, when set, rejects passwords known from breaches
+	breachedPasswords port.BreachedPasswordChecker
+	hasher            *passwordHasher
+	lockout           LockoutPolicy
+	notifiers         []port.SecurityNotifier
 }
 
 var (
@@ -21,8 +33,22 @@ var (
 
 // CreateUser with Argon2id password hashing
 func (a *authService) CreateUser(ctx context.Context, creds domain.Credentials) (*domain.User, error) {
+	phonenumber, err := a.phones.Normalize(creds.Phonenumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users of deployments with one-time code login may go without a
+	// password
+	hasPassword := creds.Password != "" || a.loginMode.allowsPassword()
+	if hasPassword {
+		if err := a.validateNewPassword(ctx, creds.Password, phonenumber); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check for existing user
-	foundUser, err := a.authRepo.ReadUserByPhone(ctx, creds.Phonenumber)
+	foundUser, err := a.authRepo.ReadUserByPhone(ctx, phonenumber)
 	if err != nil {
 		return nil, err
 	}
@@ -31,16 +57,18 @@ func (a *authService) CreateUser(ctx context.Context, creds domain.Credentials)
 		return nil, ErrUserExists
 	}
 
-	// Generate password hash
-	encodedHash, err := generateFromPassword(creds.Password, defaultArgon2Params())
-	if err != nil {
-		return nil, fmt.Errorf("password hashing failed: %w", err)
+	var encodedHash string
+	if hasPassword {
+		encodedHash, err = a.hasher.hash(ctx, creds.Password)
+		if err != nil {
+			return nil, fmt.Errorf("password hashing failed: %w", err)
+		}
 	}
 
 	// Create domain user
 	user := &domain.User{
 		ID:          generateUniqueID(),
-		Phonenumber: creds.Phonenumber,
+		Phonenumber: phonenumber,
 		Password:    encodedHash,
 	}
 
@@ -54,20 +82,48 @@ func (a *authService) CreateUser(ctx context.Context, creds domain.Credentials)
 
 // ValidateUser credentials with Argon2id
 func (a *authService) ValidateUser(ctx context.Context, creds domain.Credentials) (bool, error) {
-	user, err := a.authRepo.ReadUserByPhone(ctx, creds.Phonenumber)
+	if !a.loginMode.allowsPassword() {
+		return false, port.ErrLoginMethodDisabled
+	}
+
+	phonenumber := a.lookupPhone(creds.Phonenumber)
+
+	// Attempts held back by earlier failures are turned away before any
+	// hashing. The rest count as failures until the password matches, so
+	// a burst of concurrent guesses cannot get past the threshold.
+	failures, err := a.reserveLoginAttempt(ctx, phonenumber)
 	if err != nil {
-		if errors.Is(err, port.ErrUserNotFound) {
-			return false, nil
-		}
+		return false, err
+	}
+
+	user, err := a.authRepo.ReadUserByPhone(ctx, phonenumber)
+	if err != nil && !errors.Is(err, port.ErrUserNotFound) {
+		a.releaseLoginAttempt(ctx, phonenumber)
 		return false, fmt.Errorf("validation failed: %w", err)
 	}
 
-	match, err := comparePasswordAndHash(creds.Password, user.Password)
+	// Unknown numbers count failures like known ones, so lockouts do not
+	// reveal which numbers are registered
+	if user == nil || user.Password == "" {
+		return false, nil
+	}
+
+	match, rehash, err := a.hasher.verify(ctx, creds.Password, user.Password)
 	if err != nil {
+		a.releaseLoginAttempt(ctx, phonenumber)
 		return false, fmt.Errorf("password comparison failed: %w", err)
 	}
+	if !match {
+		a.recordLockout(ctx, failures, user)
+		return false, nil
+	}
 
-	return match, nil
+	if rehash {
+		a.upgradePasswordHash(ctx, user, creds.Password)
+	}
+	a.clearLoginFailures(ctx, phonenumber)
+
+	return true, nil
 }
 
 // ReadUserById fetches user by ID
@@ -84,28 +140,43 @@ func (a *authService) ReadUserById(ctx context.Context, id string) (*domain.User
 
 // service/auth_service.go
 func (a *authService) ReadUserByPhone(ctx context.Context, phonenumber string) (*domain.User, error) {
-	user, err := a.authRepo.ReadUserByPhone(ctx, phonenumber)
+	user, err := a.authRepo.ReadUserByPhone(ctx, a.lookupPhone(phonenumber))
 	if err != nil {
 		if errors.Is(err, port.ErrUserNotFound) {
 			return nil, port.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to read user by phone: %w", err)
 	}
+	if user == nil {
+		return nil, port.ErrUserNotFound
+	}
 	return user, nil
 }
 
 func (a *authService) UpdateUser(ctx context.Context, user domain.User) (*domain.User, error) {
+	phonenumber, err := a.phones.Normalize(user.Phonenumber)
+	if err != nil {
+		return nil, err
+	}
+	user.Phonenumber = phonenumber
+
 	// Verify existing user
 	existingUser, err := a.authRepo.ReadUserByID(ctx, user.ID)
 	if err != nil {
 		return nil, fmt.Errorf("user verification failed: %w", err)
 	}
 
-	// Check phone number availability if changing
+	// Passwords only change through ChangePassword and ResetPassword
+	user.Password = existingUser.Password
+
+	// Check phone number availability if changing. A new number has to be
+	// verified again.
+	user.PhoneVerifiedAt = existingUser.PhoneVerifiedAt
 	if user.Phonenumber != existingUser.Phonenumber {
-		if _, err := a.authRepo.ReadUserByPhone(ctx, user.Phonenumber); err == nil {
+		if found, err := a.authRepo.ReadUserByPhone(ctx, user.Phonenumber); err == nil && found != nil {
 			return nil, ErrUserExists
 		}
+		user.PhoneVerifiedAt = nil
 	}
 
 	// Perform atomic update
@@ -132,18 +203,39 @@ func (a *authService) DeleteUser(ctx context.Context, id string) error {
 	return nil
 }
 
-func (a *authService) CreateSession(ctx context.Context, userid string) (*domain.Session, error) {
-	// Generate new session with 24h duration
-	session, err := generateSession(userid, 24)
+// CreateSession signs a user in, starting a new refresh token family.
+func (a *authService) CreateSession(ctx context.Context, userid string, device domain.Device) (*domain.Session, error) {
+	return a.startSession(ctx, userid, generateUniqueID(), time.Now(), device)
+}
+
+// startSession saves a new session in the given refresh token family, whose
+// user signed in at signedInAt, together with the refresh token that can
+// later replace it.
+func (a *authService) startSession(ctx context.Context, userid string, familyid string, signedInAt time.Time, device domain.Device) (*domain.Session, error) {
+	session, err := generateSession(userid, signedInAt, a.sessionPolicy)
 	if err != nil {
 		return nil, fmt.Errorf("session generation failed: %w", err)
 	}
+	session.Device = device
+	session.FamilyID = familyid
 
 	// Save to repository
 	if _, err := a.authRepo.SaveSession(ctx, *session, userid); err != nil {
 		return nil, fmt.Errorf("session persistence failed: %w", err)
 	}
 
+	if err := a.issueRefreshToken(ctx, session); err != nil {
+		return nil, fmt.Errorf("refresh token persistence failed: %w", err)
+	}
+
+	if a.tokens != nil {
+		accessToken, err := a.issueAccessToken(ctx, session)
+		if err != nil {
+			return nil, fmt.Errorf("access token signing failed: %w", err)
+		}
+		session.AccessToken = accessToken
+	}
+
 	return session, nil
 }
 
@@ -157,15 +249,49 @@ func (a *authService) ReadSession(ctx context.Context, token string) (*domain.Se
 	}
 
 	// Validate session expiration
-	if time.Now().After(session.ExpiresAt) {
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
 		// Auto-cleanup expired session
 		_ = a.authRepo.DeleteSession(ctx, token)
 		return nil, port.ErrSessionExpired
 	}
 
+	session.Token = token
+
+	// Slide the idle timeout forward, capped by the maximum lifetime
+	extended := a.sessionPolicy.expiry(sessionSignedInAt(session), now)
+	if extended.Sub(session.ExpiresAt) >= sessionExtensionGranularity {
+		session.ExpiresAt = extended
+		if err := a.authRepo.ExtendSession(ctx, *session); err != nil {
+			if errors.Is(err, port.ErrSessionNotFound) {
+				return nil, port.ErrSessionNotFound
+			}
+			return nil, fmt.Errorf("session extension failed: %w", err)
+		}
+	}
+
 	return session, nil
 }
 
+// ListSessions returns the unexpired sessions of a user, one per signed-in device.
+func (a *authService) ListSessions(ctx context.Context, userid string) ([]domain.Session, error) {
+	sessions, err := a.authRepo.ListSessions(ctx, userid)
+	if err != nil {
+		return nil, fmt.Errorf("session listing failed: %w", err)
+	}
+
+	now := time.Now()
+	active := make([]domain.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if now.After(session.ExpiresAt) {
+			continue
+		}
+		active = append(active, session)
+	}
+
+	return active, nil
+}
+
 func (a *authService) DeleteSession(ctx context.Context, token string) error {
 	if err := a.authRepo.DeleteSession(ctx, token); err != nil {
 		return fmt.Errorf("session deletion failed: %w", err)
@@ -173,6 +299,50 @@ func (a *authService) DeleteSession(ctx context.Context, token string) error {
 	return nil
 }
 
-func NewAuthService(ar port.AuthRepository) port.AuthService {
-	return &authService{authRepo: ar}
+// RevokeAllSessions signs the user out everywhere, optionally keeping the
+// session identified by exceptToken.
+func (a *authService) RevokeAllSessions(ctx context.Context, userid string, exceptToken string) (int, error) {
+	revoked, err := a.authRepo.RevokeAllSessions(ctx, userid, exceptToken)
+	if err != nil {
+		return 0, fmt.Errorf("session revocation failed: %w", err)
+	}
+	return revoked, nil
+}
+
+// RevokeSessionByID signs the user out of a single session.
+func (a *authService) RevokeSessionByID(ctx context.Context, userid string, sessionid string) error {
+	if err := a.authRepo.RevokeSessionByID(ctx, userid, sessionid); err != nil {
+		if errors.Is(err, port.ErrSessionNotFound) {
+			return port.ErrSessionNotFound
+		}
+		return fmt.Errorf("session revocation failed: %w", err)
+	}
+	return nil
+}
+
+// PruneSessions reaps per-user session index entries left behind by sessions
+// that expired in Redis.
+func (a *authService) PruneSessions(ctx context.Context) (int, error) {
+	pruned, err := a.authRepo.PruneSessions(ctx)
+	if err != nil {
+		return pruned, fmt.Errorf("session pruning failed: %w", err)
+	}
+	return pruned, nil
+}
+
+func NewAuthService(ar port.AuthRepository, opts ...Option) port.AuthService {
+	a := &authService{
+		authRepo:       ar,
+		sessionPolicy:  DefaultSessionPolicy(),
+		phones:         defaultPhoneNormalizer(),
+		codePolicy:     DefaultOneTimeCodePolicy(),
+		loginMode:      LoginModePassword,
+		passwordPolicy: DefaultPasswordPolicy(),
+		hasher:         newPasswordHasher(DefaultArgon2Params()),
+		lockout:        DefaultLockoutPolicy(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
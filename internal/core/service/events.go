@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// recordEvent appends to the user's security audit trail. Failing to record
+// an event is logged rather than failing the operation that raised it.
+// Notifiers get the event's phone number only while its send limits allow.
+func (a *authService) recordEvent(ctx context.Context, event domain.SecurityEvent) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	log.Printf("Security event %s for user %s: %v", event.Type, event.UserID, event.Details)
+
+	if err := a.authRepo.SaveEvent(ctx, event); err != nil {
+		log.Println("Failed to record security event:", err)
+	}
+	if len(a.notifiers) == 0 {
+		return
+	}
+
+	// Anyone can lock a number out, so texts about it count against the
+	// number's send limits like one-time codes do
+	if event.Phonenumber != "" {
+		if err := a.limitSends(ctx, event.Phonenumber); err != nil {
+			log.Printf("Not texting user %s about security event %s: %v", event.UserID, event.Type, err)
+			event.Phonenumber = ""
+		}
+	}
+
+	// Notifiers may be slow to reach, so they do not hold up the operation
+	for _, notifier := range a.notifiers {
+		go func(notifier port.SecurityNotifier) {
+			if err := notifier.NotifySecurityEvent(context.WithoutCancel(ctx), event); err != nil {
+				log.Printf("Failed to notify of security event %s: %v", event.Type, err)
+			}
+		}(notifier)
+	}
+}
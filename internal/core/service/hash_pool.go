@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// HashingPoolPolicy bounds how many password hashes are computed at once.
+// Every Argon2id hash allocates its whole memory parameter, 64 MiB by
+// default, so unbounded bursts of sign ins exhaust memory.
+type HashingPoolPolicy struct {
+	// Concurrency is how many hashes are computed at once.
+	Concurrency int
+	// QueueDepth is how many more may wait for a slot. Beyond it hashing
+	// fails at once with port.ErrHashingBusy.
+	QueueDepth int
+}
+
+// DefaultHashingPoolPolicy computes one hash per CPU and queues up to 64
+// more.
+func DefaultHashingPoolPolicy() HashingPoolPolicy {
+	return HashingPoolPolicy{
+		Concurrency: runtime.GOMAXPROCS(0),
+		QueueDepth:  64,
+	}
+}
+
+// hashingWaitBuckets are the upper bounds of the queue wait histogram.
+var hashingWaitBuckets = []time.Duration{
+	10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+	250 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+}
+
+// hashingMetrics are published under "password_hashing" in expvar, served
+// at /debug/vars.
+var hashingMetrics = func() *expvar.Map {
+	metrics := expvar.NewMap("password_hashing")
+	for _, name := range []string{"in_flight", "queued", "completed", "rejected", "cancelled", "wait_count"} {
+		metrics.Set(name, new(expvar.Int))
+	}
+	metrics.Set("wait_seconds_total", new(expvar.Float))
+
+	buckets := new(expvar.Map)
+	for _, bound := range hashingWaitBuckets {
+		buckets.Set(bound.String(), new(expvar.Int))
+	}
+	buckets.Set("+Inf", new(expvar.Int))
+	metrics.Set("wait_seconds_bucket", buckets)
+
+	return metrics
+}()
+
+// hashingPool is a semaphore in front of password hashing with a bounded
+// queue.
+type hashingPool struct {
+	slots      chan struct{}
+	waiting    atomic.Int64
+	queueDepth int64
+}
+
+func newHashingPool(policy HashingPoolPolicy) *hashingPool {
+	if policy.Concurrency < 1 {
+		policy.Concurrency = 1
+	}
+	return &hashingPool{
+		slots:      make(chan struct{}, policy.Concurrency),
+		queueDepth: int64(policy.QueueDepth),
+	}
+}
+
+// run calls fn once a slot is free. It gives up with port.ErrHashingBusy
+// when the queue is full, and with the context's error when ctx ends while
+// waiting.
+func (p *hashingPool) run(ctx context.Context, fn func()) error {
+	if err := p.acquire(ctx); err != nil {
+		return err
+	}
+	hashingMetrics.Add("in_flight", 1)
+	defer func() {
+		hashingMetrics.Add("in_flight", -1)
+		hashingMetrics.Add("completed", 1)
+		<-p.slots
+	}()
+
+	fn()
+	return nil
+}
+
+func (p *hashingPool) acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		observeHashingWait(0)
+		return nil
+	default:
+	}
+
+	if p.waiting.Add(1) > p.queueDepth {
+		p.waiting.Add(-1)
+		hashingMetrics.Add("rejected", 1)
+		return port.ErrHashingBusy
+	}
+	hashingMetrics.Add("queued", 1)
+	defer func() {
+		p.waiting.Add(-1)
+		hashingMetrics.Add("queued", -1)
+	}()
+
+	start := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+		observeHashingWait(time.Since(start))
+		return nil
+	case <-ctx.Done():
+		hashingMetrics.Add("cancelled", 1)
+		return ctx.Err()
+	}
+}
+
+func observeHashingWait(wait time.Duration) {
+	hashingMetrics.Add("wait_count", 1)
+	hashingMetrics.AddFloat("wait_seconds_total", wait.Seconds())
+
+	buckets := hashingMetrics.Get("wait_seconds_bucket").(*expvar.Map)
+	for _, bound := range hashingWaitBuckets {
+		if wait <= bound {
+			buckets.Add(bound.String(), 1)
+		}
+	}
+	buckets.Add("+Inf", 1)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestHashingPool(t *testing.T) {
+	pool := newHashingPool(HashingPoolPolicy{Concurrency: 1, QueueDepth: 1})
+
+	// Hold the only slot
+	release := make(chan struct{})
+	running := make(chan struct{})
+	go pool.run(context.Background(), func() {
+		close(running)
+		<-release
+	})
+	<-running
+
+	// Queue one waiter, which gives up when its context ends
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := make(chan error)
+	go func() {
+		queued <- pool.run(ctx, func() { t.Error("cancelled waiter ran") })
+	}()
+	for pool.waiting.Load() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queue is full
+	if err := pool.run(context.Background(), func() { t.Error("rejected caller ran") }); !errors.Is(err, port.ErrHashingBusy) {
+		t.Fatalf("run with a full queue = %v, want ErrHashingBusy", err)
+	}
+
+	cancel()
+	if err := <-queued; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled waiter = %v, want context.Canceled", err)
+	}
+
+	// Its place in the queue is free again
+	done := make(chan error)
+	go func() {
+		done <- pool.run(context.Background(), func() {})
+	}()
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("run after release = %v, want nil", err)
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// tokenHintRefreshToken is the token_type_hint of RFC 7009 section 2.1 for
+// refresh tokens. Any other hint looks at sessions first.
+const tokenHintRefreshToken = "refresh_token"
+
+// IntrospectToken tells a resource server whether a token is active and who
+// it belongs to. Only confidential clients may ask, so nobody can use the
+// endpoint to probe for valid tokens.
+func (a *authService) IntrospectToken(ctx context.Context, req domain.IntrospectionRequest) (*domain.Introspection, error) {
+	client, err := a.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if client.SecretHash == "" {
+		return nil, oauthError(port.OAuthInvalidClient, "introspection requires a confidential client")
+	}
+
+	if req.Token == "" {
+		return nil, oauthError(port.OAuthInvalidRequest, "token is required")
+	}
+
+	if looksLikeJWT(req.Token) {
+		return a.introspectAccessToken(ctx, req.Token)
+	}
+
+	lookups := []func(context.Context, string) (*domain.Introspection, error){
+		a.introspectSession,
+		a.introspectRefreshToken,
+	}
+	if req.TokenTypeHint == tokenHintRefreshToken {
+		slices.Reverse(lookups)
+	}
+
+	for _, lookup := range lookups {
+		introspection, err := lookup(ctx, req.Token)
+		if err != nil {
+			return nil, err
+		}
+		if introspection.Active {
+			return introspection, nil
+		}
+	}
+
+	return &domain.Introspection{}, nil
+}
+
+func (a *authService) introspectSession(ctx context.Context, token string) (*domain.Introspection, error) {
+	session, err := a.authRepo.FindSessionByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, port.ErrSessionNotFound) {
+			return &domain.Introspection{}, nil
+		}
+		return nil, fmt.Errorf("session retrieval failed: %w", err)
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return &domain.Introspection{}, nil
+	}
+
+	return &domain.Introspection{
+		Active:    true,
+		Subject:   session.UserID,
+		ExpiresAt: session.ExpiresAt.Unix(),
+		IssuedAt:  session.CreatedAt.Unix(),
+		TokenType: "Bearer",
+		SessionID: session.ID,
+	}, nil
+}
+
+func (a *authService) introspectRefreshToken(ctx context.Context, token string) (*domain.Introspection, error) {
+	refresh, err := a.authRepo.FindRefreshToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, port.ErrRefreshTokenNotFound) {
+			return &domain.Introspection{}, nil
+		}
+		return nil, fmt.Errorf("refresh token retrieval failed: %w", err)
+	}
+
+	if refresh.UsedAt != nil || time.Now().After(refresh.ExpiresAt) {
+		return &domain.Introspection{}, nil
+	}
+
+	return &domain.Introspection{
+		Active:    true,
+		Subject:   refresh.UserID,
+		ExpiresAt: refresh.ExpiresAt.Unix(),
+		IssuedAt:  refresh.CreatedAt.Unix(),
+		SessionID: refresh.SessionID,
+	}, nil
+}
+
+func (a *authService) introspectAccessToken(ctx context.Context, token string) (*domain.Introspection, error) {
+	claims, err := a.VerifyAccessToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, port.ErrInvalidToken) || errors.Is(err, port.ErrTokenRevoked) {
+			return &domain.Introspection{}, nil
+		}
+		return nil, err
+	}
+
+	return &domain.Introspection{
+		Active:    true,
+		Subject:   claims.Subject,
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		SessionID: claims.SessionID,
+	}, nil
+}
+
+// RevokeToken invalidates a token on behalf of a client. Revoking a session
+// token or refresh token ends the session and its refresh token family, and
+// revoking an access token JWT ends the session it was issued for. Tokens
+// the service does not know are silently ignored, as RFC 7009 asks.
+func (a *authService) RevokeToken(ctx context.Context, req domain.RevocationRequest) error {
+	client, err := a.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	if req.Token == "" {
+		return oauthError(port.OAuthInvalidRequest, "token is required")
+	}
+
+	if looksLikeJWT(req.Token) {
+		return a.revokeAccessToken(ctx, client, req.Token)
+	}
+
+	revocations := []func(context.Context, string) (bool, error){
+		a.revokeSessionToken,
+		a.revokeRefreshToken,
+	}
+	if req.TokenTypeHint == tokenHintRefreshToken {
+		slices.Reverse(revocations)
+	}
+
+	for _, revoke := range revocations {
+		revoked, err := revoke(ctx, req.Token)
+		if err != nil || revoked {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *authService) revokeSessionToken(ctx context.Context, token string) (bool, error) {
+	if err := a.authRepo.DeleteSession(ctx, token); err != nil {
+		if errors.Is(err, port.ErrSessionNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("session revocation failed: %w", err)
+	}
+	return true, nil
+}
+
+func (a *authService) revokeRefreshToken(ctx context.Context, token string) (bool, error) {
+	refresh, err := a.authRepo.FindRefreshToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, port.ErrRefreshTokenNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("refresh token retrieval failed: %w", err)
+	}
+
+	if err := a.authRepo.RevokeRefreshFamily(ctx, refresh.UserID, refresh.FamilyID); err != nil {
+		return false, fmt.Errorf("refresh token revocation failed: %w", err)
+	}
+	return true, nil
+}
+
+func (a *authService) revokeAccessToken(ctx context.Context, client *domain.Client, token string) error {
+	claims, err := a.VerifyAccessToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, port.ErrInvalidToken) || errors.Is(err, port.ErrTokenRevoked) {
+			return nil
+		}
+		return err
+	}
+
+	// Clients may only revoke their own tokens
+	if claims.ClientID != "" && claims.ClientID != client.ID {
+		return nil
+	}
+
+	// Without a session there is nothing to deny the token by; client
+	// credentials tokens are short-lived and expire on their own
+	if claims.SessionID == "" {
+		return oauthError(port.OAuthUnsupportedTokenType, "tokens without a session cannot be revoked")
+	}
+
+	if err := a.authRepo.RevokeSessionByID(ctx, claims.Subject, claims.SessionID); err != nil {
+		if errors.Is(err, port.ErrSessionNotFound) {
+			return nil
+		}
+		return fmt.Errorf("session revocation failed: %w", err)
+	}
+	return nil
+}
+
+// looksLikeJWT tells access token JWTs apart from opaque session and refresh
+// tokens, which never contain dots.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
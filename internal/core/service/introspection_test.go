@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestIntrospectToken(t *testing.T) {
+	ctx := context.Background()
+	device := domain.Device{UserAgent: "test", IPAddress: "192.0.2.1"}
+
+	a, _, client, secret, public := newOAuthTestService(t)
+	introspect := func(token string, hint string) *domain.Introspection {
+		t.Helper()
+		introspection, err := a.IntrospectToken(ctx, domain.IntrospectionRequest{
+			Token:         token,
+			TokenTypeHint: hint,
+			ClientID:      client.ID,
+			ClientSecret:  secret,
+		})
+		if err != nil {
+			t.Fatalf("IntrospectToken: %v", err)
+		}
+		return introspection
+	}
+
+	session, err := a.CreateSession(ctx, "user-1", device)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("client authentication", func(t *testing.T) {
+		for name, req := range map[string]domain.IntrospectionRequest{
+			"public client":  {ClientID: public.ID},
+			"wrong secret":   {ClientID: client.ID, ClientSecret: secret + "x"},
+			"missing secret": {ClientID: client.ID},
+			"unknown client": {ClientID: "unknown", ClientSecret: secret},
+		} {
+			req.Token = session.Token
+			if _, err := a.IntrospectToken(ctx, req); oauthErrorCode(err) != port.OAuthInvalidClient {
+				t.Errorf("%s: expected %s, got %v", name, port.OAuthInvalidClient, err)
+			}
+		}
+	})
+
+	t.Run("session token", func(t *testing.T) {
+		got := introspect(session.Token, "")
+		if !got.Active || got.Subject != "user-1" || got.SessionID != session.ID {
+			t.Errorf("unexpected introspection: %+v", got)
+		}
+	})
+
+	t.Run("refresh token whatever the hint", func(t *testing.T) {
+		for _, hint := range []string{tokenHintRefreshToken, "", "access_token"} {
+			got := introspect(session.RefreshToken, hint)
+			if !got.Active || got.Subject != "user-1" || got.SessionID != session.ID {
+				t.Errorf("hint %q: unexpected introspection: %+v", hint, got)
+			}
+		}
+	})
+
+	t.Run("access token JWT", func(t *testing.T) {
+		got := introspect(session.AccessToken, tokenHintRefreshToken)
+		if !got.Active || got.Subject != "user-1" || got.SessionID != session.ID || got.TokenType != "Bearer" {
+			t.Errorf("unexpected introspection: %+v", got)
+		}
+	})
+
+	t.Run("unknown tokens are inactive", func(t *testing.T) {
+		for _, token := range []string{"unknown", "not.a.jwt"} {
+			if got := introspect(token, ""); *got != (domain.Introspection{}) {
+				t.Errorf("%q: expected a bare inactive response, got %+v", token, got)
+			}
+		}
+	})
+
+	t.Run("used refresh token is inactive", func(t *testing.T) {
+		other, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.RefreshSession(ctx, other.RefreshToken, device); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := introspect(other.RefreshToken, tokenHintRefreshToken); got.Active {
+			t.Errorf("expected the used refresh token inactive, got %+v", got)
+		}
+		if got := introspect(other.Token, ""); got.Active {
+			t.Errorf("expected the refreshed session inactive, got %+v", got)
+		}
+	})
+
+	t.Run("revoked tokens are inactive", func(t *testing.T) {
+		err := a.RevokeToken(ctx, domain.RevocationRequest{
+			Token:        session.Token,
+			ClientID:     client.ID,
+			ClientSecret: secret,
+		})
+		if err != nil {
+			t.Fatalf("RevokeToken: %v", err)
+		}
+
+		for name, token := range map[string]string{
+			"session":       session.Token,
+			"refresh token": session.RefreshToken,
+			"access token":  session.AccessToken,
+		} {
+			if got := introspect(token, ""); got.Active {
+				t.Errorf("%s: expected inactive after revocation, got %+v", name, got)
+			}
+		}
+	})
+}
+
+func TestRevokeToken(t *testing.T) {
+	ctx := context.Background()
+	device := domain.Device{UserAgent: "test", IPAddress: "192.0.2.1"}
+
+	t.Run("public clients identify by client_id", func(t *testing.T) {
+		a, _, _, _, public := newOAuthTestService(t)
+		session, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := a.RevokeToken(ctx, domain.RevocationRequest{Token: session.Token, ClientID: public.ID}); err != nil {
+			t.Fatalf("RevokeToken: %v", err)
+		}
+		if _, err := a.ReadSession(ctx, session.Token); err == nil {
+			t.Error("session still readable after revocation")
+		}
+	})
+
+	t.Run("confidential clients need their secret", func(t *testing.T) {
+		a, _, client, secret, _ := newOAuthTestService(t)
+		session, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, attempt := range []string{"", secret + "x"} {
+			err := a.RevokeToken(ctx, domain.RevocationRequest{Token: session.Token, ClientID: client.ID, ClientSecret: attempt})
+			if oauthErrorCode(err) != port.OAuthInvalidClient {
+				t.Errorf("secret %q: expected %s, got %v", attempt, port.OAuthInvalidClient, err)
+			}
+		}
+		if _, err := a.ReadSession(ctx, session.Token); err != nil {
+			t.Errorf("session ended by an unauthenticated request: %v", err)
+		}
+
+		err = a.RevokeToken(ctx, domain.RevocationRequest{Token: session.Token, ClientID: "unknown"})
+		if oauthErrorCode(err) != port.OAuthInvalidClient {
+			t.Errorf("unknown client: expected %s, got %v", port.OAuthInvalidClient, err)
+		}
+	})
+
+	t.Run("refresh token ends the session and family", func(t *testing.T) {
+		a, repo, _, _, public := newOAuthTestService(t)
+		session, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = a.RevokeToken(ctx, domain.RevocationRequest{
+			Token:         session.RefreshToken,
+			TokenTypeHint: tokenHintRefreshToken,
+			ClientID:      public.ID,
+		})
+		if err != nil {
+			t.Fatalf("RevokeToken: %v", err)
+		}
+		if _, err := a.ReadSession(ctx, session.Token); err == nil {
+			t.Error("session still readable after revoking its refresh token")
+		}
+		if _, err := repo.FindRefreshToken(ctx, session.RefreshToken); !errors.Is(err, port.ErrRefreshTokenNotFound) {
+			t.Errorf("expected the refresh token gone, got %v", err)
+		}
+	})
+
+	t.Run("access token ends its session", func(t *testing.T) {
+		a, repo, _, _, public := newOAuthTestService(t)
+		session, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := a.RevokeToken(ctx, domain.RevocationRequest{Token: session.AccessToken, ClientID: public.ID}); err != nil {
+			t.Fatalf("RevokeToken: %v", err)
+		}
+		if revoked, _ := repo.IsSessionRevoked(ctx, session.ID); !revoked {
+			t.Error("session not denylisted, the access token still verifies")
+		}
+		if _, err := a.VerifyAccessToken(ctx, session.AccessToken); err == nil {
+			t.Error("access token still verifies after revocation")
+		}
+	})
+
+	t.Run("clients only revoke their own access tokens", func(t *testing.T) {
+		a, _, client, secret, public := newOAuthTestService(t)
+		response, err := a.ExchangeToken(ctx, domain.TokenRequest{
+			GrantType:    domain.GrantClientCredentials,
+			ClientID:     client.ID,
+			ClientSecret: secret,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := a.RevokeToken(ctx, domain.RevocationRequest{Token: response.AccessToken, ClientID: public.ID}); err != nil {
+			t.Errorf("expected another client's token to be ignored, got %v", err)
+		}
+
+		err = a.RevokeToken(ctx, domain.RevocationRequest{Token: response.AccessToken, ClientID: client.ID, ClientSecret: secret})
+		if oauthErrorCode(err) != port.OAuthUnsupportedTokenType {
+			t.Errorf("expected %s for a token without a session, got %v", port.OAuthUnsupportedTokenType, err)
+		}
+	})
+
+	t.Run("unknown tokens are ignored", func(t *testing.T) {
+		a, _, _, _, public := newOAuthTestService(t)
+		for _, token := range []string{"unknown", "not.a.jwt"} {
+			if err := a.RevokeToken(ctx, domain.RevocationRequest{Token: token, ClientID: public.ID}); err != nil {
+				t.Errorf("%q: expected no error, got %v", token, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// RunSessionJanitor prunes orphaned session index entries every interval
+// until ctx is cancelled. Session keys expire on their own; the janitor only
+// tidies up what they leave behind in the per-user indexes.
+func RunSessionJanitor(ctx context.Context, sessions port.SessionService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := sessions.PruneSessions(ctx)
+			if err != nil {
+				log.Println("Session janitor:", err)
+				continue
+			}
+			if pruned > 0 {
+				log.Printf("Session janitor: pruned %d orphaned session entries", pruned)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// pruneRecorder reports every PruneSessions call on calls.
+type pruneRecorder struct {
+	port.SessionService
+	err   error
+	calls chan struct{}
+}
+
+func (p *pruneRecorder) PruneSessions(ctx context.Context) (int, error) {
+	select {
+	case p.calls <- struct{}{}:
+	case <-ctx.Done():
+	}
+	return 1, p.err
+}
+
+func TestRunSessionJanitor(t *testing.T) {
+	for name, err := range map[string]error{
+		"prunes every interval": nil,
+		"keeps going on errors": errors.New("redis unavailable"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sessions := &pruneRecorder{err: err, calls: make(chan struct{})}
+			done := make(chan struct{})
+			go func() {
+				RunSessionJanitor(ctx, sessions, time.Millisecond)
+				close(done)
+			}()
+
+			for range 2 {
+				select {
+				case <-sessions.calls:
+				case <-time.After(time.Second):
+					t.Fatal("janitor did not prune")
+				}
+			}
+
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("janitor kept running after cancellation")
+			}
+		})
+	}
+}
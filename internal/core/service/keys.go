@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// keyCacheTTL bounds how long a key list read from the store is reused, and
+// so how long a key revoked by another process keeps verifying here.
+const keyCacheTTL = time.Minute
+
+// rotationLockTTL bounds how long a process that dies while rotating keys
+// holds up the others.
+const rotationLockTTL = 30 * time.Second
+
+// rotationLockWait is how long rotation waits for another process to finish
+// rotating, checking every rotationLockPoll.
+var (
+	rotationLockWait = 5 * time.Second
+	rotationLockPoll = 100 * time.Millisecond
+)
+
+// KeyRotationPolicy sets the lifecycle of signing keys. A key signs for
+// RotationPeriod. Its successor is published PrePublish before taking over so
+// verifiers caching the JWKS already know it, and a retired key stays
+// published for RetiredGrace so tokens it signed keep verifying.
+type KeyRotationPolicy struct {
+	RotationPeriod time.Duration
+	PrePublish     time.Duration
+	RetiredGrace   time.Duration
+}
+
+// DefaultKeyRotationPolicy rotates keys every 90 days, publishing successors
+// two days ahead and keeping retired keys published for a day.
+func DefaultKeyRotationPolicy() KeyRotationPolicy {
+	return KeyRotationPolicy{
+		RotationPeriod: 90 * 24 * time.Hour,
+		PrePublish:     48 * time.Hour,
+		RetiredGrace:   24 * time.Hour,
+	}
+}
+
+// KeyManager rotates the keys in a KeyStore and hands out the ones to sign
+// and verify with.
+type KeyManager struct {
+	store  port.KeyStore
+	policy KeyRotationPolicy
+
+	mu       sync.Mutex
+	cached   []domain.SigningKey
+	cachedAt time.Time
+}
+
+// NewKeyManager manages the keys in store. Processes sharing a store take
+// turns rotating through its rotation lock.
+func NewKeyManager(store port.KeyStore, policy KeyRotationPolicy) *KeyManager {
+	return &KeyManager{store: store, policy: policy}
+}
+
+// SigningKey returns the key new tokens are signed with, creating one if the
+// store has none active.
+func (m *KeyManager) SigningKey(ctx context.Context) (*domain.SigningKey, error) {
+	keys, err := m.cachedKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := activeKey(keys, time.Now()); key != nil {
+		return key, nil
+	}
+
+	if err := m.Maintain(ctx); err != nil {
+		return nil, err
+	}
+
+	keys, err = m.cachedKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := activeKey(keys, time.Now()); key != nil {
+		return key, nil
+	}
+	return nil, ErrNoSigningKey
+}
+
+// PublishedKeys returns the keys tokens may be verified with: the active key,
+// its pre-published successor and recently retired keys, never revoked ones.
+func (m *KeyManager) PublishedKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	keys, err := m.cachedKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	published := make([]domain.SigningKey, 0, len(keys))
+	for _, key := range keys {
+		if key.RevokedAt == nil && now.Before(key.ExpiresAt) {
+			published = append(published, key)
+		}
+	}
+	return published, nil
+}
+
+// ListKeys returns every key in the store, oldest activation first.
+func (m *KeyManager) ListKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	keys, err := m.store.ListKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("key listing failed: %w", err)
+	}
+	sortKeys(keys)
+	return keys, nil
+}
+
+// Maintain runs the scheduled part of rotation: it makes sure a key is
+// active, publishes its successor once it is due to retire within
+// PrePublish, and deletes keys that are no longer published.
+func (m *KeyManager) Maintain(ctx context.Context) error {
+	defer m.invalidate()
+
+	return m.locked(ctx, func() error {
+		return m.maintain(ctx)
+	})
+}
+
+func (m *KeyManager) maintain(ctx context.Context) error {
+	keys, err := m.store.ListKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("key listing failed: %w", err)
+	}
+
+	now := time.Now()
+	live := make([]domain.SigningKey, 0, len(keys))
+	for _, key := range keys {
+		if !now.Before(key.ExpiresAt) {
+			if err := m.store.DeleteKey(ctx, key.ID); err != nil && !errors.Is(err, port.ErrKeyNotFound) {
+				return fmt.Errorf("expired key deletion failed: %w", err)
+			}
+			continue
+		}
+		if key.RevokedAt == nil {
+			live = append(live, key)
+		}
+	}
+	sortKeys(live)
+
+	if activeKey(live, now) == nil {
+		// Nothing can sign right now: promote a pending key or start one
+		if pending := pendingKey(live, now); pending != nil {
+			pending.ActivatesAt = now
+			if err := m.store.SaveKey(ctx, *pending); err != nil {
+				return fmt.Errorf("key activation failed: %w", err)
+			}
+		} else {
+			key, err := m.newKey(now)
+			if err != nil {
+				return err
+			}
+			if err := m.store.SaveKey(ctx, *key); err != nil {
+				return fmt.Errorf("key creation failed: %w", err)
+			}
+			live = append(live, *key)
+		}
+	}
+
+	last := live[0]
+	for _, key := range live {
+		if key.RetiresAt.After(last.RetiresAt) {
+			last = key
+		}
+	}
+	if last.RetiresAt.Sub(now) <= m.policy.PrePublish {
+		successor, err := m.newKey(last.RetiresAt)
+		if err != nil {
+			return err
+		}
+		if err := m.store.SaveKey(ctx, *successor); err != nil {
+			return fmt.Errorf("key creation failed: %w", err)
+		}
+		log.Printf("Published signing key %s, active from %s", successor.ID, successor.ActivatesAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// ForceRotate retires the active key immediately and starts signing with a
+// new one. Keys waiting to become active are dropped in favour of it.
+func (m *KeyManager) ForceRotate(ctx context.Context) (*domain.SigningKey, error) {
+	defer m.invalidate()
+
+	var key *domain.SigningKey
+	err := m.locked(ctx, func() (err error) {
+		key, err = m.forceRotate(ctx)
+		return err
+	})
+	return key, err
+}
+
+func (m *KeyManager) forceRotate(ctx context.Context) (*domain.SigningKey, error) {
+	keys, err := m.store.ListKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("key listing failed: %w", err)
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		switch {
+		case key.ActivatesAt.After(now):
+			if err := m.store.DeleteKey(ctx, key.ID); err != nil && !errors.Is(err, port.ErrKeyNotFound) {
+				return nil, fmt.Errorf("pending key deletion failed: %w", err)
+			}
+		case key.RetiresAt.After(now):
+			key.RetiresAt = now
+			key.ExpiresAt = now.Add(m.policy.RetiredGrace)
+			if err := m.store.SaveKey(ctx, key); err != nil {
+				return nil, fmt.Errorf("key retirement failed: %w", err)
+			}
+		}
+	}
+
+	key, err := m.newKey(now)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.store.SaveKey(ctx, *key); err != nil {
+		return nil, fmt.Errorf("key creation failed: %w", err)
+	}
+
+	return key, nil
+}
+
+// Revoke withdraws a compromised key: it is dropped from the JWKS and tokens
+// it signed stop verifying. A replacement is started if it was signing.
+func (m *KeyManager) Revoke(ctx context.Context, kid string) error {
+	defer m.invalidate()
+
+	return m.locked(ctx, func() error {
+		return m.revoke(ctx, kid)
+	})
+}
+
+func (m *KeyManager) revoke(ctx context.Context, kid string) error {
+	keys, err := m.store.ListKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("key listing failed: %w", err)
+	}
+
+	for _, key := range keys {
+		if key.ID != kid {
+			continue
+		}
+
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := m.store.SaveKey(ctx, key); err != nil {
+			return fmt.Errorf("key revocation failed: %w", err)
+		}
+
+		return m.maintain(ctx)
+	}
+
+	return port.ErrKeyNotFound
+}
+
+// locked runs fn under the rotation lock of the store, waiting up to
+// rotationLockWait for another process that holds it.
+func (m *KeyManager) locked(ctx context.Context, fn func() error) error {
+	deadline := time.Now().Add(rotationLockWait)
+	for {
+		token, acquired, err := m.store.LockRotation(ctx, rotationLockTTL)
+		if err != nil {
+			return fmt.Errorf("key rotation lock failed: %w", err)
+		}
+		if acquired {
+			defer func() {
+				if err := m.store.UnlockRotation(context.WithoutCancel(ctx), token); err != nil {
+					log.Println("Failed to release key rotation lock:", err)
+				}
+			}()
+			return fn()
+		}
+
+		if time.Now().After(deadline) {
+			return port.ErrKeyStoreBusy
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rotationLockPoll):
+		}
+	}
+}
+
+func (m *KeyManager) newKey(activatesAt time.Time) (*domain.SigningKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("key generation failed: %w", err)
+	}
+
+	retiresAt := activatesAt.Add(m.policy.RotationPeriod)
+
+	return &domain.SigningKey{
+		ID:          jwkThumbprint(public),
+		Algorithm:   jwtAlgorithm,
+		PrivateKey:  private,
+		PublicKey:   public,
+		CreatedAt:   time.Now(),
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		ExpiresAt:   retiresAt.Add(m.policy.RetiredGrace),
+	}, nil
+}
+
+func (m *KeyManager) cachedKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cached != nil && time.Since(m.cachedAt) < keyCacheTTL {
+		return m.cached, nil
+	}
+
+	keys, err := m.store.ListKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("key listing failed: %w", err)
+	}
+	sortKeys(keys)
+
+	m.cached = keys
+	m.cachedAt = time.Now()
+	return keys, nil
+}
+
+func (m *KeyManager) invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cached = nil
+}
+
+// activeKey returns the unrevoked key signing at now that activated last.
+func activeKey(keys []domain.SigningKey, now time.Time) *domain.SigningKey {
+	var active *domain.SigningKey
+	for i := range keys {
+		key := &keys[i]
+		if key.RevokedAt != nil || key.ActivatesAt.After(now) || !key.RetiresAt.After(now) {
+			continue
+		}
+		if active == nil || key.ActivatesAt.After(active.ActivatesAt) {
+			active = key
+		}
+	}
+	return active
+}
+
+// pendingKey returns the unrevoked key due to activate soonest after now.
+func pendingKey(keys []domain.SigningKey, now time.Time) *domain.SigningKey {
+	var pending *domain.SigningKey
+	for i := range keys {
+		key := &keys[i]
+		if key.RevokedAt != nil || !key.ActivatesAt.After(now) {
+			continue
+		}
+		if pending == nil || key.ActivatesAt.Before(pending.ActivatesAt) {
+			pending = key
+		}
+	}
+	return pending
+}
+
+func sortKeys(keys []domain.SigningKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+}
+
+// RunKeyRotation maintains the signing keys now and then every interval
+// until ctx is cancelled.
+func RunKeyRotation(ctx context.Context, keys *KeyManager, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := keys.Maintain(ctx); err != nil {
+			log.Println("Key rotation:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
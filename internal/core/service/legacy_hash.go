@@ -0,0 +1,290 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// LegacyHasher verifies password hashes imported from other systems. A user
+// with such a hash gets an Argon2id one the next time they sign in.
+type LegacyHasher interface {
+	// Recognizes reports whether encodedHash is in the hasher's format.
+	Recognizes(encodedHash string) bool
+	// Check parses encodedHash without deriving a key, and tells why Verify
+	// would fail on it: malformed, or beyond the cost limits.
+	Check(encodedHash string) error
+	Verify(password string, encodedHash string) (bool, error)
+}
+
+// DefaultLegacyHashers understand bcrypt, Django's PBKDF2-SHA256 and scrypt,
+// and passlib's scrypt.
+func DefaultLegacyHashers() []LegacyHasher {
+	return []LegacyHasher{BcryptHasher{}, DjangoPBKDF2Hasher{}, DjangoScryptHasher{}, PasslibScryptHasher{}}
+}
+
+// Bounds on the cost of imported hashes, so a crafted record cannot tie up
+// the server for minutes on every sign in.
+const (
+	maxBcryptCost       = 16
+	maxPBKDF2Iterations = 2_000_000
+	// PBKDF2 runs the iterations once per 32 bytes of key; Django's keys
+	// are exactly that long
+	maxPBKDF2KeyLength = sha256.Size
+	maxScryptMemory    = 1 << 30 // bytes, 128 * N * r
+	maxScryptKeyLength = 128
+)
+
+// memoryLimited is implemented by legacy hashers whose cost is mostly
+// memory. The password hasher limits them to the memory of an Argon2id hash
+// at the target parameters, the most one hashing pool slot is sized for.
+type memoryLimited interface {
+	withMemoryLimit(bytes int) LegacyHasher
+}
+
+var errUnsupportedHashCost = errors.New("hash cost exceeds supported limits")
+
+// BcryptHasher verifies "$2a$", "$2b$" and "$2y$" bcrypt hashes.
+type BcryptHasher struct{}
+
+func (BcryptHasher) Recognizes(encodedHash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(encodedHash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (BcryptHasher) Check(encodedHash string) error {
+	cost, err := bcrypt.Cost([]byte(goBcryptHash(encodedHash)))
+	if err != nil {
+		return err
+	}
+	if cost > maxBcryptCost {
+		return errUnsupportedHashCost
+	}
+	return nil
+}
+
+func (h BcryptHasher) Verify(password string, encodedHash string) (bool, error) {
+	if err := h.Check(encodedHash); err != nil {
+		return false, err
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(goBcryptHash(encodedHash)), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// goBcryptHash rewrites $2y$, PHP's name for the same algorithm, as the $2a$
+// Go's bcrypt reads.
+func goBcryptHash(encodedHash string) string {
+	if strings.HasPrefix(encodedHash, "$2y$") {
+		return "$2a$" + strings.TrimPrefix(encodedHash, "$2y$")
+	}
+	return encodedHash
+}
+
+// DjangoPBKDF2Hasher verifies Django's "pbkdf2_sha256$<iterations>$<salt>$<hash>".
+type DjangoPBKDF2Hasher struct{}
+
+func (DjangoPBKDF2Hasher) Recognizes(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "pbkdf2_sha256$")
+}
+
+// pbkdf2Hash is a Django PBKDF2 hash taken apart.
+type pbkdf2Hash struct {
+	iterations int
+	salt       []byte
+	key        []byte
+}
+
+func decodePBKDF2Hash(encodedHash string) (*pbkdf2Hash, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 4 {
+		return nil, errors.New("invalid pbkdf2_sha256 hash format")
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return nil, errors.New("invalid pbkdf2_sha256 iterations")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, errors.New("invalid pbkdf2_sha256 hash format")
+	}
+	if iterations > maxPBKDF2Iterations || len(key) > maxPBKDF2KeyLength {
+		return nil, errUnsupportedHashCost
+	}
+
+	return &pbkdf2Hash{iterations: iterations, salt: []byte(parts[2]), key: key}, nil
+}
+
+func (DjangoPBKDF2Hasher) Check(encodedHash string) error {
+	_, err := decodePBKDF2Hash(encodedHash)
+	return err
+}
+
+func (DjangoPBKDF2Hasher) Verify(password string, encodedHash string) (bool, error) {
+	decoded, err := decodePBKDF2Hash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	derived := pbkdf2.Key([]byte(password), decoded.salt, decoded.iterations, len(decoded.key), sha256.New)
+	return subtle.ConstantTimeCompare(derived, decoded.key) == 1, nil
+}
+
+// scryptHash is an scrypt hash taken apart.
+type scryptHash struct {
+	n, r, p int
+	salt    []byte
+	key     []byte
+}
+
+// check rejects parameters scrypt refuses or that exceed the cost limits.
+// maxMemory, when set, lowers the memory limit below maxScryptMemory.
+func (h *scryptHash) check(maxMemory int) error {
+	if h.n < 2 || h.r < 1 || h.p < 1 || len(h.key) == 0 {
+		return errors.New("invalid scrypt parameters")
+	}
+	if maxMemory <= 0 || maxMemory > maxScryptMemory {
+		maxMemory = maxScryptMemory
+	}
+	if h.n > maxMemory || h.r > 64 || 128*h.n*h.r > maxMemory || h.p > 16 || len(h.key) > maxScryptKeyLength {
+		return errUnsupportedHashCost
+	}
+	return nil
+}
+
+func (h *scryptHash) verify(password string) (bool, error) {
+	derived, err := scrypt.Key([]byte(password), h.salt, h.n, h.r, h.p, len(h.key))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(derived, h.key) == 1, nil
+}
+
+// DjangoScryptHasher verifies Django's "scrypt$<N>$<salt>$<r>$<p>$<hash>".
+type DjangoScryptHasher struct {
+	// maxMemory caps 128 * N * r, in bytes, below maxScryptMemory
+	maxMemory int
+}
+
+func (h DjangoScryptHasher) withMemoryLimit(bytes int) LegacyHasher {
+	h.maxMemory = bytes
+	return h
+}
+
+func (DjangoScryptHasher) Recognizes(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "scrypt$")
+}
+
+func (h DjangoScryptHasher) decode(encodedHash string) (*scryptHash, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 {
+		return nil, errors.New("invalid scrypt hash format")
+	}
+
+	var costs [3]int
+	for i, part := range []string{parts[1], parts[3], parts[4]} {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrypt parameter %q", part)
+		}
+		costs[i] = n
+	}
+
+	key, err := base64.StdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := &scryptHash{n: costs[0], r: costs[1], p: costs[2], salt: []byte(parts[2]), key: key}
+	return decoded, decoded.check(h.maxMemory)
+}
+
+func (h DjangoScryptHasher) Check(encodedHash string) error {
+	_, err := h.decode(encodedHash)
+	return err
+}
+
+func (h DjangoScryptHasher) Verify(password string, encodedHash string) (bool, error) {
+	decoded, err := h.decode(encodedHash)
+	if err != nil {
+		return false, err
+	}
+	return decoded.verify(password)
+}
+
+// PasslibScryptHasher verifies passlib's "$scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<hash>",
+// salt and hash in passlib's base64 with "." for "+".
+type PasslibScryptHasher struct {
+	// maxMemory caps 128 * N * r, in bytes, below maxScryptMemory
+	maxMemory int
+}
+
+func (h PasslibScryptHasher) withMemoryLimit(bytes int) LegacyHasher {
+	h.maxMemory = bytes
+	return h
+}
+
+func (PasslibScryptHasher) Recognizes(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$scrypt$")
+}
+
+func (h PasslibScryptHasher) decode(encodedHash string) (*scryptHash, error) {
+	parts := strings.Split(strings.TrimPrefix(encodedHash, "$"), "$")
+	if len(parts) != 4 {
+		return nil, errors.New("invalid scrypt hash format")
+	}
+
+	var logN, r, p int
+	if _, err := fmt.Sscanf(parts[1], "ln=%d,r=%d,p=%d", &logN, &r, &p); err != nil {
+		return nil, fmt.Errorf("invalid scrypt parameters: %w", err)
+	}
+	if logN < 1 || logN > 30 {
+		return nil, errUnsupportedHashCost
+	}
+
+	passlibBase64 := strings.NewReplacer(".", "+")
+	salt, err := base64.RawStdEncoding.DecodeString(passlibBase64.Replace(parts[2]))
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(passlibBase64.Replace(parts[3]))
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := &scryptHash{n: 1 << logN, r: r, p: p, salt: salt, key: key}
+	return decoded, decoded.check(h.maxMemory)
+}
+
+func (h PasslibScryptHasher) Check(encodedHash string) error {
+	_, err := h.decode(encodedHash)
+	return err
+}
+
+func (h PasslibScryptHasher) Verify(password string, encodedHash string) (bool, error) {
+	decoded, err := h.decode(encodedHash)
+	if err != nil {
+		return false, err
+	}
+	return decoded.verify(password)
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// LockoutPolicy slows down and then stops password guessing against a phone
+// number. After FreeAttempts failed sign ins, each further attempt has to
+// wait BaseDelay, doubling per failure up to MaxDelay. Threshold failures
+// lock the number out for LockoutDuration. Failures are forgotten Window
+// after the last one, or on a successful sign in.
+type LockoutPolicy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// Threshold of zero never locks numbers out.
+	Threshold       int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		Threshold:       10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+}
+
+// delay is the wait after count failures.
+func (p LockoutPolicy) delay(count int) time.Duration {
+	excess := count - p.FreeAttempts
+	if excess <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < excess && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// locks reports whether count failures lock a number out.
+func (p LockoutPolicy) locks(count int) bool {
+	return p.Threshold > 0 && count >= p.Threshold
+}
+
+// retryAt is when the next sign in is allowed after the given failures,
+// and whether the number is locked out until then.
+func (p LockoutPolicy) retryAt(failures domain.LoginFailures) (time.Time, bool) {
+	if failures.Count == 0 {
+		return time.Time{}, false
+	}
+	if p.locks(failures.Count) {
+		return failures.LastFailureAt.Add(p.LockoutDuration), true
+	}
+	return failures.LastFailureAt.Add(p.delay(failures.Count)), false
+}
+
+// ttl is how long failures are kept after the last one. They outlive any
+// delay or lockout they cause.
+func (p LockoutPolicy) ttl() time.Duration {
+	return max(p.Window, p.LockoutDuration, p.MaxDelay, p.delay(p.Threshold))
+}
+
+// reserveTries is how often a sign in re-checks the lockout when other
+// attempts with the same number keep reserving first.
+const reserveTries = 3
+
+// checkLockout returns the failures with a phone number, or an
+// AccountLockedError when they hold back another attempt for now.
+func (a *authService) checkLockout(ctx context.Context, phonenumber string) (*domain.LoginFailures, error) {
+	failures, err := a.authRepo.ReadLoginFailures(ctx, phonenumber)
+	if err != nil {
+		return nil, fmt.Errorf("login failure lookup failed: %w", err)
+	}
+
+	retryAt, locked := a.lockout.retryAt(*failures)
+	if wait := time.Until(retryAt); wait > 0 {
+		return nil, &port.AccountLockedError{RetryAfter: wait, Locked: locked}
+	}
+	return failures, nil
+}
+
+// reserveLoginAttempt counts a sign in with a phone number as failed until
+// its password is found to match, returning the failures with it. Attempts
+// held back by earlier failures, counting those still being checked, get an
+// AccountLockedError.
+func (a *authService) reserveLoginAttempt(ctx context.Context, phonenumber string) (*domain.LoginFailures, error) {
+	for i := 0; i < reserveTries; i++ {
+		failures, err := a.checkLockout(ctx, phonenumber)
+		if err != nil {
+			return nil, err
+		}
+
+		reserved, err := a.authRepo.ReserveLoginAttempt(ctx, phonenumber, failures.Count, time.Now(), a.lockout.ttl())
+		if err != nil {
+			return nil, fmt.Errorf("login attempt reservation failed: %w", err)
+		}
+		if reserved != nil {
+			return reserved, nil
+		}
+	}
+
+	// Other attempts with the number keep getting there first
+	return nil, &port.AccountLockedError{RetryAfter: max(a.lockout.BaseDelay, time.Second)}
+}
+
+// releaseLoginAttempt takes back a reserved attempt whose password could not
+// be checked. Failing is logged; the attempt then counts as a failure.
+func (a *authService) releaseLoginAttempt(ctx context.Context, phonenumber string) {
+	if err := a.authRepo.ReleaseLoginAttempt(context.WithoutCancel(ctx), phonenumber); err != nil {
+		log.Println("Failed to release login attempt:", err)
+	}
+}
+
+// recordLockout records in a registered user's audit trail the failure,
+// already counted by reserveLoginAttempt, that locks them out.
+func (a *authService) recordLockout(ctx context.Context, failures *domain.LoginFailures, user *domain.User) {
+	if !a.lockout.locks(failures.Count) || a.lockout.locks(failures.Count-1) {
+		return
+	}
+
+	a.recordEvent(ctx, domain.SecurityEvent{
+		Type:   domain.EventAccountLocked,
+		UserID: user.ID,
+		Details: map[string]string{
+			"failures":     fmt.Sprint(failures.Count),
+			"locked_until": failures.LastFailureAt.Add(a.lockout.LockoutDuration).UTC().Format(time.RFC3339),
+		},
+		Phonenumber: user.Phonenumber,
+	})
+}
+
+// clearLoginFailures forgets failures after a successful sign in. Failing
+// is logged rather than failing the sign in.
+func (a *authService) clearLoginFailures(ctx context.Context, phonenumber string) {
+	if _, err := a.authRepo.ClearLoginFailures(ctx, phonenumber); err != nil {
+		log.Println("Failed to clear login failures:", err)
+	}
+}
+
+// LockoutStatus tells whether sign ins with a phone number are held back.
+func (a *authService) LockoutStatus(ctx context.Context, phonenumber string) (*domain.LockoutStatus, error) {
+	phonenumber = a.lookupPhone(phonenumber)
+
+	failures, err := a.authRepo.ReadLoginFailures(ctx, phonenumber)
+	if err != nil {
+		return nil, fmt.Errorf("login failure lookup failed: %w", err)
+	}
+
+	status := &domain.LockoutStatus{
+		Phonenumber:   phonenumber,
+		Failures:      failures.Count,
+		LastFailureAt: failures.LastFailureAt,
+	}
+	if retryAt, locked := a.lockout.retryAt(*failures); time.Now().Before(retryAt) {
+		status.RetryAt = retryAt
+		status.Locked = locked
+	}
+	return status, nil
+}
+
+// UnlockAccount lets an administrator lift a lockout before it runs out.
+func (a *authService) UnlockAccount(ctx context.Context, phonenumber string) (bool, error) {
+	phonenumber = a.lookupPhone(phonenumber)
+
+	cleared, err := a.authRepo.ClearLoginFailures(ctx, phonenumber)
+	if err != nil {
+		return false, fmt.Errorf("login failure clearing failed: %w", err)
+	}
+
+	if cleared {
+		if user, err := a.authRepo.ReadUserByPhone(ctx, phonenumber); err == nil && user != nil {
+			a.recordEvent(ctx, domain.SecurityEvent{
+				Type:   domain.EventAccountUnlocked,
+				UserID: user.ID,
+			})
+		}
+	}
+	return cleared, nil
+}
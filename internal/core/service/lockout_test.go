@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestLockoutPolicyRetryAt(t *testing.T) {
+	policy := DefaultLockoutPolicy()
+	last := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		failures   int
+		wantDelay  time.Duration
+		wantLocked bool
+	}{
+		{0, 0, false},
+		{3, 0, false},
+		{4, time.Second, false},
+		{5, 2 * time.Second, false},
+		{7, 8 * time.Second, false},
+		{9, 32 * time.Second, false},
+		{10, 15 * time.Minute, true},
+		{25, 15 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		retryAt, locked := policy.retryAt(domain.LoginFailures{Count: tt.failures, LastFailureAt: last})
+		var delay time.Duration
+		if !retryAt.IsZero() {
+			delay = retryAt.Sub(last)
+		}
+		if delay != tt.wantDelay || locked != tt.wantLocked {
+			t.Errorf("retryAt after %d failures = +%s locked=%v, want +%s locked=%v",
+				tt.failures, delay, locked, tt.wantDelay, tt.wantLocked)
+		}
+	}
+
+	// Delays stop doubling at MaxDelay, and never lock without a threshold
+	policy.Threshold = 0
+	retryAt, locked := policy.retryAt(domain.LoginFailures{Count: 40, LastFailureAt: last})
+	if got := retryAt.Sub(last); got != policy.MaxDelay || locked {
+		t.Errorf("retryAt after 40 failures without threshold = +%s locked=%v, want +%s", got, locked, policy.MaxDelay)
+	}
+}
+
+func TestValidateUserLockout(t *testing.T) {
+	ctx := context.Background()
+	phone := "+12025550123"
+	password := "correct-horse-battery"
+	policy := LockoutPolicy{FreeAttempts: 10, Threshold: 3, LockoutDuration: time.Hour, Window: time.Hour}
+
+	setup := func(t *testing.T) (*authService, *testRepo) {
+		t.Helper()
+		a, repo := newTestService(t, WithLockoutPolicy(policy))
+		if _, err := a.CreateUser(ctx, domain.Credentials{Phonenumber: phone, Password: password}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		return a, repo
+	}
+	fail := func(t *testing.T, a *authService, number string) {
+		t.Helper()
+		if valid, err := a.ValidateUser(ctx, domain.Credentials{Phonenumber: number, Password: "wrong-password"}); err != nil || valid {
+			t.Fatalf("ValidateUser(wrong password) = %v, %v, want false", valid, err)
+		}
+	}
+
+	t.Run("locks out at the threshold", func(t *testing.T) {
+		a, repo := setup(t)
+		for i := 0; i < policy.Threshold; i++ {
+			fail(t, a, phone)
+		}
+
+		var locked *port.AccountLockedError
+		_, err := a.ValidateUser(ctx, domain.Credentials{Phonenumber: phone, Password: password})
+		if !errors.As(err, &locked) || !locked.Locked || locked.RetryAfter <= 0 {
+			t.Fatalf("expected a lockout, got %v", err)
+		}
+		if repo.failures[phone].Count != policy.Threshold {
+			t.Errorf("failures = %d, want the refused attempt uncounted", repo.failures[phone].Count)
+		}
+		if events := repo.eventTypes(); len(events) != 1 || events[0] != domain.EventAccountLocked {
+			t.Errorf("expected one %s event, got %v", domain.EventAccountLocked, events)
+		}
+	})
+
+	t.Run("a success clears failures", func(t *testing.T) {
+		a, repo := setup(t)
+		for i := 0; i < policy.Threshold-1; i++ {
+			fail(t, a, phone)
+		}
+
+		if valid, err := a.ValidateUser(ctx, domain.Credentials{Phonenumber: phone, Password: password}); err != nil || !valid {
+			t.Fatalf("ValidateUser = %v, %v, want true", valid, err)
+		}
+		if _, ok := repo.failures[phone]; ok {
+			t.Errorf("expected failures cleared, got %+v", repo.failures[phone])
+		}
+	})
+
+	t.Run("unknown numbers lock out the same", func(t *testing.T) {
+		a, repo := setup(t)
+		other := "+12025550199"
+		for i := 0; i < policy.Threshold; i++ {
+			fail(t, a, other)
+		}
+
+		if _, err := a.ValidateUser(ctx, domain.Credentials{Phonenumber: other, Password: password}); !errors.Is(err, port.ErrAccountLocked) {
+			t.Errorf("expected ErrAccountLocked, got %v", err)
+		}
+		if len(repo.eventTypes()) != 0 {
+			t.Errorf("expected no events for a number without an account, got %v", repo.eventTypes())
+		}
+	})
+
+	t.Run("concurrent attempts cannot pass the threshold", func(t *testing.T) {
+		a, repo := setup(t)
+		repo.failures[phone] = domain.LoginFailures{Count: policy.Threshold - 1, LastFailureAt: time.Now()}
+
+		results := make(chan error)
+		for i := 0; i < 8; i++ {
+			go func() {
+				_, err := a.ValidateUser(ctx, domain.Credentials{Phonenumber: phone, Password: "wrong-password"})
+				results <- err
+			}()
+		}
+		checked := 0
+		for i := 0; i < 8; i++ {
+			if err := <-results; err == nil {
+				checked++
+			} else if !errors.Is(err, port.ErrAccountLocked) {
+				t.Errorf("expected ErrAccountLocked, got %v", err)
+			}
+		}
+
+		if checked != 1 || repo.failures[phone].Count != policy.Threshold {
+			t.Errorf("%d passwords checked with %d failures, want 1 with %d", checked, repo.failures[phone].Count, policy.Threshold)
+		}
+	})
+
+	t.Run("lockout texts count against the send limit", func(t *testing.T) {
+		notifier := &testNotifier{events: make(chan domain.SecurityEvent, 2)}
+		codePolicy := testCodePolicy
+		codePolicy.MaxSends = 1
+		a, repo := newTestService(t, WithLockoutPolicy(policy), WithOneTimeCodePolicy(codePolicy), WithSecurityNotifiers(notifier))
+		if _, err := a.CreateUser(ctx, domain.Credentials{Phonenumber: phone, Password: password}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		for _, want := range []string{phone, ""} {
+			for i := 0; i < policy.Threshold; i++ {
+				fail(t, a, phone)
+			}
+			event := <-notifier.events
+			if event.Type != domain.EventAccountLocked || event.Phonenumber != want {
+				t.Errorf("notified of %s for %q, want %s for %q", event.Type, event.Phonenumber, domain.EventAccountLocked, want)
+			}
+			if _, ok := event.Details["phonenumber"]; ok {
+				t.Error("phone number recorded in the event details")
+			}
+			delete(repo.failures, phone)
+		}
+		if repo.limits["sms:"+phone] != 2 {
+			t.Errorf("send limit hit %d times, want 2", repo.limits["sms:"+phone])
+		}
+	})
+
+	t.Run("attempts that cannot be checked are taken back", func(t *testing.T) {
+		a, repo := setup(t)
+		user, _ := repo.ReadUserByPhone(ctx, phone)
+		user.Password = "argon2id$v=19$m=64,t=1,p=1,keyid=gone$c2FsdHNhbHQ$MDEyMzQ1Njc4OWFiY2RlZg"
+		repo.users[user.ID] = *user
+
+		if _, err := a.ValidateUser(ctx, domain.Credentials{Phonenumber: phone, Password: password}); err == nil {
+			t.Fatal("expected an error for a hash with an unknown pepper")
+		}
+		if _, ok := repo.failures[phone]; ok {
+			t.Errorf("expected the attempt released, got %+v", repo.failures[phone])
+		}
+	})
+}
+
+// testNotifier passes notified events on to a channel.
+type testNotifier struct {
+	events chan domain.SecurityEvent
+}
+
+func (n *testNotifier) NotifySecurityEvent(ctx context.Context, event domain.SecurityEvent) error {
+	n.events <- event
+	return nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import "fmt"
+
+// LoginMode selects how users of a deployment prove who they are.
+type LoginMode string
+
+const (
+	// LoginModePassword signs users in with their password only.
+	LoginModePassword LoginMode = "password"
+	// LoginModeOTP signs users in with one-time codes texted to them only.
+	LoginModeOTP LoginMode = "otp"
+	// LoginModeEither accepts both.
+	LoginModeEither LoginMode = "either"
+)
+
+// ParseLoginMode reads a login mode from configuration.
+func ParseLoginMode(mode string) (LoginMode, error) {
+	switch LoginMode(mode) {
+	case LoginModePassword, LoginModeOTP, LoginModeEither:
+		return LoginMode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown login mode %q: want password, otp or either", mode)
+	}
+}
+
+func (m LoginMode) allowsPassword() bool {
+	return m == LoginModePassword || m == LoginModeEither
+}
+
+func (m LoginMode) allowsOTP() bool {
+	return m == LoginModeOTP || m == LoginModeEither
+}
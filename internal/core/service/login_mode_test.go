@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestParseLoginMode(t *testing.T) {
+	tests := []struct {
+		mode          string
+		password, otp bool
+	}{
+		{"password", true, false},
+		{"otp", false, true},
+		{"either", true, true},
+	}
+	for _, tt := range tests {
+		mode, err := ParseLoginMode(tt.mode)
+		if err != nil {
+			t.Fatalf("ParseLoginMode(%q): %v", tt.mode, err)
+		}
+		if mode.allowsPassword() != tt.password || mode.allowsOTP() != tt.otp {
+			t.Errorf("%s: allowsPassword = %v, allowsOTP = %v", mode, mode.allowsPassword(), mode.allowsOTP())
+		}
+	}
+
+	for _, mode := range []string{"", "OTP", "sms"} {
+		if _, err := ParseLoginMode(mode); err == nil {
+			t.Errorf("expected %q to be rejected", mode)
+		}
+	}
+}
+
+func TestLoginModeGatesSignIn(t *testing.T) {
+	ctx := context.Background()
+	phone := "+12025550123"
+	creds := domain.Credentials{Phonenumber: phone, Password: "correct horse battery staple"}
+
+	t.Run("password mode refuses codes", func(t *testing.T) {
+		a, _ := newTestService(t, WithLoginMode(LoginModePassword), WithSMSSender(&testSMS{}))
+		if _, err := a.StartOTPLogin(ctx, phone); !errors.Is(err, port.ErrLoginMethodDisabled) {
+			t.Errorf("StartOTPLogin: expected ErrLoginMethodDisabled, got %v", err)
+		}
+		if _, err := a.ValidateOTPLogin(ctx, phone, "123456"); !errors.Is(err, port.ErrLoginMethodDisabled) {
+			t.Errorf("ValidateOTPLogin: expected ErrLoginMethodDisabled, got %v", err)
+		}
+	})
+
+	t.Run("otp mode refuses passwords", func(t *testing.T) {
+		a, _ := newTestService(t, WithLoginMode(LoginModeOTP))
+		if _, err := a.CreateUser(ctx, creds); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if _, err := a.ValidateUser(ctx, creds); !errors.Is(err, port.ErrLoginMethodDisabled) {
+			t.Errorf("ValidateUser: expected ErrLoginMethodDisabled, got %v", err)
+		}
+	})
+}
+
+func TestCreateUserWithoutPassword(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("allowed with code login only", func(t *testing.T) {
+		a, repo := newTestService(t, WithLoginMode(LoginModeOTP))
+		user, err := a.CreateUser(ctx, domain.Credentials{Phonenumber: "202-555-0123"})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if stored := repo.users[user.ID]; stored.Password != "" || stored.Phonenumber != "+12025550123" {
+			t.Errorf("unexpected user %+v", stored)
+		}
+	})
+
+	t.Run("cannot sign in with an empty password", func(t *testing.T) {
+		a, repo := newTestService(t, WithLoginMode(LoginModeEither))
+		repo.users["user-1"] = domain.User{ID: "user-1", Phonenumber: "+12025550123"}
+
+		valid, err := a.ValidateUser(ctx, domain.Credentials{Phonenumber: "+12025550123"})
+		if err != nil || valid {
+			t.Errorf("ValidateUser = %v, %v, want false", valid, err)
+		}
+	})
+
+	t.Run("a password given is still checked", func(t *testing.T) {
+		a, _ := newTestService(t, WithLoginMode(LoginModeOTP))
+		_, err := a.CreateUser(ctx, domain.Credentials{Phonenumber: "+12025550123", Password: "short"})
+		if !errors.Is(err, port.ErrWeakPassword) {
+			t.Errorf("expected ErrWeakPassword, got %v", err)
+		}
+	})
+
+	t.Run("required wherever passwords sign in", func(t *testing.T) {
+		for _, mode := range []LoginMode{LoginModePassword, LoginModeEither} {
+			a, _ := newTestService(t, WithLoginMode(mode))
+			_, err := a.CreateUser(ctx, domain.Credentials{Phonenumber: "+12025550123"})
+			if !errors.Is(err, port.ErrWeakPassword) {
+				t.Errorf("%s: expected ErrWeakPassword, got %v", mode, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+var ErrInvalidClientRegistration = errors.New("invalid client registration")
+
+// authorizationCodeTTL is how long a client has to redeem a code.
+const authorizationCodeTTL = time.Minute
+
+// supportedScopes are the scopes clients may request at /authorize.
+var supportedScopes = []string{"openid", "phone"}
+
+// supportedGrantTypes are the grant types served at the token endpoint.
+var supportedGrantTypes = []string{domain.GrantAuthorizationCode, domain.GrantClientCredentials}
+
+func oauthError(code string, description string) error {
+	return &port.OAuthError{Code: code, Description: description}
+}
+
+// RegisterClient adds an application that may sign users in with OpenID
+// Connect, or a backend job using the client credentials grant. Redirect URIs
+// must be absolute and are matched exactly. Clients allowed the client
+// credentials grant are confidential and get a secret.
+func (a *authService) RegisterClient(ctx context.Context, registration domain.ClientRegistration) (*domain.Client, string, error) {
+	grantTypes := registration.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{domain.GrantAuthorizationCode}
+	}
+
+	if registration.Name == "" {
+		return nil, "", ErrInvalidClientRegistration
+	}
+
+	for _, grantType := range grantTypes {
+		if !slices.Contains(supportedGrantTypes, grantType) {
+			return nil, "", fmt.Errorf("%w: unsupported grant type %q", ErrInvalidClientRegistration, grantType)
+		}
+	}
+
+	if slices.Contains(grantTypes, domain.GrantAuthorizationCode) && len(registration.RedirectURIs) == 0 {
+		return nil, "", fmt.Errorf("%w: the authorization code grant needs a redirect uri", ErrInvalidClientRegistration)
+	}
+
+	for _, redirectURI := range registration.RedirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Host == "" || parsed.Fragment != "" {
+			return nil, "", fmt.Errorf("%w: %q", port.ErrInvalidRedirectURI, redirectURI)
+		}
+	}
+
+	for _, scope := range registration.Scopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\n\"\\") {
+			return nil, "", fmt.Errorf("%w: invalid scope %q", ErrInvalidClientRegistration, scope)
+		}
+	}
+
+	client := &domain.Client{
+		ID:           generateUniqueID(),
+		Name:         registration.Name,
+		RedirectURIs: registration.RedirectURIs,
+		GrantTypes:   grantTypes,
+		Scopes:       registration.Scopes,
+		CreatedAt:    time.Now(),
+	}
+
+	var secret string
+	if slices.Contains(grantTypes, domain.GrantClientCredentials) {
+		secret = generateToken()
+
+		secretHash, err := a.hasher.hashSecret(ctx, secret)
+		if err != nil {
+			return nil, "", fmt.Errorf("client secret hashing failed: %w", err)
+		}
+		client.SecretHash = secretHash
+	}
+
+	if err := a.authRepo.SaveClient(ctx, *client); err != nil {
+		return nil, "", fmt.Errorf("client registration failed: %w", err)
+	}
+
+	return client, secret, nil
+}
+
+func (a *authService) ListClients(ctx context.Context) ([]domain.Client, error) {
+	clients, err := a.authRepo.ListClients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("client listing failed: %w", err)
+	}
+	return clients, nil
+}
+
+func (a *authService) DeleteClient(ctx context.Context, id string) error {
+	if err := a.authRepo.DeleteClient(ctx, id); err != nil {
+		if errors.Is(err, port.ErrClientNotFound) {
+			return port.ErrClientNotFound
+		}
+		return fmt.Errorf("client deletion failed: %w", err)
+	}
+	return nil
+}
+
+// ValidateAuthorizationRequest makes sure the client exists and registered
+// the redirect URI, so errors can safely be sent there.
+func (a *authService) ValidateAuthorizationRequest(ctx context.Context, req domain.AuthorizationRequest) (*domain.Client, error) {
+	client, err := a.readClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, port.ErrInvalidRedirectURI
+	}
+
+	return client, nil
+}
+
+// Authorize issues an authorization code bound to the session's user and the
+// PKCE challenge of the request.
+func (a *authService) Authorize(ctx context.Context, req domain.AuthorizationRequest, session *domain.Session) (*domain.AuthorizationCode, error) {
+	if req.ResponseType != "code" {
+		return nil, oauthError(port.OAuthUnsupportedResponseType, "only the code response type is supported")
+	}
+
+	scope, err := parseScope(req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CodeChallenge == "" {
+		return nil, oauthError(port.OAuthInvalidRequest, "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != "S256" {
+		return nil, oauthError(port.OAuthInvalidRequest, "code_challenge_method must be S256")
+	}
+
+	if session == nil {
+		return nil, oauthError(port.OAuthLoginRequired, "the user is not signed in")
+	}
+
+	now := time.Now()
+	code := &domain.AuthorizationCode{
+		Code:                generateToken(),
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		UserID:              session.UserID,
+		SessionID:           session.ID,
+		SessionExpiresAt:    session.ExpiresAt,
+		AuthTime:            sessionSignedInAt(session),
+		Scope:               scope,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		ExpiresAt:           now.Add(authorizationCodeTTL),
+	}
+
+	if err := a.authRepo.SaveAuthorizationCode(ctx, *code); err != nil {
+		return nil, fmt.Errorf("authorization code persistence failed: %w", err)
+	}
+
+	return code, nil
+}
+
+// ExchangeToken serves the token endpoint.
+func (a *authService) ExchangeToken(ctx context.Context, req domain.TokenRequest) (*domain.TokenResponse, error) {
+	if a.tokens == nil {
+		return nil, errors.New("token issuer not configured")
+	}
+
+	switch req.GrantType {
+	case domain.GrantAuthorizationCode:
+		return a.exchangeAuthorizationCode(ctx, req)
+	case domain.GrantClientCredentials:
+		return a.exchangeClientCredentials(ctx, req)
+	default:
+		return nil, oauthError(port.OAuthUnsupportedGrantType, "unsupported grant_type")
+	}
+}
+
+func (a *authService) exchangeAuthorizationCode(ctx context.Context, req domain.TokenRequest) (*domain.TokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, oauthError(port.OAuthInvalidRequest, "code and code_verifier are required")
+	}
+
+	if _, err := a.authenticateGrant(ctx, req); err != nil {
+		return nil, err
+	}
+
+	code, err := a.authRepo.ConsumeAuthorizationCode(ctx, req.Code)
+	if err != nil {
+		if errors.Is(err, port.ErrAuthorizationCodeNotFound) {
+			return nil, oauthError(port.OAuthInvalidGrant, "unknown or already used code")
+		}
+		return nil, fmt.Errorf("authorization code lookup failed: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case code.ClientID != req.ClientID:
+		return nil, oauthError(port.OAuthInvalidGrant, "code was issued to another client")
+	case code.RedirectURI != req.RedirectURI:
+		return nil, oauthError(port.OAuthInvalidGrant, "redirect_uri does not match the authorization request")
+	case now.After(code.ExpiresAt):
+		return nil, oauthError(port.OAuthInvalidGrant, "code expired")
+	case !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge):
+		return nil, oauthError(port.OAuthInvalidGrant, "code_verifier does not match code_challenge")
+	}
+
+	revoked, err := a.authRepo.IsSessionRevoked(ctx, code.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("revocation check failed: %w", err)
+	}
+
+	// Tokens must not outlive the session the user signed in with
+	expiresAt := now.Add(a.tokens.ttl)
+	if code.SessionExpiresAt.Before(expiresAt) {
+		expiresAt = code.SessionExpiresAt
+	}
+	if revoked || !expiresAt.After(now) {
+		return nil, oauthError(port.OAuthInvalidGrant, "the session has ended")
+	}
+
+	user, err := a.authRepo.ReadUserByID(ctx, code.UserID)
+	if err != nil {
+		if errors.Is(err, port.ErrUserNotFound) {
+			return nil, oauthError(port.OAuthInvalidGrant, "the user no longer exists")
+		}
+		return nil, fmt.Errorf("user lookup failed: %w", err)
+	}
+
+	accessToken, err := a.tokens.Issue(ctx, domain.TokenClaims{
+		Subject:   user.ID,
+		SessionID: code.SessionID,
+		Roles:     user.Roles,
+		Scope:     code.Scope,
+		ClientID:  code.ClientID,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("access token signing failed: %w", err)
+	}
+
+	response := &domain.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(expiresAt.Sub(now).Seconds()),
+		Scope:       code.Scope,
+	}
+
+	if hasScope(code.Scope, "openid") {
+		claims := domain.TokenClaims{
+			Subject:   user.ID,
+			Audience:  code.ClientID,
+			SessionID: code.SessionID,
+			AuthTime:  code.AuthTime.Unix(),
+			Nonce:     code.Nonce,
+		}
+		if hasScope(code.Scope, "phone") {
+			claims.PhoneNumber = user.Phonenumber
+		}
+
+		response.IDToken, err = a.tokens.IssueIDToken(ctx, claims)
+		if err != nil {
+			return nil, fmt.Errorf("id token signing failed: %w", err)
+		}
+	}
+
+	return response, nil
+}
+
+// exchangeClientCredentials issues a backend job a token of its own, limited
+// to the scopes it was registered with.
+func (a *authService) exchangeClientCredentials(ctx context.Context, req domain.TokenRequest) (*domain.TokenResponse, error) {
+	client, err := a.authenticateGrant(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := client.Scopes
+	if req.Scope != "" {
+		scopes = nil
+		for _, scope := range strings.Fields(req.Scope) {
+			if !slices.Contains(client.Scopes, scope) {
+				return nil, oauthError(port.OAuthInvalidScope, fmt.Sprintf("scope %q is not allowed for this client", scope))
+			}
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	scope := strings.Join(scopes, " ")
+
+	expiresAt := time.Now().Add(a.tokens.ttl)
+	accessToken, err := a.tokens.Issue(ctx, domain.TokenClaims{
+		Subject:   client.ID,
+		Scope:     scope,
+		ClientID:  client.ID,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("access token signing failed: %w", err)
+	}
+
+	return &domain.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(a.tokens.ttl.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// authenticateClient identifies the client making a token endpoint request.
+// Confidential clients must present their secret; public ones are identified
+// by client_id alone.
+func (a *authService) authenticateClient(ctx context.Context, clientID string, clientSecret string) (*domain.Client, error) {
+	client, err := a.readClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, port.ErrClientNotFound) {
+			return nil, oauthError(port.OAuthInvalidClient, "client authentication failed")
+		}
+		return nil, err
+	}
+
+	if client.SecretHash == "" {
+		return client, nil
+	}
+
+	if clientSecret == "" {
+		return nil, oauthError(port.OAuthInvalidClient, "client authentication failed")
+	}
+
+	// Client secrets are random, so outdated hashes of them are left as is
+	match, _, err := a.hasher.verify(ctx, clientSecret, client.SecretHash)
+	if err != nil {
+		return nil, fmt.Errorf("client secret comparison failed: %w", err)
+	}
+	if !match {
+		return nil, oauthError(port.OAuthInvalidClient, "client authentication failed")
+	}
+
+	return client, nil
+}
+
+// authenticateGrant authenticates the client of a token request and checks
+// it may use the requested grant.
+func (a *authService) authenticateGrant(ctx context.Context, req domain.TokenRequest) (*domain.Client, error) {
+	client, err := a.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.SecretHash == "" && req.GrantType == domain.GrantClientCredentials {
+		return nil, oauthError(port.OAuthUnauthorizedClient, "public clients cannot use the client credentials grant")
+	}
+
+	grantTypes := client.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{domain.GrantAuthorizationCode}
+	}
+	if !slices.Contains(grantTypes, req.GrantType) {
+		return nil, oauthError(port.OAuthUnauthorizedClient, "the client may not use this grant type")
+	}
+
+	return client, nil
+}
+
+// UserInfo returns the claims about the user an access token grants.
+func (a *authService) UserInfo(ctx context.Context, accessToken string) (*domain.UserInfo, error) {
+	claims, err := a.VerifyAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.authRepo.ReadUserByID(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, port.ErrUserNotFound) {
+			return nil, port.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("user lookup failed: %w", err)
+	}
+
+	info := &domain.UserInfo{Subject: user.ID}
+	if hasScope(claims.Scope, "phone") {
+		info.PhoneNumber = user.Phonenumber
+	}
+
+	return info, nil
+}
+
+func (a *authService) readClient(ctx context.Context, id string) (*domain.Client, error) {
+	if id == "" {
+		return nil, port.ErrClientNotFound
+	}
+
+	client, err := a.authRepo.ReadClient(ctx, id)
+	if err != nil {
+		if errors.Is(err, port.ErrClientNotFound) {
+			return nil, port.ErrClientNotFound
+		}
+		return nil, fmt.Errorf("client lookup failed: %w", err)
+	}
+
+	return client, nil
+}
+
+// parseScope validates a space-separated scope list against supportedScopes
+// and returns it without duplicates.
+func parseScope(scope string) (string, error) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(supportedScopes, s) {
+			return "", oauthError(port.OAuthInvalidScope, fmt.Sprintf("unsupported scope %q", s))
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+func hasScope(scope string, want string) bool {
+	return slices.Contains(strings.Fields(scope), want)
+}
+
+// verifyCodeChallenge checks a PKCE code verifier against its S256
+// challenge (RFC 7636 section 4.6).
+func verifyCodeChallenge(verifier string, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
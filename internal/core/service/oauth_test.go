@@ -0,0 +1,434 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !verifyCodeChallenge(verifier, challenge) {
+		t.Fatal("expected the RFC 7636 example verifier to match")
+	}
+	if verifyCodeChallenge(verifier+"x", challenge) {
+		t.Fatal("expected a different verifier not to match")
+	}
+	if verifyCodeChallenge("short", challenge) {
+		t.Fatal("expected a verifier shorter than 43 characters to be rejected")
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	scope, err := parseScope("openid phone openid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scope != "openid phone" {
+		t.Fatalf("expected duplicates to be dropped, got %q", scope)
+	}
+
+	if _, err := parseScope("openid email"); err == nil {
+		t.Fatal("expected an unsupported scope to be rejected")
+	}
+}
+
+// oauthErrorCode returns the OAuth error code err carries, or "".
+func oauthErrorCode(err error) string {
+	var oauthErr *port.OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Code
+	}
+	return ""
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	ctx := context.Background()
+	a, repo := newTestService(t, WithTokenIssuer(newTestIssuer(t)))
+
+	client, secret, err := a.RegisterClient(ctx, domain.ClientRegistration{
+		Name:       "reports job",
+		GrantTypes: []string{domain.GrantClientCredentials},
+		Scopes:     []string{"reports:read", "reports:write"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+	if secret == "" {
+		t.Fatal("expected a confidential client to get a secret")
+	}
+	if stored := repo.clients[client.ID]; stored.SecretHash == "" || stored.SecretHash == secret {
+		t.Fatalf("expected only a hash of the secret stored, got %q", stored.SecretHash)
+	}
+
+	t.Run("exchanges the secret for a token", func(t *testing.T) {
+		response, err := a.ExchangeToken(ctx, domain.TokenRequest{
+			GrantType:    domain.GrantClientCredentials,
+			ClientID:     client.ID,
+			ClientSecret: secret,
+		})
+		if err != nil {
+			t.Fatalf("ExchangeToken: %v", err)
+		}
+		if response.Scope != "reports:read reports:write" {
+			t.Errorf("scope = %q, want every registered scope", response.Scope)
+		}
+
+		claims, err := a.VerifyAccessToken(ctx, response.AccessToken)
+		if err != nil {
+			t.Fatalf("VerifyAccessToken: %v", err)
+		}
+		if claims.Subject != client.ID || claims.ClientID != client.ID || claims.SessionID != "" {
+			t.Errorf("unexpected claims: %+v", claims)
+		}
+	})
+
+	t.Run("narrows the scope", func(t *testing.T) {
+		response, err := a.ExchangeToken(ctx, domain.TokenRequest{
+			GrantType:    domain.GrantClientCredentials,
+			ClientID:     client.ID,
+			ClientSecret: secret,
+			Scope:        "reports:read",
+		})
+		if err != nil {
+			t.Fatalf("ExchangeToken: %v", err)
+		}
+		if response.Scope != "reports:read" {
+			t.Errorf("scope = %q, want reports:read", response.Scope)
+		}
+	})
+
+	t.Run("rejects scopes it was not registered with", func(t *testing.T) {
+		_, err := a.ExchangeToken(ctx, domain.TokenRequest{
+			GrantType:    domain.GrantClientCredentials,
+			ClientID:     client.ID,
+			ClientSecret: secret,
+			Scope:        "reports:read admin",
+		})
+		if code := oauthErrorCode(err); code != port.OAuthInvalidScope {
+			t.Fatalf("expected %s, got %v", port.OAuthInvalidScope, err)
+		}
+	})
+
+	t.Run("rejects a wrong or missing secret", func(t *testing.T) {
+		for _, attempt := range []string{"", secret + "x"} {
+			_, err := a.ExchangeToken(ctx, domain.TokenRequest{
+				GrantType:    domain.GrantClientCredentials,
+				ClientID:     client.ID,
+				ClientSecret: attempt,
+			})
+			if code := oauthErrorCode(err); code != port.OAuthInvalidClient {
+				t.Fatalf("secret %q: expected %s, got %v", attempt, port.OAuthInvalidClient, err)
+			}
+		}
+	})
+
+	t.Run("refuses public clients", func(t *testing.T) {
+		public, _, err := a.RegisterClient(ctx, domain.ClientRegistration{
+			Name:         "web app",
+			RedirectURIs: []string{"https://app.test/callback"},
+		})
+		if err != nil {
+			t.Fatalf("RegisterClient: %v", err)
+		}
+
+		_, err = a.ExchangeToken(ctx, domain.TokenRequest{
+			GrantType: domain.GrantClientCredentials,
+			ClientID:  public.ID,
+		})
+		if code := oauthErrorCode(err); code != port.OAuthUnauthorizedClient {
+			t.Fatalf("expected %s, got %v", port.OAuthUnauthorizedClient, err)
+		}
+	})
+}
+
+// newOAuthTestService returns a service that issues access tokens, with
+// user-1, a confidential client and its secret and a public client
+// registered.
+func newOAuthTestService(t *testing.T) (a *authService, repo *testRepo, confidential *domain.Client, secret string, public *domain.Client) {
+	t.Helper()
+	ctx := context.Background()
+
+	a, repo = newTestService(t, WithTokenIssuer(newTestIssuer(t)))
+	repo.users["user-1"] = domain.User{ID: "user-1", Phonenumber: "+12025550123"}
+
+	confidential, secret, err := a.RegisterClient(ctx, domain.ClientRegistration{
+		Name:       "resource server",
+		GrantTypes: []string{domain.GrantClientCredentials},
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+
+	public, _, err = a.RegisterClient(ctx, domain.ClientRegistration{
+		Name:         "web app",
+		RedirectURIs: []string{"https://app.test/callback"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+
+	return a, repo, confidential, secret, public
+}
+
+// parseIDToken checks an ID token's signature and returns its claims.
+func parseIDToken(t *testing.T, a *authService, token string) *domain.TokenClaims {
+	t.Helper()
+
+	published, err := a.tokens.keys.PublishedKeys(context.Background())
+	if err != nil {
+		t.Fatalf("PublishedKeys: %v", err)
+	}
+
+	claims := &domain.TokenClaims{}
+	err = parseJWT(token, idTokenType, func(keyID string) (ed25519.PublicKey, bool) {
+		for _, key := range published {
+			if key.ID == keyID {
+				return ed25519.PublicKey(key.PublicKey), true
+			}
+		}
+		return nil, false
+	}, claims)
+	if err != nil {
+		t.Fatalf("parsing ID token: %v", err)
+	}
+	return claims
+}
+
+func TestAuthorizationCodeGrant(t *testing.T) {
+	ctx := context.Background()
+	device := domain.Device{UserAgent: "test", IPAddress: "192.0.2.1"}
+	// RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	a, repo, _, _, public := newOAuthTestService(t)
+	other, _, err := a.RegisterClient(ctx, domain.ClientRegistration{
+		Name:         "other app",
+		RedirectURIs: []string{"https://other.test/callback"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+
+	session, err := a.CreateSession(ctx, "user-1", device)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	authorize := func(t *testing.T, session *domain.Session, scope string) *domain.AuthorizationCode {
+		t.Helper()
+		code, err := a.Authorize(ctx, domain.AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            public.ID,
+			RedirectURI:         "https://app.test/callback",
+			Scope:               scope,
+			Nonce:               "nonce-1",
+			CodeChallenge:       challenge,
+			CodeChallengeMethod: "S256",
+		}, session)
+		if err != nil {
+			t.Fatalf("Authorize: %v", err)
+		}
+		return code
+	}
+	exchange := func(code string) domain.TokenRequest {
+		return domain.TokenRequest{
+			GrantType:    domain.GrantAuthorizationCode,
+			Code:         code,
+			RedirectURI:  "https://app.test/callback",
+			ClientID:     public.ID,
+			CodeVerifier: verifier,
+		}
+	}
+
+	t.Run("issues an ID token for the client and sign in", func(t *testing.T) {
+		code := authorize(t, session, "openid phone")
+
+		response, err := a.ExchangeToken(ctx, exchange(code.Code))
+		if err != nil {
+			t.Fatalf("ExchangeToken: %v", err)
+		}
+		if response.Scope != "openid phone" || response.IDToken == "" {
+			t.Fatalf("expected an ID token for openid phone, got %+v", response)
+		}
+
+		claims := parseIDToken(t, a, response.IDToken)
+		if claims.Subject != "user-1" || claims.Audience != public.ID || claims.Nonce != "nonce-1" {
+			t.Errorf("unexpected ID token claims: %+v", claims)
+		}
+		if claims.AuthTime != session.SignedInAt.Unix() {
+			t.Errorf("auth_time = %d, want the sign in at %d", claims.AuthTime, session.SignedInAt.Unix())
+		}
+		if claims.PhoneNumber != "+12025550123" {
+			t.Errorf("phone_number = %q, want the user's number", claims.PhoneNumber)
+		}
+	})
+
+	t.Run("needs the openid scope for an ID token", func(t *testing.T) {
+		response, err := a.ExchangeToken(ctx, exchange(authorize(t, session, "phone").Code))
+		if err != nil {
+			t.Fatalf("ExchangeToken: %v", err)
+		}
+		if response.IDToken != "" {
+			t.Fatal("expected no ID token without openid")
+		}
+	})
+
+	t.Run("rejects a wrong code verifier", func(t *testing.T) {
+		req := exchange(authorize(t, session, "openid").Code)
+		req.CodeVerifier = "a-different-verifier-that-is-long-enough-to-be-valid"
+
+		_, err := a.ExchangeToken(ctx, req)
+		if code := oauthErrorCode(err); code != port.OAuthInvalidGrant {
+			t.Fatalf("expected %s, got %v", port.OAuthInvalidGrant, err)
+		}
+	})
+
+	t.Run("codes work once", func(t *testing.T) {
+		code := authorize(t, session, "openid")
+		if _, err := a.ExchangeToken(ctx, exchange(code.Code)); err != nil {
+			t.Fatalf("ExchangeToken: %v", err)
+		}
+
+		_, err := a.ExchangeToken(ctx, exchange(code.Code))
+		if code := oauthErrorCode(err); code != port.OAuthInvalidGrant {
+			t.Fatalf("expected %s on reuse, got %v", port.OAuthInvalidGrant, err)
+		}
+	})
+
+	t.Run("rejects another client", func(t *testing.T) {
+		req := exchange(authorize(t, session, "openid").Code)
+		req.ClientID = other.ID
+		req.RedirectURI = "https://other.test/callback"
+
+		_, err := a.ExchangeToken(ctx, req)
+		if code := oauthErrorCode(err); code != port.OAuthInvalidGrant {
+			t.Fatalf("expected %s, got %v", port.OAuthInvalidGrant, err)
+		}
+	})
+
+	t.Run("rejects another redirect URI", func(t *testing.T) {
+		req := exchange(authorize(t, session, "openid").Code)
+		req.RedirectURI = "https://app.test/elsewhere"
+
+		_, err := a.ExchangeToken(ctx, req)
+		if code := oauthErrorCode(err); code != port.OAuthInvalidGrant {
+			t.Fatalf("expected %s, got %v", port.OAuthInvalidGrant, err)
+		}
+	})
+
+	t.Run("rejects an expired code", func(t *testing.T) {
+		code := authorize(t, session, "openid")
+		stored := repo.authCode[code.Code]
+		stored.ExpiresAt = time.Now().Add(-time.Second)
+		repo.authCode[code.Code] = stored
+
+		_, err := a.ExchangeToken(ctx, exchange(code.Code))
+		if code := oauthErrorCode(err); code != port.OAuthInvalidGrant {
+			t.Fatalf("expected %s, got %v", port.OAuthInvalidGrant, err)
+		}
+	})
+
+	t.Run("rejects a code of a revoked session", func(t *testing.T) {
+		revoked, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		code := authorize(t, revoked, "openid")
+		if err := repo.DeleteSession(ctx, revoked.Token); err != nil {
+			t.Fatalf("DeleteSession: %v", err)
+		}
+
+		_, err = a.ExchangeToken(ctx, exchange(code.Code))
+		if code := oauthErrorCode(err); code != port.OAuthInvalidGrant {
+			t.Fatalf("expected %s, got %v", port.OAuthInvalidGrant, err)
+		}
+	})
+
+	t.Run("requires a signed in user", func(t *testing.T) {
+		_, err := a.Authorize(ctx, domain.AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            public.ID,
+			RedirectURI:         "https://app.test/callback",
+			CodeChallenge:       challenge,
+			CodeChallengeMethod: "S256",
+		}, nil)
+		if code := oauthErrorCode(err); code != port.OAuthLoginRequired {
+			t.Fatalf("expected %s, got %v", port.OAuthLoginRequired, err)
+		}
+	})
+}
+
+func TestUserInfo(t *testing.T) {
+	ctx := context.Background()
+	device := domain.Device{UserAgent: "test", IPAddress: "192.0.2.1"}
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	a, _, _, _, public := newOAuthTestService(t)
+	session, err := a.CreateSession(ctx, "user-1", device)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	accessToken := func(t *testing.T, scope string) string {
+		t.Helper()
+		code, err := a.Authorize(ctx, domain.AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            public.ID,
+			RedirectURI:         "https://app.test/callback",
+			Scope:               scope,
+			CodeChallenge:       challenge,
+			CodeChallengeMethod: "S256",
+		}, session)
+		if err != nil {
+			t.Fatalf("Authorize: %v", err)
+		}
+		response, err := a.ExchangeToken(ctx, domain.TokenRequest{
+			GrantType:    domain.GrantAuthorizationCode,
+			Code:         code.Code,
+			RedirectURI:  "https://app.test/callback",
+			ClientID:     public.ID,
+			CodeVerifier: verifier,
+		})
+		if err != nil {
+			t.Fatalf("ExchangeToken: %v", err)
+		}
+		return response.AccessToken
+	}
+
+	t.Run("phone scope includes the number", func(t *testing.T) {
+		info, err := a.UserInfo(ctx, accessToken(t, "openid phone"))
+		if err != nil {
+			t.Fatalf("UserInfo: %v", err)
+		}
+		if info.Subject != "user-1" || info.PhoneNumber != "+12025550123" {
+			t.Fatalf("unexpected user info: %+v", info)
+		}
+	})
+
+	t.Run("without the phone scope only the subject", func(t *testing.T) {
+		info, err := a.UserInfo(ctx, accessToken(t, "openid"))
+		if err != nil {
+			t.Fatalf("UserInfo: %v", err)
+		}
+		if info.Subject != "user-1" || info.PhoneNumber != "" {
+			t.Fatalf("unexpected user info: %+v", info)
+		}
+	})
+
+	t.Run("rejects an invalid token", func(t *testing.T) {
+		if _, err := a.UserInfo(ctx, "not-a-token"); err == nil {
+			t.Fatal("expected an invalid token to be rejected")
+		}
+	})
+}
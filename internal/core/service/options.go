@@ -0,0 +1,114 @@
+package service
+
+import "github.com/mar-cial/space-auth/internal/core/port"
+
+// Option customises the auth service built by NewAuthService.
+type Option func(*authService)
+
+// WithSessionPolicy sets the idle timeout and maximum lifetime of sessions.
+func WithSessionPolicy(policy SessionPolicy) Option {
+	return func(a *authService) {
+		a.sessionPolicy = policy
+	}
+}
+
+// WithTokenIssuer makes every new session come with a signed JWT access
+// token.
+func WithTokenIssuer(issuer *TokenIssuer) Option {
+	return func(a *authService) {
+		a.tokens = issuer
+	}
+}
+
+// WithPhoneNormalizer sets how phone numbers are normalized to E.164,
+// notably which region national numbers belong to.
+func WithPhoneNormalizer(phones *PhoneNormalizer) Option {
+	return func(a *authService) {
+		a.phones = phones
+	}
+}
+
+// WithSMSSender sets how one-time codes reach users. Without one, flows that
+// text codes fail with ErrSMSUnavailable.
+func WithSMSSender(sender port.SMSSender) Option {
+	return func(a *authService) {
+		a.sms = sender
+	}
+}
+
+// WithOneTimeCodePolicy sets the length, lifetime and limits of texted codes.
+func WithOneTimeCodePolicy(policy OneTimeCodePolicy) Option {
+	return func(a *authService) {
+		a.codePolicy = policy
+	}
+}
+
+// WithLoginMode sets whether users sign in with passwords, one-time codes or
+// either.
+func WithLoginMode(mode LoginMode) Option {
+	return func(a *authService) {
+		a.loginMode = mode
+	}
+}
+
+// WithPasswordPolicy sets which new passwords users may choose.
+func WithPasswordPolicy(policy PasswordPolicy) Option {
+	return func(a *authService) {
+		a.passwordPolicy = policy
+	}
+}
+
+// WithBreachedPasswordChecker rejects new passwords known from data breaches.
+func WithBreachedPasswordChecker(checker port.BreachedPasswordChecker) Option {
+	return func(a *authService) {
+		a.breachedPasswords = checker
+	}
+}
+
+// WithArgon2Params sets the Argon2id parameters of new password hashes.
+// Hashes made with weaker parameters are upgraded when their users sign in.
+func WithArgon2Params(params Argon2Params) Option {
+	return func(a *authService) {
+		a.hasher.params = params
+	}
+}
+
+// WithPeppers keys password hashes with server-side secrets, oldest first.
+// New hashes use the last one; hashes made with another are upgraded when
+// their users sign in, after which the old pepper can be dropped.
+func WithPeppers(peppers []Pepper) Option {
+	return func(a *authService) {
+		a.hasher.peppers = peppers
+	}
+}
+
+// WithLegacyHashers sets which formats of hashes imported from other systems
+// are understood, replacing DefaultLegacyHashers.
+func WithLegacyHashers(hashers ...LegacyHasher) Option {
+	return func(a *authService) {
+		a.hasher.legacy = hashers
+	}
+}
+
+// WithHashingPool bounds how many password hashes are computed at once and
+// how many more may wait.
+func WithHashingPool(policy HashingPoolPolicy) Option {
+	return func(a *authService) {
+		a.hasher.pool = newHashingPool(policy)
+	}
+}
+
+// WithLockoutPolicy sets how failed sign ins slow down and lock out further
+// attempts with the same phone number.
+func WithLockoutPolicy(policy LockoutPolicy) Option {
+	return func(a *authService) {
+		a.lockout = policy
+	}
+}
+
+// WithSecurityNotifiers tells notifiers about every security event recorded.
+func WithSecurityNotifiers(notifiers ...port.SecurityNotifier) Option {
+	return func(a *authService) {
+		a.notifiers = append(a.notifiers, notifiers...)
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+var ErrSMSUnavailable = errors.New("no sms sender configured")
+
+// OneTimeCodePolicy governs the numeric codes texted to users. A code lives
+// for TTL and allows MaxAttempts guesses. No phone number is sent more than
+// MaxSends codes per SendWindow, whatever they are for, nor more than one
+// per ResendInterval.
+type OneTimeCodePolicy struct {
+	Length         int
+	TTL            time.Duration
+	MaxAttempts    int
+	MaxSends       int
+	SendWindow     time.Duration
+	ResendInterval time.Duration
+}
+
+func DefaultOneTimeCodePolicy() OneTimeCodePolicy {
+	return OneTimeCodePolicy{
+		Length:         6,
+		TTL:            10 * time.Minute,
+		MaxAttempts:    5,
+		MaxSends:       5,
+		SendWindow:     time.Hour,
+		ResendInterval: 30 * time.Second,
+	}
+}
+
+// issueCode creates a code for purpose and subject, replacing any
+// outstanding one, and texts it to phone. message formats the text around
+// the code.
+func (a *authService) issueCode(ctx context.Context, code domain.OneTimeCode, message func(code string) string) (time.Time, error) {
+	if a.sms == nil {
+		return time.Time{}, ErrSMSUnavailable
+	}
+
+	if err := a.limitSends(ctx, code.Phonenumber); err != nil {
+		return time.Time{}, err
+	}
+
+	plain, err := generateCode(a.codePolicy.Length)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	code.Code = plain
+	code.CodeHash = hashCode(a.hasher.currentPepper(), code.Purpose, code.Subject, plain)
+	code.Attempts = 0
+	code.ExpiresAt = time.Now().Add(a.codePolicy.TTL)
+
+	if err := a.authRepo.SaveOneTimeCode(ctx, code); err != nil {
+		return time.Time{}, fmt.Errorf("one-time code persistence failed: %w", err)
+	}
+
+	if err := a.sms.SendSMS(ctx, code.Phonenumber, message(plain)); err != nil {
+		return time.Time{}, fmt.Errorf("sms delivery failed: %w", err)
+	}
+
+	return code.ExpiresAt, nil
+}
+
+// issueCodeByPhone texts a code for purpose to the account registered with
+// the phone number. Unknown numbers get the same answer, and count against
+// the same limits, so callers cannot find out who has an account.
+func (a *authService) issueCodeByPhone(ctx context.Context, purpose string, phonenumber string, message func(code string) string) (time.Time, error) {
+	phone, err := a.phones.Normalize(phonenumber)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	user, err := a.authRepo.ReadUserByPhone(ctx, phone)
+	if err != nil && !errors.Is(err, port.ErrUserNotFound) {
+		return time.Time{}, fmt.Errorf("user lookup failed: %w", err)
+	}
+
+	if user == nil {
+		if err := a.limitSends(ctx, phone); err != nil {
+			return time.Time{}, err
+		}
+		return time.Now().Add(a.codePolicy.TTL), nil
+	}
+
+	return a.issueCode(ctx, domain.OneTimeCode{
+		Purpose:     purpose,
+		Subject:     user.ID,
+		UserID:      user.ID,
+		Phonenumber: user.Phonenumber,
+	}, message)
+}
+
+// redeemCodeByPhone redeems a code issued by issueCodeByPhone and returns the
+// user it was issued to. Unknown numbers look like wrong codes.
+func (a *authService) redeemCodeByPhone(ctx context.Context, purpose string, phonenumber string, code string) (*domain.User, error) {
+	user, err := a.authRepo.ReadUserByPhone(ctx, a.lookupPhone(phonenumber))
+	if err != nil && !errors.Is(err, port.ErrUserNotFound) {
+		return nil, fmt.Errorf("user lookup failed: %w", err)
+	}
+	if user == nil {
+		return nil, port.ErrInvalidCode
+	}
+
+	record, err := a.redeemCode(ctx, purpose, user.ID, code)
+	if err != nil {
+		return nil, err
+	}
+
+	// The number changed since the code was sent
+	if record.Phonenumber != user.Phonenumber {
+		return nil, port.ErrInvalidCode
+	}
+
+	return user, nil
+}
+
+// redeemCode checks a code for purpose and subject and consumes it. Every
+// call counts as an attempt; once they run out the code is discarded.
+func (a *authService) redeemCode(ctx context.Context, purpose string, subject string, code string) (*domain.OneTimeCode, error) {
+	record, err := a.authRepo.AttemptOneTimeCode(ctx, purpose, subject)
+	if err != nil {
+		if errors.Is(err, port.ErrOneTimeCodeNotFound) {
+			return nil, port.ErrInvalidCode
+		}
+		return nil, fmt.Errorf("one-time code lookup failed: %w", err)
+	}
+
+	if record.Attempts > a.codePolicy.MaxAttempts {
+		a.discardCode(ctx, purpose, subject)
+		return nil, port.ErrTooManyAttempts
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return nil, port.ErrInvalidCode
+	}
+
+	if !a.codeMatches(purpose, subject, code, record.CodeHash) {
+		if record.Attempts >= a.codePolicy.MaxAttempts {
+			a.discardCode(ctx, purpose, subject)
+		}
+		return nil, port.ErrInvalidCode
+	}
+
+	// Two requests may get here with the same code; only one deletes it
+	deleted, err := a.authRepo.DeleteOneTimeCode(ctx, purpose, subject)
+	if err != nil {
+		return nil, fmt.Errorf("one-time code deletion failed: %w", err)
+	}
+	if !deleted {
+		return nil, port.ErrInvalidCode
+	}
+
+	return record, nil
+}
+
+func (a *authService) discardCode(ctx context.Context, purpose string, subject string) {
+	_, _ = a.authRepo.DeleteOneTimeCode(ctx, purpose, subject)
+}
+
+// limitSends counts a text to phone against the send limits of the policy.
+func (a *authService) limitSends(ctx context.Context, phone string) error {
+	if a.codePolicy.ResendInterval > 0 {
+		if err := a.limitRate(ctx, "sms-resend:"+phone, 1, a.codePolicy.ResendInterval); err != nil {
+			return err
+		}
+	}
+	return a.limitRate(ctx, "sms:"+phone, a.codePolicy.MaxSends, a.codePolicy.SendWindow)
+}
+
+// limitRate allows limit hits on key per window.
+func (a *authService) limitRate(ctx context.Context, key string, limit int, window time.Duration) error {
+	hits, retryAfter, err := a.authRepo.HitRateLimit(ctx, key, window)
+	if err != nil {
+		return fmt.Errorf("rate limit check failed: %w", err)
+	}
+	if hits > int64(limit) {
+		return &port.RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// generateCode returns a uniformly random numeric code of the given length.
+func generateCode(length int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", ErrBadToken
+	}
+	return fmt.Sprintf("%0*s", length, n.String()), nil
+}
+
+// hashCode binds a code to what it was issued for, so a stored hash is
+// useless for any other purpose or subject. With a pepper the hash is keyed,
+// so a copy of the stored codes does not give away the few million
+// candidates; without one it does not protect against such a copy.
+func hashCode(pepper *Pepper, purpose string, subject string, code string) string {
+	sum := sha256.Sum256(pepper.apply(purpose + ":" + subject + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// codeMatches compares code with a stored hash made with any known pepper,
+// so codes outstanding while the peppers rotate keep working.
+func (a *authService) codeMatches(purpose string, subject string, code string, codeHash string) bool {
+	if len(a.hasher.peppers) == 0 {
+		return subtle.ConstantTimeCompare([]byte(hashCode(nil, purpose, subject, code)), []byte(codeHash)) == 1
+	}
+	match := false
+	for i := range a.hasher.peppers {
+		if subtle.ConstantTimeCompare([]byte(hashCode(&a.hasher.peppers[i], purpose, subject, code)), []byte(codeHash)) == 1 {
+			match = true
+		}
+	}
+	return match
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// StartOTPLogin texts a login code to the account registered with the phone
+// number.
+func (a *authService) StartOTPLogin(ctx context.Context, phonenumber string) (time.Time, error) {
+	if !a.loginMode.allowsOTP() {
+		return time.Time{}, port.ErrLoginMethodDisabled
+	}
+
+	return a.issueCodeByPhone(ctx, domain.CodePurposeLogin, phonenumber, func(code string) string {
+		return fmt.Sprintf("%s is your login code. It expires in %d minutes. Never share it with anyone.", code, int(a.codePolicy.TTL.Minutes()))
+	})
+}
+
+// ValidateOTPLogin returns the user a login code was texted to. Signing in
+// this way proves the user owns their phone number, so it is marked
+// verified.
+func (a *authService) ValidateOTPLogin(ctx context.Context, phonenumber string, code string) (*domain.User, error) {
+	if !a.loginMode.allowsOTP() {
+		return nil, port.ErrLoginMethodDisabled
+	}
+
+	user, err := a.redeemCodeByPhone(ctx, domain.CodePurposeLogin, phonenumber, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PhoneVerifiedAt == nil {
+		now := time.Now()
+		user.PhoneVerifiedAt = &now
+		if _, err := a.authRepo.UpdateUser(ctx, *user); err != nil {
+			return nil, fmt.Errorf("user update failed: %w", err)
+		}
+	}
+
+	return user, nil
+}
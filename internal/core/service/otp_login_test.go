@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestOTPLogin(t *testing.T) {
+	ctx := context.Background()
+	phone := "+12025550123"
+
+	setup := func(t *testing.T) (*authService, *testRepo, *testSMS) {
+		t.Helper()
+		sms := &testSMS{}
+		a, repo := newTestService(t,
+			WithLoginMode(LoginModeOTP),
+			WithSMSSender(sms),
+			WithOneTimeCodePolicy(testCodePolicy),
+		)
+		repo.users["user-1"] = domain.User{ID: "user-1", Phonenumber: phone}
+		return a, repo, sms
+	}
+
+	t.Run("signs in with the texted code", func(t *testing.T) {
+		a, repo, sms := setup(t)
+		if _, err := a.StartOTPLogin(ctx, "(202) 555-0123"); err != nil {
+			t.Fatalf("StartOTPLogin: %v", err)
+		}
+
+		user, err := a.ValidateOTPLogin(ctx, "202.555.0123", sms.lastCode(t, phone))
+		if err != nil {
+			t.Fatalf("ValidateOTPLogin: %v", err)
+		}
+		if user.ID != "user-1" {
+			t.Errorf("signed in as %s, want user-1", user.ID)
+		}
+		if repo.users["user-1"].PhoneVerifiedAt == nil {
+			t.Error("expected the phone marked verified")
+		}
+	})
+
+	t.Run("refuses a wrong code", func(t *testing.T) {
+		a, _, sms := setup(t)
+		if _, err := a.StartOTPLogin(ctx, phone); err != nil {
+			t.Fatalf("StartOTPLogin: %v", err)
+		}
+
+		if _, err := a.ValidateOTPLogin(ctx, phone, wrongCode(sms.lastCode(t, phone))); !errors.Is(err, port.ErrInvalidCode) {
+			t.Errorf("expected ErrInvalidCode, got %v", err)
+		}
+	})
+
+	t.Run("unknown numbers look like known ones", func(t *testing.T) {
+		a, repo, sms := setup(t)
+		other := "+12025550199"
+
+		expiresAt, err := a.StartOTPLogin(ctx, other)
+		if err != nil || expiresAt.IsZero() {
+			t.Fatalf("StartOTPLogin = %v, %v, want an expiry", expiresAt, err)
+		}
+		if sms.count(other) != 0 {
+			t.Error("texted a number without an account")
+		}
+		if repo.limits["sms:"+other] != 1 {
+			t.Error("expected the send counted against the number's limit")
+		}
+
+		if _, err := a.ValidateOTPLogin(ctx, other, "123456"); !errors.Is(err, port.ErrInvalidCode) {
+			t.Errorf("expected ErrInvalidCode, got %v", err)
+		}
+	})
+
+	t.Run("refuses the code when the number changed since it was sent", func(t *testing.T) {
+		a, repo, sms := setup(t)
+		if _, err := a.StartOTPLogin(ctx, phone); err != nil {
+			t.Fatalf("StartOTPLogin: %v", err)
+		}
+		code := sms.lastCode(t, phone)
+
+		user := repo.users["user-1"]
+		user.Phonenumber = "+12025550199"
+		repo.users["user-1"] = user
+
+		for _, number := range []string{phone, "+12025550199"} {
+			if _, err := a.ValidateOTPLogin(ctx, number, code); !errors.Is(err, port.ErrInvalidCode) {
+				t.Errorf("%s: expected ErrInvalidCode, got %v", number, err)
+			}
+		}
+	})
+
+	t.Run("login codes only sign in", func(t *testing.T) {
+		a, _, sms := setup(t)
+		if _, err := a.StartOTPLogin(ctx, phone); err != nil {
+			t.Fatalf("StartOTPLogin: %v", err)
+		}
+
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", sms.lastCode(t, phone)); !errors.Is(err, port.ErrInvalidCode) {
+			t.Errorf("expected a login code refused for phone verification, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// testSMS records the texts sent through it.
+type testSMS struct {
+	mu   sync.Mutex
+	sent map[string][]string
+}
+
+func (s *testSMS) SendSMS(ctx context.Context, to string, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent == nil {
+		s.sent = make(map[string][]string)
+	}
+	s.sent[to] = append(s.sent[to], message)
+	return nil
+}
+
+// count returns how many texts were sent to phone.
+func (s *testSMS) count(phone string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sent[phone])
+}
+
+// lastCode returns the code in the last text sent to phone. Every text
+// starts with its code.
+func (s *testSMS) lastCode(t *testing.T, phone string) string {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	texts := s.sent[phone]
+	if len(texts) == 0 {
+		t.Fatalf("no text sent to %s", phone)
+	}
+	return strings.Fields(texts[len(texts)-1])[0]
+}
+
+// testCodePolicy allows as many texts as tests need.
+var testCodePolicy = OneTimeCodePolicy{
+	Length:      6,
+	TTL:         time.Minute,
+	MaxAttempts: 3,
+	MaxSends:    100,
+	SendWindow:  time.Hour,
+}
+
+// wrongCode returns a code of the same length that is not code.
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+func TestGenerateCode(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		code, err := generateCode(6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 6 || strings.Trim(code, "0123456789") != "" {
+			t.Fatalf("expected 6 digits, got %q", code)
+		}
+	}
+}
+
+func TestHashCodeIsBoundToPurposeAndSubject(t *testing.T) {
+	hash := hashCode(nil, "verify_phone", "user-1", "123456")
+	if hash == hashCode(nil, "verify_phone", "user-2", "123456") {
+		t.Fatal("expected the hash to depend on the subject")
+	}
+	if hash == hashCode(nil, "other", "user-1", "123456") {
+		t.Fatal("expected the hash to depend on the purpose")
+	}
+}
+
+func TestCodeMatchesWithPeppers(t *testing.T) {
+	old := Pepper{ID: "old", Secret: []byte("0123456789abcdef")}
+	current := Pepper{ID: "current", Secret: []byte("fedcba9876543210")}
+	a, _ := newTestService(t, WithPeppers([]Pepper{old, current}))
+
+	hash := hashCode(&current, "verify_phone", "user-1", "123456")
+	if hash == hashCode(nil, "verify_phone", "user-1", "123456") {
+		t.Fatal("expected the pepper to key the hash")
+	}
+	if !a.codeMatches("verify_phone", "user-1", "123456", hash) {
+		t.Fatal("expected the code to match its hash")
+	}
+	if !a.codeMatches("verify_phone", "user-1", "123456", hashCode(&old, "verify_phone", "user-1", "123456")) {
+		t.Fatal("expected a hash made with an older pepper to match")
+	}
+	if a.codeMatches("verify_phone", "user-1", "123456", hashCode(nil, "verify_phone", "user-1", "123456")) {
+		t.Fatal("expected an unpeppered hash not to match once peppers are set")
+	}
+	if a.codeMatches("verify_phone", "user-1", "654321", hash) {
+		t.Fatal("expected another code not to match")
+	}
+}
+
+func TestRedeemCode(t *testing.T) {
+	ctx := context.Background()
+	phone := "+12025550123"
+
+	setup := func(t *testing.T) (*authService, *testRepo, *testSMS) {
+		t.Helper()
+		sms := &testSMS{}
+		a, repo := newTestService(t, WithSMSSender(sms), WithOneTimeCodePolicy(testCodePolicy))
+		repo.users["user-1"] = domain.User{ID: "user-1", Phonenumber: phone}
+		if _, err := a.StartPhoneVerification(ctx, "user-1"); err != nil {
+			t.Fatalf("StartPhoneVerification: %v", err)
+		}
+		return a, repo, sms
+	}
+
+	t.Run("accepts the code after wrong guesses within the limit", func(t *testing.T) {
+		a, repo, sms := setup(t)
+		code := sms.lastCode(t, phone)
+
+		for i := 1; i < testCodePolicy.MaxAttempts; i++ {
+			if err := a.ConfirmPhoneVerification(ctx, "user-1", wrongCode(code)); !errors.Is(err, port.ErrInvalidCode) {
+				t.Fatalf("guess %d: expected ErrInvalidCode, got %v", i, err)
+			}
+		}
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", code); err != nil {
+			t.Fatalf("ConfirmPhoneVerification: %v", err)
+		}
+		if repo.users["user-1"].PhoneVerifiedAt == nil {
+			t.Fatal("phone not marked verified")
+		}
+	})
+
+	t.Run("discards the code once attempts run out", func(t *testing.T) {
+		a, repo, sms := setup(t)
+		code := sms.lastCode(t, phone)
+
+		for i := 0; i < testCodePolicy.MaxAttempts; i++ {
+			if err := a.ConfirmPhoneVerification(ctx, "user-1", wrongCode(code)); !errors.Is(err, port.ErrInvalidCode) {
+				t.Fatalf("guess %d: expected ErrInvalidCode, got %v", i+1, err)
+			}
+		}
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", code); !errors.Is(err, port.ErrInvalidCode) {
+			t.Fatalf("expected the right code refused after the last attempt, got %v", err)
+		}
+		if repo.users["user-1"].PhoneVerifiedAt != nil {
+			t.Fatal("phone verified with an exhausted code")
+		}
+	})
+
+	t.Run("refuses attempts past the limit", func(t *testing.T) {
+		a, repo, sms := setup(t)
+		code := sms.lastCode(t, phone)
+
+		// A concurrent guess may count an attempt before the code is discarded
+		record := repo.codes[domain.CodePurposeVerifyPhone+":user-1"]
+		record.Attempts = testCodePolicy.MaxAttempts
+		repo.codes[domain.CodePurposeVerifyPhone+":user-1"] = record
+
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", code); !errors.Is(err, port.ErrTooManyAttempts) {
+			t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+		}
+		if _, ok := repo.codes[domain.CodePurposeVerifyPhone+":user-1"]; ok {
+			t.Fatal("expected the code discarded")
+		}
+	})
+
+	t.Run("refuses expired codes", func(t *testing.T) {
+		a, repo, sms := setup(t)
+		code := sms.lastCode(t, phone)
+
+		record := repo.codes[domain.CodePurposeVerifyPhone+":user-1"]
+		record.ExpiresAt = time.Now().Add(-time.Second)
+		repo.codes[domain.CodePurposeVerifyPhone+":user-1"] = record
+
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", code); !errors.Is(err, port.ErrInvalidCode) {
+			t.Fatalf("expected ErrInvalidCode, got %v", err)
+		}
+	})
+
+	t.Run("codes work once", func(t *testing.T) {
+		a, repo, sms := setup(t)
+		code := sms.lastCode(t, phone)
+
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", code); err != nil {
+			t.Fatalf("ConfirmPhoneVerification: %v", err)
+		}
+
+		user := repo.users["user-1"]
+		user.PhoneVerifiedAt = nil
+		repo.users["user-1"] = user
+
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", code); !errors.Is(err, port.ErrInvalidCode) {
+			t.Fatalf("expected a used code refused, got %v", err)
+		}
+	})
+
+	t.Run("a new code replaces the old one", func(t *testing.T) {
+		a, _, sms := setup(t)
+		old := sms.lastCode(t, phone)
+		if _, err := a.StartPhoneVerification(ctx, "user-1"); err != nil {
+			t.Fatalf("StartPhoneVerification: %v", err)
+		}
+		code := sms.lastCode(t, phone)
+		if old == code {
+			t.Skip("the same code was drawn twice")
+		}
+
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", old); !errors.Is(err, port.ErrInvalidCode) {
+			t.Fatalf("expected the replaced code refused, got %v", err)
+		}
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", code); err != nil {
+			t.Fatalf("ConfirmPhoneVerification: %v", err)
+		}
+	})
+
+	t.Run("refuses the code when the number changed since it was sent", func(t *testing.T) {
+		a, repo, sms := setup(t)
+		code := sms.lastCode(t, phone)
+
+		user := repo.users["user-1"]
+		user.Phonenumber = "+12025550199"
+		repo.users["user-1"] = user
+
+		if err := a.ConfirmPhoneVerification(ctx, "user-1", code); !errors.Is(err, port.ErrInvalidCode) {
+			t.Fatalf("expected ErrInvalidCode, got %v", err)
+		}
+		if repo.users["user-1"].PhoneVerifiedAt != nil {
+			t.Fatal("new number marked verified with a code sent to the old one")
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+// passwordHasher hashes new passwords with the target Argon2id parameters
+// and the newest pepper, and verifies hashes made with any parameters and
+// any known pepper, telling which ones fall short of the target.
+type passwordHasher struct {
+	params Argon2Params
+	// peppers are oldest first; the last one peppers new hashes
+	peppers []Pepper
+	// legacy verify hashes imported from other systems
+	legacy []LegacyHasher
+	// pool bounds how many hashes are computed at once
+	pool *hashingPool
+}
+
+func newPasswordHasher(params Argon2Params) *passwordHasher {
+	return &passwordHasher{
+		params: params,
+		legacy: DefaultLegacyHashers(),
+		pool:   newHashingPool(DefaultHashingPoolPolicy()),
+	}
+}
+
+// currentPepper is the pepper of new hashes, if any.
+func (h *passwordHasher) currentPepper() *Pepper {
+	if len(h.peppers) == 0 {
+		return nil
+	}
+	return &h.peppers[len(h.peppers)-1]
+}
+
+// Validate reports whether Argon2id accepts the parameters.
+func (p Argon2Params) Validate() error {
+	switch {
+	case p.Iterations < 1:
+		return errors.New("argon2 iterations must be at least 1")
+	case p.Parallelism < 1:
+		return errors.New("argon2 parallelism must be at least 1")
+	case p.Memory < 8*uint32(p.Parallelism):
+		return errors.New("argon2 memory must be at least 8 KiB per thread")
+	case p.SaltLength < 8:
+		return errors.New("argon2 salt must be at least 8 bytes")
+	case p.KeyLength < 16:
+		return errors.New("argon2 key must be at least 16 bytes")
+	case p.checkCost() != nil:
+		return errors.New("argon2 parameters exceed the limits hashes are verified with")
+	}
+	return nil
+}
+
+func (h *passwordHasher) hash(ctx context.Context, password string) (string, error) {
+	return h.generate(ctx, password, h.currentPepper())
+}
+
+// hashSecret hashes a random secret, such as a client secret. Peppering
+// adds nothing to secrets that cannot be guessed, and would tie them to a
+// pepper they are never rehashed away from.
+func (h *passwordHasher) hashSecret(ctx context.Context, secret string) (string, error) {
+	return h.generate(ctx, secret, nil)
+}
+
+func (h *passwordHasher) generate(ctx context.Context, password string, pepper *Pepper) (encodedHash string, err error) {
+	if poolErr := h.pool.run(ctx, func() {
+		encodedHash, err = generateFromPassword(password, &h.params, pepper)
+	}); poolErr != nil {
+		return "", poolErr
+	}
+	return encodedHash, err
+}
+
+// verify checks password against encodedHash. rehash is set when the
+// password matched but the hash is weaker than the target parameters, or
+// was imported from another system, so it should be replaced while the
+// password is at hand.
+func (h *passwordHasher) verify(ctx context.Context, password string, encodedHash string) (match bool, rehash bool, err error) {
+	legacy := h.legacyHasher(encodedHash)
+	if legacy == nil {
+		if err := h.checkArgon2Memory(encodedHash); err != nil {
+			return false, false, err
+		}
+	}
+	if poolErr := h.pool.run(ctx, func() {
+		if legacy != nil {
+			match, err = legacy.Verify(password, encodedHash)
+		} else {
+			match, err = comparePasswordAndHash(password, encodedHash, h.peppers)
+		}
+	}); poolErr != nil {
+		return false, false, poolErr
+	}
+
+	if err != nil || !match {
+		return false, false, err
+	}
+	return true, legacy != nil || h.needsRehash(encodedHash), nil
+}
+
+// legacyHasher finds the hasher of an imported hash, limited to the memory
+// of a pool slot. It returns nil for Argon2id hashes and hashes in unknown
+// formats.
+func (h *passwordHasher) legacyHasher(encodedHash string) LegacyHasher {
+	for _, legacy := range h.legacy {
+		if !legacy.Recognizes(encodedHash) {
+			continue
+		}
+		if limited, ok := legacy.(memoryLimited); ok {
+			return limited.withMemoryLimit(int(h.params.Memory) * 1024)
+		}
+		return legacy
+	}
+	return nil
+}
+
+// recognizes reports whether encodedHash is in a format verify understands,
+// within its cost limits and the memory of a pool slot and, if peppered,
+// with a pepper it knows.
+func (h *passwordHasher) recognizes(encodedHash string) bool {
+	if legacy := h.legacyHasher(encodedHash); legacy != nil {
+		return legacy.Check(encodedHash) == nil
+	}
+	decoded, err := decodeArgon2Hash(encodedHash)
+	if err != nil || decoded.params.Memory > h.params.Memory {
+		return false
+	}
+	return decoded.keyID == "" || findPepper(h.peppers, decoded.keyID) != nil
+}
+
+// checkArgon2Memory refuses Argon2id hashes that need more memory than the
+// target parameters, the most one hashing pool slot is sized for.
+func (h *passwordHasher) checkArgon2Memory(encodedHash string) error {
+	decoded, err := decodeArgon2Hash(encodedHash)
+	if err != nil {
+		return err
+	}
+	if decoded.params.Memory > h.params.Memory {
+		return errUnsupportedHashCost
+	}
+	return nil
+}
+
+// needsRehash reports whether any parameter of encodedHash is below target,
+// or it was not made with the newest pepper.
+func (h *passwordHasher) needsRehash(encodedHash string) bool {
+	decoded, err := decodeArgon2Hash(encodedHash)
+	if err != nil {
+		return false
+	}
+
+	if pepper := h.currentPepper(); pepper != nil && decoded.keyID != pepper.ID {
+		return true
+	}
+
+	params := decoded.params
+	return params.Memory < h.params.Memory ||
+		params.Iterations < h.params.Iterations ||
+		params.Parallelism < h.params.Parallelism ||
+		params.SaltLength < h.params.SaltLength ||
+		params.KeyLength < h.params.KeyLength
+}
+
+// upgradePasswordHash replaces a user's outdated password hash after they
+// proved they know the password. Only the hash that was verified is
+// replaced: a password changed in the meantime stays. Failing is logged
+// rather than failing the sign in; the next one tries again.
+func (a *authService) upgradePasswordHash(ctx context.Context, user *domain.User, password string) {
+	encodedHash, err := a.hasher.hash(ctx, password)
+	if err != nil {
+		log.Println("Failed to rehash password:", err)
+		return
+	}
+
+	updated, err := a.authRepo.UpdatePasswordHash(ctx, user.ID, user.Password, encodedHash)
+	if err != nil {
+		log.Printf("Failed to store rehashed password of user %s: %v", user.ID, err)
+		return
+	}
+	if updated {
+		user.Password = encodedHash
+	}
+}
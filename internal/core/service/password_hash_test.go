@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+func TestPasswordHasherRehash(t *testing.T) {
+	ctx := context.Background()
+	weak := Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	target := Argon2Params{Memory: 128, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	old, err := newPasswordHasher(weak).hash(ctx, "tangerine-orbit-falcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasher := newPasswordHasher(target)
+
+	match, rehash, err := hasher.verify(ctx, "tangerine-orbit-falcon", old)
+	if err != nil || !match || !rehash {
+		t.Fatalf("verify(weak hash) = %v, %v, %v, want a match to rehash", match, rehash, err)
+	}
+
+	match, rehash, err = hasher.verify(ctx, "wrong password", old)
+	if err != nil || match || rehash {
+		t.Fatalf("verify(wrong password) = %v, %v, %v, want no match", match, rehash, err)
+	}
+
+	current, err := hasher.hash(ctx, "tangerine-orbit-falcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, rehash, err = hasher.verify(ctx, "tangerine-orbit-falcon", current)
+	if err != nil || !match || rehash {
+		t.Fatalf("verify(current hash) = %v, %v, %v, want a match as is", match, rehash, err)
+	}
+
+	// Hashes in the PHC string format start with "$"
+	if match, _, err := hasher.verify(ctx, "tangerine-orbit-falcon", "$"+current); err != nil || !match {
+		t.Fatalf("verify(PHC hash) = %v, %v, want a match", match, err)
+	}
+
+	// Stronger than the target in some parameters is not enough when weaker
+	// in another
+	for name, params := range map[string]Argon2Params{
+		"iterations": {Memory: 256, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		"salt":       {Memory: 128, Iterations: 2, Parallelism: 1, SaltLength: 8, KeyLength: 32},
+		"key":        {Memory: 128, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 16},
+	} {
+		encoded, err := newPasswordHasher(params).hash(ctx, "tangerine-orbit-falcon")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hasher.needsRehash(encoded) {
+			t.Errorf("needsRehash(%s below target) = false, want true", name)
+		}
+	}
+}
+
+func TestPasswordHasherPeppers(t *testing.T) {
+	ctx := context.Background()
+	params := Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	peppers, err := ParsePeppers([]byte(`
+# rotated 2026-01
+old MDEyMzQ1Njc4OWFiY2RlZg==
+new ZmVkY2JhOTg3NjU0MzIxMA==
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unpeppered := newPasswordHasher(params)
+	rotated := newPasswordHasher(params)
+	rotated.peppers = peppers
+	previous := newPasswordHasher(params)
+	previous.peppers = peppers[:1]
+
+	plain, err := unpeppered.hash(ctx, "tangerine-orbit-falcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := previous.hash(ctx, "tangerine-orbit-falcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := rotated.hash(ctx, "tangerine-orbit-falcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		hash   string
+		rehash bool
+	}{
+		"unpeppered":     {plain, true},
+		"older pepper":   {old, true},
+		"current pepper": {current, false},
+	} {
+		match, rehash, err := rotated.verify(ctx, "tangerine-orbit-falcon", tt.hash)
+		if err != nil || !match || rehash != tt.rehash {
+			t.Errorf("verify(%s) = %v, %v, %v, want a match with rehash %v", name, match, rehash, err, tt.rehash)
+		}
+	}
+
+	// The pepper is needed to verify, not just the hash
+	if _, _, err := unpeppered.verify(ctx, "tangerine-orbit-falcon", current); err == nil {
+		t.Error("verify without the pepper succeeded, want an unknown pepper error")
+	}
+	if match, _, _ := previous.verify(ctx, "tangerine-orbit-falcon", plain); !match {
+		t.Error("verify(unpeppered) with peppers configured failed, want a match")
+	}
+
+	// Imports are refused hashes peppered with a pepper the service lacks
+	if !rotated.recognizes(old) || !previous.recognizes(plain) {
+		t.Error("recognizes = false for a hash with a known or no pepper")
+	}
+	if unpeppered.recognizes(current) || previous.recognizes(current) {
+		t.Error("recognizes = true for a hash with an unknown pepper")
+	}
+
+	for _, invalid := range []string{
+		"short MDEyMzQ1Njc4OQ==",
+		"bad$id MDEyMzQ1Njc4OWFiY2RlZg==",
+		"dup MDEyMzQ1Njc4OWFiY2RlZg==\ndup ZmVkY2JhOTg3NjU0MzIxMA==",
+		"missing-secret",
+	} {
+		if _, err := ParsePeppers([]byte(invalid)); err == nil {
+			t.Errorf("ParsePeppers(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestPasswordHasherLegacyHashes(t *testing.T) {
+	ctx := context.Background()
+	// scrypt may use as much memory as the target, 16 MiB for Django's default
+	hasher := newPasswordHasher(Argon2Params{Memory: 16 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+	}{
+		// OpenBSD bcrypt test vector
+		{"bcrypt", "U*U", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+		{"bcrypt php", "U*U", "$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+		{"django pbkdf2", "tangerine-orbit-falcon", "pbkdf2_sha256$1000$saltsalt$/3XZq6ExgG4tEgNl6AAiIne2pHiGJSsf7nhxZPzpzGs="},
+		// Django's ScryptPasswordHasher with its default N, r and p
+		{"django scrypt", "tangerine-orbit-falcon", "scrypt$16384$Y7mKq2VdXbTzR9cWs4LhNf$8$1$gfXtoPaGF68qGvpE95J9MHoDuqwGR2gHlfGJNWaqrUymp692GI+aoBFawwNA5uogAyRxnXOkswOQYlATx8jJbg=="},
+		{"passlib scrypt", "tangerine-orbit-falcon", "$scrypt$ln=10,r=8,p=1$MDEyMzQ1Njc4OWFiY2RlZg$uVV/V3Z4kEwF.bTVVKj8O12ZJDAEg8jigonbYcPaEZQ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !hasher.recognizes(tt.hash) {
+				t.Fatal("recognizes = false, want true")
+			}
+			match, rehash, err := hasher.verify(ctx, tt.password, tt.hash)
+			if err != nil || !match || !rehash {
+				t.Fatalf("verify = %v, %v, %v, want a match to rehash", match, rehash, err)
+			}
+			match, rehash, err = hasher.verify(ctx, tt.password+"x", tt.hash)
+			if err != nil || match || rehash {
+				t.Fatalf("verify(wrong password) = %v, %v, %v, want no match", match, rehash, err)
+			}
+		})
+	}
+
+	for _, hash := range []string{"md5$abc$def", "plaintext", "scrypt$1073741824$salt$8$1$AAAA"} {
+		if match, _, err := hasher.verify(ctx, "password", hash); match || err == nil {
+			t.Errorf("verify(%q) = %v, %v, want an error", hash, match, err)
+		}
+	}
+
+	// Above the target memory a hash would take more than its pool slot
+	smaller := newPasswordHasher(Argon2Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	django := tests[3]
+	if match, _, err := smaller.verify(ctx, django.password, django.hash); match || !errors.Is(err, errUnsupportedHashCost) {
+		t.Errorf("verify(scrypt above the target memory) = %v, %v, want errUnsupportedHashCost", match, err)
+	}
+
+	// So would an Argon2id hash, however it got into the store
+	argon2Hash, err := hasher.hash(ctx, "tangerine-orbit-falcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match, _, err := smaller.verify(ctx, "tangerine-orbit-falcon", argon2Hash); match || !errors.Is(err, errUnsupportedHashCost) {
+		t.Errorf("verify(argon2id above the target memory) = %v, %v, want errUnsupportedHashCost", match, err)
+	}
+	if smaller.recognizes(argon2Hash) {
+		t.Error("recognizes(argon2id above the target memory) = true, want false")
+	}
+}
+
+func TestUpgradePasswordHash(t *testing.T) {
+	ctx := context.Background()
+	a, repo := newTestService(t)
+	verified := domain.User{ID: "user-1", Phonenumber: "+12025550123", Password: "verified-hash"}
+
+	t.Run("keeps a password changed in the meantime", func(t *testing.T) {
+		changed := verified
+		changed.Password = "changed-hash"
+		repo.users[verified.ID] = changed
+
+		user := verified
+		a.upgradePasswordHash(ctx, &user, "tangerine-orbit-falcon")
+		if got := repo.users[verified.ID].Password; got != "changed-hash" {
+			t.Fatalf("expected the changed password to stay, got %q", got)
+		}
+		if user.Password != "verified-hash" {
+			t.Fatalf("expected the user to keep the verified hash, got %q", user.Password)
+		}
+	})
+
+	t.Run("replaces the verified hash", func(t *testing.T) {
+		repo.users[verified.ID] = verified
+
+		user := verified
+		a.upgradePasswordHash(ctx, &user, "tangerine-orbit-falcon")
+		stored := repo.users[verified.ID].Password
+		if stored == "verified-hash" || stored != user.Password {
+			t.Fatalf("expected the hash to be upgraded, stored %q, user %q", stored, user.Password)
+		}
+		if match, _, err := a.hasher.verify(ctx, "tangerine-orbit-falcon", stored); err != nil || !match {
+			t.Fatalf("expected the upgraded hash to verify, got %v, %v", match, err)
+		}
+	})
+}
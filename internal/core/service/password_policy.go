@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// Codes of the password policy violations.
+const (
+	PasswordTooShort         = "too_short"
+	PasswordTooLong          = "too_long"
+	PasswordCharacterClasses = "character_classes"
+	PasswordPhoneNumber      = "phone_number"
+	PasswordTooGuessable     = "too_guessable"
+	PasswordUnchanged        = "unchanged"
+	PasswordBreached         = "breached"
+)
+
+// phoneDigitsRun is how many consecutive digits of the user's phone number a
+// password may not contain.
+const phoneDigitsRun = 6
+
+// PasswordPolicy decides which new passwords are acceptable. Length is
+// counted in characters. MinClasses asks for that many of lowercase,
+// uppercase, digits and symbols. MinScore is the lowest strength score,
+// from 0 (trivially guessable) to 4 (very hard to guess), a password must
+// reach; see estimatePasswordStrength.
+type PasswordPolicy struct {
+	MinLength          int
+	MaxLength          int
+	MinClasses         int
+	MinScore           int
+	RejectPhoneNumbers bool
+}
+
+// DefaultPasswordPolicy follows NIST SP 800-63B: length and guessability
+// matter, composition rules do not.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:          8,
+		MaxLength:          128,
+		MinClasses:         0,
+		MinScore:           2,
+		RejectPhoneNumbers: true,
+	}
+}
+
+// Validate checks a password a user with the given phone number is about to
+// set. It returns a *port.PasswordPolicyError listing every violation.
+func (p PasswordPolicy) Validate(password string, phonenumber string) error {
+	var violations []domain.PasswordViolation
+	violate := func(code string, format string, args ...any) {
+		violations = append(violations, domain.PasswordViolation{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	tooShort := length < p.MinLength
+	tooLong := p.MaxLength > 0 && length > p.MaxLength
+	if tooShort {
+		violate(PasswordTooShort, "use at least %d characters", p.MinLength)
+	}
+	if tooLong {
+		violate(PasswordTooLong, "use at most %d characters", p.MaxLength)
+	}
+
+	if classes := characterClasses(password); classes < p.MinClasses {
+		violate(PasswordCharacterClasses, "use at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinClasses)
+	}
+
+	if p.RejectPhoneNumbers && containsPhoneDigits(password, phonenumber) {
+		violate(PasswordPhoneNumber, "do not use your phone number")
+	}
+
+	if !tooShort && !tooLong && p.MinScore > 0 {
+		if score := estimatePasswordStrength(password, phonenumber); score < p.MinScore {
+			violate(PasswordTooGuessable, "this password is too easy to guess, try a longer phrase or fewer common words and patterns")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &port.PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// validateNewPassword checks a password a user with the given phone number
+// is about to set against the password policy and, when configured, known
+// breaches. Every violation is reported together.
+func (a *authService) validateNewPassword(ctx context.Context, password string, phonenumber string) error {
+	err := a.passwordPolicy.Validate(password, phonenumber)
+	if a.breachedPasswords == nil || password == "" {
+		return err
+	}
+
+	breached, checkErr := a.breachedPasswords.IsBreached(ctx, password)
+	if checkErr != nil {
+		return fmt.Errorf("breached password check failed: %w", checkErr)
+	}
+	if !breached {
+		return err
+	}
+
+	violation := domain.PasswordViolation{
+		Code:    PasswordBreached,
+		Message: "this password appears in known data breaches, choose another one",
+	}
+	var policyErr *port.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		policyErr.Violations = append(policyErr.Violations, violation)
+		return policyErr
+	}
+	return &port.PasswordPolicyError{Violations: []domain.PasswordViolation{violation}}
+}
+
+// characterClasses counts which of lowercase, uppercase, digits and other
+// characters appear in password.
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
+
+// containsPhoneDigits reports whether the digits of password include a run
+// of phoneDigitsRun digits from the phone number, forwards or backwards.
+func containsPhoneDigits(password string, phonenumber string) bool {
+	phone := strings.TrimPrefix(phonenumber, "+")
+	if len(phone) < phoneDigitsRun {
+		return false
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, password)
+	if len(digits) < phoneDigitsRun {
+		return false
+	}
+
+	for _, candidate := range []string{digits, reverse(digits)} {
+		for i := 0; i+phoneDigitsRun <= len(phone); i++ {
+			if strings.Contains(candidate, phone[i:i+phoneDigitsRun]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
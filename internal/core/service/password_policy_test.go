@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	strict := DefaultPasswordPolicy()
+	strict.MinClasses = 3
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     []string
+	}{
+		{"strong", policy, "tangerine-Orbit-falcon", nil},
+		{"random", policy, "x7#kQ9!zR2", nil},
+		{"empty", policy, "", []string{PasswordTooShort}},
+		{"too short", policy, "k#9Qz", []string{PasswordTooShort}},
+		{"too long", policy, string(make([]byte, 129)), []string{PasswordTooLong}},
+		{"common", policy, "password1", []string{PasswordTooGuessable}},
+		{"leet", policy, "P@ssw0rd", []string{PasswordTooGuessable}},
+		{"sequence", policy, "abcd1234", []string{PasswordTooGuessable}},
+		{"keyboard", policy, "qwertyuiop", []string{PasswordTooGuessable}},
+		{"repeat", policy, "aaaaaaaaaaaa", []string{PasswordTooGuessable}},
+		{"phone number", policy, "call-me-at-202-555-0123", []string{PasswordPhoneNumber}},
+		{"phone number reversed", policy, "bird32105552", []string{PasswordPhoneNumber}},
+		{"character classes", strict, "tangerineorbitfalcon", []string{PasswordCharacterClasses}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password, "+12025550123")
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate(%q) = %v, want nil", tt.password, err)
+				}
+				return
+			}
+
+			var policyErr *port.PasswordPolicyError
+			if !errors.As(err, &policyErr) || !errors.Is(err, port.ErrWeakPassword) {
+				t.Fatalf("Validate(%q) = %v, want *port.PasswordPolicyError", tt.password, err)
+			}
+			var codes []string
+			for _, violation := range policyErr.Violations {
+				codes = append(codes, violation.Code)
+			}
+			if len(codes) != len(tt.want) {
+				t.Fatalf("Validate(%q) violations = %v, want %v", tt.password, codes, tt.want)
+			}
+			for i := range codes {
+				if codes[i] != tt.want[i] {
+					t.Fatalf("Validate(%q) violations = %v, want %v", tt.password, codes, tt.want)
+				}
+			}
+		})
+	}
+}
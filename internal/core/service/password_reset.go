@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// StartPasswordReset texts a reset code to the account registered with the
+// phone number.
+func (a *authService) StartPasswordReset(ctx context.Context, phonenumber string) (time.Time, error) {
+	return a.issueCodeByPhone(ctx, domain.CodePurposeReset, phonenumber, func(code string) string {
+		return fmt.Sprintf("%s is your password reset code. It expires in %d minutes. If you did not ask to reset your password, ignore this message.", code, int(a.codePolicy.TTL.Minutes()))
+	})
+}
+
+// ResetPassword sets a new password with a reset code. Whoever knew the old
+// password may still be signed in, so every session of the user is revoked.
+func (a *authService) ResetPassword(ctx context.Context, phonenumber string, code string, password string) error {
+	// Check the password first so a rejected one does not use up the code
+	if err := a.validateNewPassword(ctx, password, a.lookupPhone(phonenumber)); err != nil {
+		return err
+	}
+
+	user, err := a.redeemCodeByPhone(ctx, domain.CodePurposeReset, phonenumber, code)
+	if err != nil {
+		return err
+	}
+
+	encodedHash, err := a.hasher.hash(ctx, password)
+	if err != nil {
+		return fmt.Errorf("password hashing failed: %w", err)
+	}
+
+	// The code proved the user owns the phone number
+	now := time.Now()
+	user.Password = encodedHash
+	if user.PhoneVerifiedAt == nil {
+		user.PhoneVerifiedAt = &now
+	}
+
+	if _, err := a.authRepo.UpdateUser(ctx, *user); err != nil {
+		return fmt.Errorf("user update failed: %w", err)
+	}
+
+	// Whoever reset the password may sign in with it straight away
+	a.clearLoginFailures(ctx, user.Phonenumber)
+
+	revoked, err := a.authRepo.RevokeAllSessions(ctx, user.ID, "")
+	if err != nil {
+		return fmt.Errorf("session revocation failed: %w", err)
+	}
+
+	a.recordEvent(ctx, domain.SecurityEvent{
+		Type:   domain.EventPasswordReset,
+		UserID: user.ID,
+		Details: map[string]string{
+			"revoked_sessions": fmt.Sprint(revoked),
+		},
+	})
+
+	return nil
+}
+
+// ChangePassword sets a new password for a signed-in user who knows the
+// current one, optionally signing them out everywhere else.
+func (a *authService) ChangePassword(ctx context.Context, change domain.PasswordChange) (int, error) {
+	user, err := a.ReadUserById(ctx, change.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	if user.Password == "" {
+		return 0, port.ErrInvalidCredentials
+	}
+
+	// Guesses at the current password count against the same lockout as
+	// sign ins, so a stolen session cannot try passwords without limit
+	failures, err := a.reserveLoginAttempt(ctx, user.Phonenumber)
+	if err != nil {
+		return 0, err
+	}
+
+	// The hash is replaced below, so an outdated one needs no upgrade
+	match, _, err := a.hasher.verify(ctx, change.CurrentPassword, user.Password)
+	if err != nil {
+		a.releaseLoginAttempt(ctx, user.Phonenumber)
+		return 0, fmt.Errorf("password comparison failed: %w", err)
+	}
+	if !match {
+		a.recordLockout(ctx, failures, user)
+		return 0, port.ErrInvalidCredentials
+	}
+	a.clearLoginFailures(ctx, user.Phonenumber)
+
+	if err := a.validateNewPassword(ctx, change.NewPassword, user.Phonenumber); err != nil {
+		return 0, err
+	}
+	if change.NewPassword == change.CurrentPassword {
+		return 0, &port.PasswordPolicyError{Violations: []domain.PasswordViolation{
+			{Code: PasswordUnchanged, Message: "choose a password different from the current one"},
+		}}
+	}
+
+	encodedHash, err := a.hasher.hash(ctx, change.NewPassword)
+	if err != nil {
+		return 0, fmt.Errorf("password hashing failed: %w", err)
+	}
+
+	user.Password = encodedHash
+	if _, err := a.authRepo.UpdateUser(ctx, *user); err != nil {
+		return 0, fmt.Errorf("user update failed: %w", err)
+	}
+
+	revoked := 0
+	if change.SignOutOthers {
+		revoked, err = a.authRepo.RevokeAllSessions(ctx, user.ID, change.KeepToken)
+		if err != nil {
+			return 0, fmt.Errorf("session revocation failed: %w", err)
+		}
+	}
+
+	a.recordEvent(ctx, domain.SecurityEvent{
+		Type:   domain.EventPasswordChanged,
+		UserID: user.ID,
+		Details: map[string]string{
+			"revoked_sessions": fmt.Sprint(revoked),
+		},
+	})
+
+	return revoked, nil
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	phone := "+12025550123"
+	device := domain.Device{UserAgent: "test", IPAddress: "192.0.2.1"}
+	newPassword := "tangerine-orbit-falcon"
+
+	setup := func(t *testing.T, policy OneTimeCodePolicy) (*authService, *testRepo, *testSMS) {
+		t.Helper()
+		sms := &testSMS{}
+		a, repo := newTestService(t, WithSMSSender(sms), WithOneTimeCodePolicy(policy))
+		if _, err := a.CreateUser(ctx, domain.Credentials{Phonenumber: phone, Password: "correct-horse-battery"}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		return a, repo, sms
+	}
+	userID := func(repo *testRepo) string {
+		user, _ := repo.ReadUserByPhone(ctx, phone)
+		return user.ID
+	}
+
+	t.Run("revokes every session and refresh token", func(t *testing.T) {
+		a, repo, sms := setup(t, testCodePolicy)
+		id := userID(repo)
+
+		var sessions []*domain.Session
+		for i := 0; i < 2; i++ {
+			session, err := a.CreateSession(ctx, id, device)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessions = append(sessions, session)
+		}
+		repo.failures[phone] = domain.LoginFailures{Count: 2, LastFailureAt: time.Now()}
+
+		if _, err := a.StartPasswordReset(ctx, "(202) 555-0123"); err != nil {
+			t.Fatalf("StartPasswordReset: %v", err)
+		}
+		if err := a.ResetPassword(ctx, phone, sms.lastCode(t, phone), newPassword); err != nil {
+			t.Fatalf("ResetPassword: %v", err)
+		}
+
+		for _, session := range sessions {
+			if _, err := a.ReadSession(ctx, session.Token); err == nil {
+				t.Error("session survived the reset")
+			}
+			if revoked, _ := repo.IsSessionRevoked(ctx, session.ID); !revoked {
+				t.Error("session not denylisted, its access tokens still verify")
+			}
+			if _, err := a.RefreshSession(ctx, session.RefreshToken, device); !errors.Is(err, port.ErrRefreshTokenNotFound) {
+				t.Errorf("expected the refresh token revoked, got %v", err)
+			}
+		}
+
+		if _, ok := repo.failures[phone]; ok {
+			t.Error("expected earlier login failures cleared")
+		}
+		if valid, err := a.ValidateUser(ctx, domain.Credentials{Phonenumber: phone, Password: newPassword}); err != nil || !valid {
+			t.Errorf("ValidateUser(new password) = %v, %v", valid, err)
+		}
+		if valid, _ := a.ValidateUser(ctx, domain.Credentials{Phonenumber: phone, Password: "correct-horse-battery"}); valid {
+			t.Error("old password still valid")
+		}
+		if repo.users[id].PhoneVerifiedAt == nil {
+			t.Error("expected the phone marked verified")
+		}
+		if !slices.Contains(repo.eventTypes(), domain.EventPasswordReset) {
+			t.Errorf("expected a %s event, got %v", domain.EventPasswordReset, repo.eventTypes())
+		}
+	})
+
+	t.Run("unknown numbers get the same answer", func(t *testing.T) {
+		a, repo, sms := setup(t, testCodePolicy)
+		other := "+12025550199"
+
+		expiresAt, err := a.StartPasswordReset(ctx, other)
+		if err != nil || expiresAt.IsZero() {
+			t.Fatalf("StartPasswordReset = %v, %v, want an expiry", expiresAt, err)
+		}
+		if sms.count(other) != 0 {
+			t.Error("texted a number without an account")
+		}
+		if repo.limits["sms:"+other] != 1 {
+			t.Error("expected the send counted against the number's limit")
+		}
+
+		if err := a.ResetPassword(ctx, other, "123456", newPassword); !errors.Is(err, port.ErrInvalidCode) {
+			t.Errorf("expected ErrInvalidCode, got %v", err)
+		}
+	})
+
+	t.Run("unknown numbers are rate limited the same", func(t *testing.T) {
+		policy := testCodePolicy
+		policy.ResendInterval = time.Minute
+		a, _, _ := setup(t, policy)
+
+		for _, number := range []string{phone, "+12025550199"} {
+			if _, err := a.StartPasswordReset(ctx, number); err != nil {
+				t.Fatalf("%s: StartPasswordReset: %v", number, err)
+			}
+			var limited *port.RateLimitError
+			if _, err := a.StartPasswordReset(ctx, number); !errors.As(err, &limited) || limited.RetryAfter <= 0 {
+				t.Errorf("%s: expected a RateLimitError with Retry-After, got %v", number, err)
+			}
+		}
+	})
+
+	t.Run("a weak password does not use up the code", func(t *testing.T) {
+		a, repo, sms := setup(t, testCodePolicy)
+		if _, err := a.StartPasswordReset(ctx, phone); err != nil {
+			t.Fatalf("StartPasswordReset: %v", err)
+		}
+		code := sms.lastCode(t, phone)
+
+		for i := 0; i < testCodePolicy.MaxAttempts+1; i++ {
+			if err := a.ResetPassword(ctx, phone, code, "short"); !errors.Is(err, port.ErrWeakPassword) {
+				t.Fatalf("expected ErrWeakPassword, got %v", err)
+			}
+		}
+		if attempts := repo.codes[domain.CodePurposeReset+":"+userID(repo)].Attempts; attempts != 0 {
+			t.Errorf("weak passwords counted %d attempts", attempts)
+		}
+
+		if err := a.ResetPassword(ctx, phone, code, newPassword); err != nil {
+			t.Fatalf("ResetPassword: %v", err)
+		}
+	})
+
+	t.Run("a used code cannot reset again", func(t *testing.T) {
+		a, _, sms := setup(t, testCodePolicy)
+		if _, err := a.StartPasswordReset(ctx, phone); err != nil {
+			t.Fatalf("StartPasswordReset: %v", err)
+		}
+		code := sms.lastCode(t, phone)
+
+		if err := a.ResetPassword(ctx, phone, code, newPassword); err != nil {
+			t.Fatalf("ResetPassword: %v", err)
+		}
+		if err := a.ResetPassword(ctx, phone, code, "another-orbit-tangerine"); !errors.Is(err, port.ErrInvalidCode) {
+			t.Errorf("expected ErrInvalidCode, got %v", err)
+		}
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	phone := "+12025550123"
+	device := domain.Device{UserAgent: "test", IPAddress: "192.0.2.1"}
+	current, newPassword := "correct-horse-battery", "tangerine-orbit-falcon"
+
+	setup := func(t *testing.T) (*authService, *testRepo, string) {
+		t.Helper()
+		a, repo := newTestService(t)
+		user, err := a.CreateUser(ctx, domain.Credentials{Phonenumber: phone, Password: current})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		return a, repo, user.ID
+	}
+
+	t.Run("refuses a wrong current password", func(t *testing.T) {
+		a, repo, id := setup(t)
+		hash := repo.users[id].Password
+
+		_, err := a.ChangePassword(ctx, domain.PasswordChange{UserID: id, CurrentPassword: "wrong-password", NewPassword: newPassword})
+		if !errors.Is(err, port.ErrInvalidCredentials) {
+			t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+		}
+		if repo.users[id].Password != hash {
+			t.Error("password changed without the current one")
+		}
+	})
+
+	t.Run("wrong current passwords count towards the lockout", func(t *testing.T) {
+		policy := LockoutPolicy{FreeAttempts: 10, Threshold: 3, LockoutDuration: time.Hour, Window: time.Hour}
+		a, repo := newTestService(t, WithLockoutPolicy(policy))
+		user, err := a.CreateUser(ctx, domain.Credentials{Phonenumber: phone, Password: current})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		change := domain.PasswordChange{UserID: user.ID, CurrentPassword: "wrong-password", NewPassword: newPassword}
+
+		for i := 0; i < policy.Threshold; i++ {
+			if _, err := a.ChangePassword(ctx, change); !errors.Is(err, port.ErrInvalidCredentials) {
+				t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+			}
+		}
+
+		change.CurrentPassword = current
+		if _, err := a.ChangePassword(ctx, change); !errors.Is(err, port.ErrAccountLocked) {
+			t.Fatalf("expected ErrAccountLocked, got %v", err)
+		}
+		if valid, _, _ := a.hasher.verify(ctx, current, repo.users[user.ID].Password); !valid {
+			t.Error("password changed while locked out")
+		}
+		if !slices.Contains(repo.eventTypes(), domain.EventAccountLocked) {
+			t.Errorf("expected a %s event, got %v", domain.EventAccountLocked, repo.eventTypes())
+		}
+	})
+
+	t.Run("the right current password clears failures", func(t *testing.T) {
+		a, repo, id := setup(t)
+		repo.failures[phone] = domain.LoginFailures{Count: 2, LastFailureAt: time.Now()}
+
+		if _, err := a.ChangePassword(ctx, domain.PasswordChange{UserID: id, CurrentPassword: current, NewPassword: newPassword}); err != nil {
+			t.Fatalf("ChangePassword: %v", err)
+		}
+		if _, ok := repo.failures[phone]; ok {
+			t.Error("expected failures cleared")
+		}
+	})
+
+	t.Run("refuses passwords the policy rejects", func(t *testing.T) {
+		a, repo, id := setup(t)
+		hash := repo.users[id].Password
+
+		for _, password := range []string{"short", current} {
+			_, err := a.ChangePassword(ctx, domain.PasswordChange{UserID: id, CurrentPassword: current, NewPassword: password})
+			if !errors.Is(err, port.ErrWeakPassword) {
+				t.Errorf("%q: expected ErrWeakPassword, got %v", password, err)
+			}
+		}
+		if repo.users[id].Password != hash {
+			t.Error("password changed to a rejected one")
+		}
+	})
+
+	t.Run("changes the password", func(t *testing.T) {
+		a, repo, id := setup(t)
+		other, err := a.CreateSession(ctx, id, device)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		revoked, err := a.ChangePassword(ctx, domain.PasswordChange{UserID: id, CurrentPassword: current, NewPassword: newPassword})
+		if err != nil || revoked != 0 {
+			t.Fatalf("ChangePassword = %d, %v, want 0 revoked", revoked, err)
+		}
+
+		if valid, err := a.ValidateUser(ctx, domain.Credentials{Phonenumber: phone, Password: newPassword}); err != nil || !valid {
+			t.Errorf("ValidateUser(new password) = %v, %v", valid, err)
+		}
+		if valid, _ := a.ValidateUser(ctx, domain.Credentials{Phonenumber: phone, Password: current}); valid {
+			t.Error("old password still valid")
+		}
+		if _, err := a.ReadSession(ctx, other.Token); err != nil {
+			t.Errorf("other session ended without SignOutOthers: %v", err)
+		}
+		if !slices.Contains(repo.eventTypes(), domain.EventPasswordChanged) {
+			t.Errorf("expected a %s event, got %v", domain.EventPasswordChanged, repo.eventTypes())
+		}
+	})
+
+	t.Run("signs out other sessions but the kept one", func(t *testing.T) {
+		a, repo, id := setup(t)
+		var sessions []*domain.Session
+		for i := 0; i < 3; i++ {
+			session, err := a.CreateSession(ctx, id, device)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessions = append(sessions, session)
+		}
+		kept, others := sessions[0], sessions[1:]
+
+		revoked, err := a.ChangePassword(ctx, domain.PasswordChange{
+			UserID:          id,
+			CurrentPassword: current,
+			NewPassword:     newPassword,
+			SignOutOthers:   true,
+			KeepToken:       kept.Token,
+		})
+		if err != nil {
+			t.Fatalf("ChangePassword: %v", err)
+		}
+		if revoked != len(others) {
+			t.Errorf("revoked = %d, want %d", revoked, len(others))
+		}
+
+		for _, session := range others {
+			if _, err := a.ReadSession(ctx, session.Token); err == nil {
+				t.Error("other session survived")
+			}
+			if revoked, _ := repo.IsSessionRevoked(ctx, session.ID); !revoked {
+				t.Error("other session not denylisted")
+			}
+			if _, err := a.RefreshSession(ctx, session.RefreshToken, device); !errors.Is(err, port.ErrRefreshTokenNotFound) {
+				t.Errorf("expected the other refresh token revoked, got %v", err)
+			}
+		}
+
+		if _, err := a.ReadSession(ctx, kept.Token); err != nil {
+			t.Errorf("kept session ended: %v", err)
+		}
+		if _, err := a.RefreshSession(ctx, kept.RefreshToken, device); err != nil {
+			t.Errorf("kept session's refresh token revoked: %v", err)
+		}
+	})
+}
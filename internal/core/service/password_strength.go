@@ -0,0 +1,249 @@
+package service
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// The strength estimate follows zxcvbn: a password is split into the
+// cheapest sequence of patterns an attacker would try (common passwords,
+// repeats, sequences, keyboard walks, years) with unmatched characters
+// guessed one by one, and the number of guesses needed becomes a score.
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords ranks common passwords and words by popularity, the most
+// popular at 1.
+var commonPasswords = func() map[string]int {
+	ranks := make(map[string]int)
+	for i, word := range strings.Fields(commonPasswordList) {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}()
+
+// leetSubstitutions undoes the usual character swaps, e.g. "p@ssw0rd".
+var leetSubstitutions = strings.NewReplacer(
+	"4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o",
+	"$", "s", "5", "s", "7", "t", "+", "t", "8", "b", "9", "g",
+)
+
+// keyboardRows are walked left to right or right to left in many passwords.
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm", "qwertzuiop", "azertyuiop"}
+
+const (
+	// bruteforceGuesses is the cost of guessing one character that is not
+	// part of any pattern.
+	bruteforceGuesses = 10
+	// minSubmatchGuesses is the least a pattern inside a longer password
+	// costs, so splitting a password into many patterns is not free.
+	minSubmatchGuesses = 50
+	minPatternLength   = 3
+	// maxEstimatedLength bounds the work done per password. Characters past
+	// it only make a password stronger.
+	maxEstimatedLength = 100
+)
+
+// strengthScoreThresholds are the log10 of guesses needed for scores 1 to 4.
+var strengthScoreThresholds = []float64{3, 6, 8, 10}
+
+// passwordPattern is a run of a password an attacker can guess as a whole.
+type passwordPattern struct {
+	start, end int
+	guesses    float64
+}
+
+// estimatePasswordStrength scores password from 0 (trivially guessable) to 4
+// (very hard to guess). The user's phone number counts as known to an
+// attacker.
+func estimatePasswordStrength(password string, phonenumber string) int {
+	runes := []rune(password)
+	if len(runes) > maxEstimatedLength {
+		runes = runes[:maxEstimatedLength]
+	}
+	guesses := log10Guesses(runes, strings.TrimPrefix(phonenumber, "+"))
+
+	score := 0
+	for _, threshold := range strengthScoreThresholds {
+		if guesses >= threshold {
+			score++
+		}
+	}
+	return score
+}
+
+// log10Guesses returns the log10 of the guesses needed for the cheapest way
+// to cover the password with patterns and single characters.
+func log10Guesses(password []rune, phoneDigits string) float64 {
+	n := len(password)
+	patterns := passwordPatterns(password, phoneDigits)
+
+	// cheapest[i] is the cost of guessing the first i characters
+	cheapest := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		cheapest[i] = cheapest[i-1] + math.Log10(bruteforceGuesses)
+		for _, pattern := range patterns {
+			if pattern.end != i {
+				continue
+			}
+			guesses := pattern.guesses
+			if pattern.end-pattern.start < n {
+				guesses = math.Max(guesses, minSubmatchGuesses)
+			}
+			if cost := cheapest[pattern.start] + math.Log10(math.Max(guesses, 1)); cost < cheapest[i] {
+				cheapest[i] = cost
+			}
+		}
+	}
+	return cheapest[n]
+}
+
+func passwordPatterns(password []rune, phoneDigits string) []passwordPattern {
+	var patterns []passwordPattern
+	n := len(password)
+
+	for start := 0; start < n; start++ {
+		for end := start + minPatternLength; end <= n; end++ {
+			token := password[start:end]
+			if guesses, ok := dictionaryGuesses(string(token)); ok {
+				patterns = append(patterns, passwordPattern{start, end, guesses})
+			}
+			if guesses, ok := repeatGuesses(token); ok {
+				patterns = append(patterns, passwordPattern{start, end, guesses})
+			}
+			if guesses, ok := sequenceGuesses(token); ok {
+				patterns = append(patterns, passwordPattern{start, end, guesses})
+			}
+			if guesses, ok := keyboardGuesses(string(token)); ok {
+				patterns = append(patterns, passwordPattern{start, end, guesses})
+			}
+			if guesses, ok := digitsGuesses(string(token), phoneDigits); ok {
+				patterns = append(patterns, passwordPattern{start, end, guesses})
+			}
+		}
+	}
+	return patterns
+}
+
+// dictionaryGuesses matches common passwords, also reversed, l33t-spoken or
+// capitalized.
+func dictionaryGuesses(token string) (float64, bool) {
+	lower := strings.ToLower(token)
+
+	guesses := math.Inf(1)
+	for _, candidate := range []struct {
+		word       string
+		multiplier float64
+	}{
+		{lower, 1},
+		{reverse(lower), 2},
+		{leetSubstitutions.Replace(lower), 4},
+	} {
+		if rank, ok := commonPasswords[candidate.word]; ok {
+			guesses = math.Min(guesses, float64(rank)*candidate.multiplier)
+		}
+	}
+	if math.IsInf(guesses, 1) {
+		return 0, false
+	}
+
+	switch {
+	case token == lower:
+	case token == strings.ToUpper(token) || token[1:] == lower[1:]:
+		guesses *= 2
+	default:
+		guesses *= 10
+	}
+	return guesses, true
+}
+
+// repeatGuesses matches a character or a short block repeated, e.g. "aaaa"
+// or "abcabc".
+func repeatGuesses(token []rune) (float64, bool) {
+	for size := 1; size <= len(token)/2; size++ {
+		if len(token)%size != 0 {
+			continue
+		}
+		block := string(token[:size])
+		if strings.Repeat(block, len(token)/size) != string(token) {
+			continue
+		}
+		base := math.Pow(float64(runeCardinality(token[0])), float64(size))
+		if size > 1 {
+			base = math.Pow10(int(math.Ceil(log10Guesses(token[:size], ""))))
+		}
+		return base * float64(len(token)/size), true
+	}
+	return 0, false
+}
+
+// sequenceGuesses matches runs of evenly spaced characters, e.g. "abcd",
+// "9753" or "zyx".
+func sequenceGuesses(token []rune) (float64, bool) {
+	delta := token[1] - token[0]
+	if delta == 0 || delta > 5 || delta < -5 {
+		return 0, false
+	}
+	for i := 2; i < len(token); i++ {
+		if token[i]-token[i-1] != delta {
+			return 0, false
+		}
+	}
+
+	base := float64(runeCardinality(token[0]))
+	if strings.ContainsRune("aAzZ019", token[0]) {
+		base = 4
+	}
+	if delta != 1 {
+		base *= 2
+	}
+	return base * float64(len(token)), true
+}
+
+// keyboardGuesses matches walks along a keyboard row, e.g. "asdf" or
+// "poiuy".
+func keyboardGuesses(token string) (float64, bool) {
+	lower := strings.ToLower(token)
+	for _, row := range keyboardRows {
+		if strings.Contains(row, lower) {
+			return 8 * float64(len(lower)), true
+		}
+		if strings.Contains(row, reverse(lower)) {
+			return 16 * float64(len(lower)), true
+		}
+	}
+	return 0, false
+}
+
+// digitsGuesses matches years and digits from the user's phone number.
+func digitsGuesses(token string, phoneDigits string) (float64, bool) {
+	if strings.Trim(token, "0123456789") != "" {
+		return 0, false
+	}
+	if len(token) == 4 && token >= "1900" && token <= "2049" {
+		return 150, true
+	}
+	if len(token) >= 4 && phoneDigits != "" && strings.Contains(phoneDigits, token) {
+		return 10, true
+	}
+	return 0, false
+}
+
+// runeCardinality is how many characters an attacker tries in place of r.
+func runeCardinality(r rune) int {
+	switch {
+	case r >= '0' && r <= '9':
+		return 10
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	case r < unicode.MaxASCII:
+		return 33
+	default:
+		return 100
+	}
+}
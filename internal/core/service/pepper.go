@@ -0,0 +1,88 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// minPepperLength is the shortest pepper secret accepted, in bytes.
+const minPepperLength = 16
+
+var pepperIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Pepper is a server-side secret mixed into password hashes with
+// HMAC-SHA256, so a copy of the user records alone is not enough to guess
+// passwords offline. Its ID is recorded in every hash it was used for.
+type Pepper struct {
+	ID     string
+	Secret []byte
+}
+
+// apply keys password with the pepper. Without one, password is used as is.
+func (p *Pepper) apply(password string) []byte {
+	if p == nil {
+		return []byte(password)
+	}
+	mac := hmac.New(sha256.New, p.Secret)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+func findPepper(peppers []Pepper, id string) *Pepper {
+	for i := range peppers {
+		if peppers[i].ID == id {
+			return &peppers[i]
+		}
+	}
+	return nil
+}
+
+// ParsePeppers reads a pepper file: one "<id> <base64 secret>" pair per
+// line, blank lines and lines starting with "#" ignored. The last pepper is
+// the one new hashes use; the others only verify existing hashes until
+// their users sign in again.
+func ParsePeppers(data []byte) ([]Pepper, error) {
+	var peppers []Pepper
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want \"<id> <base64 secret>\"", line)
+		}
+		id, encoded := fields[0], fields[1]
+
+		if !pepperIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("line %d: pepper ID %q must be 1 to 32 letters, digits, \"-\" or \"_\"", line, id)
+		}
+		if findPepper(peppers, id) != nil {
+			return nil, fmt.Errorf("line %d: duplicate pepper ID %q", line, id)
+		}
+
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: pepper %q: %w", line, id, err)
+		}
+		if len(secret) < minPepperLength {
+			return nil, fmt.Errorf("line %d: pepper %q must be at least %d bytes", line, id, minPepperLength)
+		}
+
+		peppers = append(peppers, Pepper{ID: id, Secret: secret})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return peppers, nil
+}
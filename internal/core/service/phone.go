@@ -0,0 +1,228 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// phoneRegion holds what is needed to read a region's phone numbers written
+// in national format and to validate them.
+type phoneRegion struct {
+	callingCode string
+	// trunkPrefix is dialled before national numbers within the region and
+	// dropped in international format.
+	trunkPrefix string
+	// internationalPrefix is dialled before a calling code to call abroad.
+	internationalPrefix string
+	// minLength and maxLength bound the national significant number.
+	minLength int
+	maxLength int
+	// pattern, when set, further restricts the national significant number.
+	pattern *regexp.Regexp
+}
+
+var nanpPattern = regexp.MustCompile(`^[2-9]\d{2}[2-9]\d{6}$`)
+
+// phoneRegions are the regions a deployment may pick as its default, keyed
+// by ISO 3166-1 alpha-2 code. Numbers from other regions are accepted in
+// international format with a known calling code and a generic length
+// check.
+var phoneRegions = map[string]phoneRegion{
+	"US": {callingCode: "1", trunkPrefix: "1", internationalPrefix: "011", minLength: 10, maxLength: 10, pattern: nanpPattern},
+	"CA": {callingCode: "1", trunkPrefix: "1", internationalPrefix: "011", minLength: 10, maxLength: 10, pattern: nanpPattern},
+	"MX": {callingCode: "52", internationalPrefix: "00", minLength: 10, maxLength: 10},
+	"GB": {callingCode: "44", trunkPrefix: "0", internationalPrefix: "00", minLength: 9, maxLength: 10},
+	"DE": {callingCode: "49", trunkPrefix: "0", internationalPrefix: "00", minLength: 6, maxLength: 13},
+	"FR": {callingCode: "33", trunkPrefix: "0", internationalPrefix: "00", minLength: 9, maxLength: 9},
+	"ES": {callingCode: "34", internationalPrefix: "00", minLength: 9, maxLength: 9},
+	"IT": {callingCode: "39", internationalPrefix: "00", minLength: 6, maxLength: 11},
+	"NL": {callingCode: "31", trunkPrefix: "0", internationalPrefix: "00", minLength: 9, maxLength: 9},
+	"BR": {callingCode: "55", trunkPrefix: "0", internationalPrefix: "00", minLength: 10, maxLength: 11},
+	"CO": {callingCode: "57", internationalPrefix: "00", minLength: 10, maxLength: 10},
+	"CL": {callingCode: "56", internationalPrefix: "00", minLength: 9, maxLength: 9},
+	"PE": {callingCode: "51", trunkPrefix: "0", internationalPrefix: "00", minLength: 8, maxLength: 9},
+	"IN": {callingCode: "91", trunkPrefix: "0", internationalPrefix: "00", minLength: 10, maxLength: 10},
+	"CN": {callingCode: "86", trunkPrefix: "0", internationalPrefix: "00", minLength: 9, maxLength: 11},
+	"JP": {callingCode: "81", trunkPrefix: "0", internationalPrefix: "010", minLength: 9, maxLength: 10},
+	"PH": {callingCode: "63", trunkPrefix: "0", internationalPrefix: "00", minLength: 8, maxLength: 10},
+	"AU": {callingCode: "61", trunkPrefix: "0", internationalPrefix: "0011", minLength: 9, maxLength: 9},
+	"NZ": {callingCode: "64", trunkPrefix: "0", internationalPrefix: "00", minLength: 8, maxLength: 10},
+	"ZA": {callingCode: "27", trunkPrefix: "0", internationalPrefix: "00", minLength: 9, maxLength: 9},
+	"NG": {callingCode: "234", trunkPrefix: "0", internationalPrefix: "009", minLength: 8, maxLength: 10},
+	"KE": {callingCode: "254", trunkPrefix: "0", internationalPrefix: "000", minLength: 9, maxLength: 9},
+}
+
+// phoneRegionsByCallingCode validates international numbers of the regions
+// above. Regions sharing a calling code share its rules.
+var phoneRegionsByCallingCode = func() map[string]phoneRegion {
+	regions := make(map[string]phoneRegion, len(phoneRegions))
+	for _, region := range phoneRegions {
+		regions[region.callingCode] = region
+	}
+	return regions
+}()
+
+// callingCodes are the country calling codes ITU-T assigns to geographic
+// areas. International numbers must start with one; codes of global
+// services such as +800 or +881 are left out, as they cannot take SMS.
+var callingCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, code := range strings.Fields(`
+		1 7
+		20 27 30 31 32 33 34 36 39 40 41 43 44 45 46 47 48 49
+		51 52 53 54 55 56 57 58 60 61 62 63 64 65 66 81 82 84 86
+		90 91 92 93 94 95 98
+		211 212 213 216 218
+		220 221 222 223 224 225 226 227 228 229
+		230 231 232 233 234 235 236 237 238 239
+		240 241 242 243 244 245 246 247 248 249
+		250 251 252 253 254 255 256 257 258
+		260 261 262 263 264 265 266 267 268 269
+		290 291 297 298 299
+		350 351 352 353 354 355 356 357 358 359
+		370 371 372 373 374 375 376 377 378
+		380 381 382 383 385 386 387 389
+		420 421 423
+		500 501 502 503 504 505 506 507 508 509
+		590 591 592 593 594 595 596 597 598 599
+		670 672 673 674 675 676 677 678 679
+		680 681 682 683 685 686 687 688 689
+		690 691 692
+		850 852 853 855 856 880 886
+		960 961 962 963 964 965 966 967 968
+		970 971 972 973 974 975 976 977
+		992 993 994 995 996 998
+	`) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// DefaultPhoneRegion is the region national numbers are read in unless
+// configured otherwise.
+const DefaultPhoneRegion = "US"
+
+func defaultPhoneNormalizer() *PhoneNormalizer {
+	return &PhoneNormalizer{region: phoneRegions[DefaultPhoneRegion]}
+}
+
+// phoneSeparators may appear in phone numbers as typed by people and are
+// dropped during normalization.
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "", "\u00a0", "")
+
+// PhoneNormalizer turns phone numbers into E.164, the form they are stored
+// and looked up in. Numbers without a calling code are read as numbers of
+// the default region.
+type PhoneNormalizer struct {
+	region phoneRegion
+}
+
+// NewPhoneNormalizer reads national numbers as numbers of defaultRegion, an
+// ISO 3166-1 alpha-2 code such as "US".
+func NewPhoneNormalizer(defaultRegion string) (*PhoneNormalizer, error) {
+	region, ok := phoneRegions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported phone region %q", defaultRegion)
+	}
+	return &PhoneNormalizer{region: region}, nil
+}
+
+// Normalize returns phone in E.164 form, e.g. "+12025550123", or
+// port.ErrInvalidPhoneNumber.
+func (p *PhoneNormalizer) Normalize(phone string) (string, error) {
+	digits := strings.TrimSpace(phone)
+
+	international := strings.HasPrefix(digits, "+")
+	if international {
+		// "(0)" after the calling code is a common way to show the trunk
+		// prefix
+		digits = strings.Replace(digits[1:], "(0)", "", 1)
+	}
+	digits = phoneSeparators.Replace(digits)
+
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return "", port.ErrInvalidPhoneNumber
+	}
+
+	if !international && strings.HasPrefix(digits, p.region.internationalPrefix) {
+		digits = strings.TrimPrefix(digits, p.region.internationalPrefix)
+		international = true
+	}
+
+	if international {
+		return normalizeInternational(digits)
+	}
+
+	return p.normalizeNational(digits)
+}
+
+func (p *PhoneNormalizer) normalizeNational(digits string) (string, error) {
+	region := p.region
+
+	if region.valid(digits) {
+		return "+" + region.callingCode + digits, nil
+	}
+
+	// Dialled with the trunk prefix, or with the calling code but no "+"
+	for _, prefix := range []string{region.trunkPrefix, region.callingCode} {
+		if prefix == "" || !strings.HasPrefix(digits, prefix) {
+			continue
+		}
+		if national := strings.TrimPrefix(digits, prefix); region.valid(national) {
+			return "+" + region.callingCode + national, nil
+		}
+	}
+
+	return "", port.ErrInvalidPhoneNumber
+}
+
+func normalizeInternational(digits string) (string, error) {
+	// E.164 numbers have at most 15 digits and calling codes never start
+	// with 0
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", port.ErrInvalidPhoneNumber
+	}
+
+	// Calling codes are prefix-free, so at most one of these matches
+	for length := 1; length <= 3; length++ {
+		region, ok := phoneRegionsByCallingCode[digits[:length]]
+		if !ok {
+			if callingCodes[digits[:length]] {
+				return "+" + digits, nil
+			}
+			continue
+		}
+
+		national := digits[length:]
+		if region.trunkPrefix != "" && !region.valid(national) && strings.HasPrefix(national, region.trunkPrefix) {
+			national = strings.TrimPrefix(national, region.trunkPrefix)
+		}
+		if !region.valid(national) {
+			return "", port.ErrInvalidPhoneNumber
+		}
+		return "+" + region.callingCode + national, nil
+	}
+
+	return "", port.ErrInvalidPhoneNumber
+}
+
+func (r phoneRegion) valid(national string) bool {
+	if len(national) < r.minLength || len(national) > r.maxLength {
+		return false
+	}
+	return r.pattern == nil || r.pattern.MatchString(national)
+}
+
+// lookupPhone normalizes a phone number to look an account up by. Numbers
+// that cannot be normalized are used as typed, so accounts stored before
+// normalization was introduced can still sign in until they are migrated.
+func (a *authService) lookupPhone(phone string) string {
+	normalized, err := a.phones.Normalize(phone)
+	if err != nil {
+		return strings.TrimSpace(phone)
+	}
+	return normalized
+}
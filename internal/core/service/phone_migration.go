@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+// MigratePhoneNumbers rewrites every stored phone number to E.164. Accounts
+// whose numbers normalize to the same E.164 number, or to one the phone
+// index already maps to another user ID, are left alone and reported as
+// collisions, as are numbers that cannot be normalized.
+func (a *authService) MigratePhoneNumbers(ctx context.Context, dryRun bool) (*domain.PhoneMigration, error) {
+	users, err := a.authRepo.ListUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("user listing failed: %w", err)
+	}
+
+	report := &domain.PhoneMigration{Scanned: len(users)}
+
+	normalized := make(map[string]string, len(users))
+	byPhone := make(map[string][]string, len(users))
+	for _, user := range users {
+		phone, err := a.phones.Normalize(user.Phonenumber)
+		if err != nil {
+			report.Invalid = append(report.Invalid, domain.PhoneChange{UserID: user.ID, From: user.Phonenumber})
+			continue
+		}
+		normalized[user.ID] = phone
+		byPhone[phone] = append(byPhone[phone], user.ID)
+	}
+
+	for phone, userIDs := range byPhone {
+		if len(userIDs) > 1 {
+			sort.Strings(userIDs)
+			report.Collisions = append(report.Collisions, domain.PhoneCollision{Phonenumber: phone, UserIDs: userIDs})
+		}
+	}
+
+	for _, user := range users {
+		phone, ok := normalized[user.ID]
+		if !ok || len(byPhone[phone]) > 1 {
+			continue
+		}
+
+		if phone == user.Phonenumber {
+			report.Unchanged++
+			continue
+		}
+
+		// The phone index may map the number elsewhere even when no listed
+		// account has it, and rewriting would take the entry over
+		owner, err := a.authRepo.PhoneOwner(ctx, phone)
+		if err != nil {
+			return report, fmt.Errorf("phone index lookup for user %s failed: %w", user.ID, err)
+		}
+		if owner != "" && owner != user.ID {
+			userIDs := []string{user.ID, owner}
+			sort.Strings(userIDs)
+			report.Collisions = append(report.Collisions, domain.PhoneCollision{Phonenumber: phone, UserIDs: userIDs})
+			continue
+		}
+
+		change := domain.PhoneChange{UserID: user.ID, From: user.Phonenumber, To: phone}
+		if !dryRun {
+			user.Phonenumber = phone
+			if _, err := a.authRepo.UpdateUser(ctx, user); err != nil {
+				return report, fmt.Errorf("phone number update for user %s failed: %w", user.ID, err)
+			}
+		}
+		report.Rewritten = append(report.Rewritten, change)
+	}
+
+	sort.Slice(report.Collisions, func(i, j int) bool {
+		return report.Collisions[i].Phonenumber < report.Collisions[j].Phonenumber
+	})
+	return report, nil
+}
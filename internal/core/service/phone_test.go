@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestPhoneNormalizer(t *testing.T) {
+	us, err := NewPhoneNormalizer("US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gb, err := NewPhoneNormalizer("gb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		normalizer *PhoneNormalizer
+		phone      string
+		want       string
+	}{
+		{"e164", us, "+12025550123", "+12025550123"},
+		{"formatted", us, "+1 (202) 555-0123", "+12025550123"},
+		{"national", us, "202.555.0123", "+12025550123"},
+		{"trunk prefix", us, "1 202 555 0123", "+12025550123"},
+		{"international prefix", us, "011 44 20 7946 0018", "+442079460018"},
+		{"other region", us, "+44 20 7946 0018", "+442079460018"},
+		{"trunk prefix in parentheses", us, "+44 (0)20 7946 0018", "+442079460018"},
+		{"default region", gb, "020 7946 0018", "+442079460018"},
+		{"region without rules", gb, "+7 495 123-45-67", "+74951234567"},
+		{"three digit calling code", us, "+373 22 123 456", "+37322123456"},
+		{"unassigned calling code", us, "+28 1234 5678", ""},
+		{"unassigned three digit calling code", us, "+999 1234 5678", ""},
+		{"global service calling code", us, "+800 1234 5678", ""},
+		{"invalid area code", us, "1234567890", ""},
+		{"too short", us, "+1 234 567 890", ""},
+		{"letters", us, "+1 202 555 CALL", ""},
+		{"too long", us, "+7 1234 5678 9012 345", ""},
+		{"empty", us, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.normalizer.Normalize(tt.phone)
+			if tt.want == "" {
+				if !errors.Is(err, port.ErrInvalidPhoneNumber) {
+					t.Fatalf("Normalize(%q) = %q, %v, want ErrInvalidPhoneNumber", tt.phone, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Normalize(%q) = %q, %v, want %q", tt.phone, got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewPhoneNormalizer("XX"); err == nil {
+		t.Fatal("expected an unknown region to be rejected")
+	}
+}
+
+func TestMigratePhoneNumbers(t *testing.T) {
+	ctx := context.Background()
+	a, repo := newTestService(t)
+	for id, phone := range map[string]string{
+		"u1": "(202) 555-0123",
+		"u2": "+12025550199",
+		"u3": "202 555 0144",
+		"u4": "+1 202 555 0144",
+		"u5": "555",
+		"u6": "202.555.0177",
+	} {
+		repo.users[id] = domain.User{ID: id, Phonenumber: phone}
+	}
+	// Left behind by an account deleted before normalization
+	repo.stalePhones["+12025550177"] = "ghost"
+
+	wantCollisions := []domain.PhoneCollision{
+		{Phonenumber: "+12025550144", UserIDs: []string{"u3", "u4"}},
+		{Phonenumber: "+12025550177", UserIDs: []string{"ghost", "u6"}},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		report, err := a.MigratePhoneNumbers(ctx, dryRun)
+		if err != nil {
+			t.Fatalf("MigratePhoneNumbers(dryRun=%v): %v", dryRun, err)
+		}
+
+		if report.Scanned != 6 || report.Unchanged != 1 || len(report.Invalid) != 1 || report.Invalid[0].UserID != "u5" {
+			t.Errorf("dryRun=%v: unexpected report %+v", dryRun, report)
+		}
+		wantRewritten := []domain.PhoneChange{{UserID: "u1", From: "(202) 555-0123", To: "+12025550123"}}
+		if !reflect.DeepEqual(report.Rewritten, wantRewritten) {
+			t.Errorf("dryRun=%v: rewritten = %+v, want %+v", dryRun, report.Rewritten, wantRewritten)
+		}
+		if !reflect.DeepEqual(report.Collisions, wantCollisions) {
+			t.Errorf("dryRun=%v: collisions = %+v, want %+v", dryRun, report.Collisions, wantCollisions)
+		}
+
+		want := "(202) 555-0123"
+		if !dryRun {
+			want = "+12025550123"
+		}
+		if got := repo.users["u1"].Phonenumber; got != want {
+			t.Errorf("dryRun=%v: u1 phone = %q, want %q", dryRun, got, want)
+		}
+		if got := repo.users["u6"].Phonenumber; got != "202.555.0177" {
+			t.Errorf("dryRun=%v: u6 rewritten onto an indexed number: %q", dryRun, got)
+		}
+	}
+}
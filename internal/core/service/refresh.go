@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// RefreshSession rotates a refresh token: the presented token is spent, the
+// session it was issued with ends, and a new session with a new refresh
+// token is issued in the same family. A spent token presented again means it
+// leaked, so the whole family is revoked.
+func (a *authService) RefreshSession(ctx context.Context, refreshToken string, device domain.Device) (*domain.Session, error) {
+	refresh, first, err := a.authRepo.UseRefreshToken(ctx, refreshToken)
+	if err != nil {
+		if errors.Is(err, port.ErrRefreshTokenNotFound) {
+			return nil, port.ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("refresh token lookup failed: %w", err)
+	}
+
+	if !first {
+		if err := a.authRepo.RevokeRefreshFamily(ctx, refresh.UserID, refresh.FamilyID); err != nil {
+			return nil, fmt.Errorf("refresh family revocation failed: %w", err)
+		}
+
+		a.recordEvent(ctx, domain.SecurityEvent{
+			Type:   domain.EventRefreshTokenReuse,
+			UserID: refresh.UserID,
+			Details: map[string]string{
+				"family_id":  refresh.FamilyID,
+				"session_id": refresh.SessionID,
+				"ip_address": device.IPAddress,
+				"user_agent": device.UserAgent,
+			},
+		})
+
+		return nil, port.ErrRefreshTokenReused
+	}
+
+	// The family ends with the sign in that started it, however often it
+	// was rotated
+	now := time.Now()
+	signedInAt := refresh.SignedInAt
+	if signedInAt.IsZero() {
+		signedInAt = refresh.CreatedAt
+	}
+	if now.After(refresh.ExpiresAt) || !a.sessionPolicy.expiry(signedInAt, now).After(now) {
+		return nil, port.ErrRefreshTokenNotFound
+	}
+
+	return a.startSession(ctx, refresh.UserID, refresh.FamilyID, signedInAt, device)
+}
+
+// issueRefreshToken mints and saves the refresh token for a new session. It
+// expires RefreshLifetime after the sign in the session descends from.
+func (a *authService) issueRefreshToken(ctx context.Context, session *domain.Session) error {
+	now := time.Now()
+
+	refresh := domain.RefreshToken{
+		Token:      generateToken(),
+		FamilyID:   session.FamilyID,
+		UserID:     session.UserID,
+		SessionID:  session.ID,
+		CreatedAt:  now,
+		SignedInAt: session.SignedInAt,
+		ExpiresAt:  session.SignedInAt.Add(a.sessionPolicy.RefreshLifetime),
+	}
+
+	if err := a.authRepo.SaveRefreshToken(ctx, refresh); err != nil {
+		return err
+	}
+
+	session.RefreshToken = refresh.Token
+	return nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestRefreshSession(t *testing.T) {
+	ctx := context.Background()
+	device := domain.Device{UserAgent: "test", IPAddress: "192.0.2.1"}
+
+	t.Run("rotation replaces the session", func(t *testing.T) {
+		a, repo := newTestService(t)
+		first, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := a.RefreshSession(ctx, first.RefreshToken, device)
+		if err != nil {
+			t.Fatalf("RefreshSession: %v", err)
+		}
+		if second.Token == first.Token || second.RefreshToken == first.RefreshToken {
+			t.Fatal("RefreshSession reissued the old credentials")
+		}
+		if second.FamilyID != first.FamilyID {
+			t.Errorf("family = %s, want %s", second.FamilyID, first.FamilyID)
+		}
+
+		// The refreshed session is over, so a stolen copy of it is useless
+		if _, err := a.ReadSession(ctx, first.Token); err == nil {
+			t.Error("old session still readable after refresh")
+		}
+		if revoked, _ := repo.IsSessionRevoked(ctx, first.ID); !revoked {
+			t.Error("old session not denylisted, its access tokens still verify")
+		}
+		if _, err := a.ReadSession(ctx, second.Token); err != nil {
+			t.Errorf("ReadSession(new session): %v", err)
+		}
+
+		sessions, err := a.ListSessions(ctx, "user-1")
+		if err != nil || len(sessions) != 1 {
+			t.Errorf("ListSessions = %d sessions, %v, want 1", len(sessions), err)
+		}
+	})
+
+	t.Run("reuse revokes the family", func(t *testing.T) {
+		a, repo := newTestService(t)
+		first, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := a.RefreshSession(ctx, first.RefreshToken, device)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := a.RefreshSession(ctx, first.RefreshToken, device); !errors.Is(err, port.ErrRefreshTokenReused) {
+			t.Fatalf("RefreshSession(spent token) = %v, want ErrRefreshTokenReused", err)
+		}
+
+		if _, err := a.ReadSession(ctx, second.Token); err == nil {
+			t.Error("session of the reused family survived")
+		}
+		if _, err := a.RefreshSession(ctx, second.RefreshToken, device); !errors.Is(err, port.ErrRefreshTokenNotFound) {
+			t.Errorf("RefreshSession(latest token of the family) = %v, want ErrRefreshTokenNotFound", err)
+		}
+		if _, err := a.ReadSession(ctx, other.Token); err != nil {
+			t.Errorf("session of another family: %v", err)
+		}
+		if !slices.Contains(repo.eventTypes(), domain.EventRefreshTokenReuse) {
+			t.Errorf("events = %v, want %s", repo.eventTypes(), domain.EventRefreshTokenReuse)
+		}
+	})
+
+	t.Run("rotation keeps the sign in time", func(t *testing.T) {
+		policy := SessionPolicy{IdleTimeout: 30 * time.Minute, MaxLifetime: time.Hour, RefreshLifetime: 24 * time.Hour}
+		a, repo := newTestService(t, WithSessionPolicy(policy))
+		first, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Signed in 50 minutes ago, so the idle timeout reaches past the cap
+		signedInAt := time.Now().Add(-50 * time.Minute)
+		refresh := repo.refresh[first.RefreshToken]
+		refresh.SignedInAt = signedInAt
+		repo.refresh[first.RefreshToken] = refresh
+
+		second, err := a.RefreshSession(ctx, first.RefreshToken, device)
+		if err != nil {
+			t.Fatalf("RefreshSession: %v", err)
+		}
+		if !second.SignedInAt.Equal(signedInAt) || !second.ExpiresAt.Equal(signedInAt.Add(policy.MaxLifetime)) {
+			t.Errorf("session signed in at %v expiring %v, want %v expiring %v",
+				second.SignedInAt, second.ExpiresAt, signedInAt, signedInAt.Add(policy.MaxLifetime))
+		}
+		if expiresAt := repo.refresh[second.RefreshToken].ExpiresAt; !expiresAt.Equal(signedInAt.Add(policy.RefreshLifetime)) {
+			t.Errorf("refresh token expires %v, want %v", expiresAt, signedInAt.Add(policy.RefreshLifetime))
+		}
+	})
+
+	t.Run("refreshing past the maximum lifetime fails", func(t *testing.T) {
+		policy := SessionPolicy{IdleTimeout: 30 * time.Minute, MaxLifetime: time.Hour, RefreshLifetime: 24 * time.Hour}
+		a, repo := newTestService(t, WithSessionPolicy(policy))
+		session, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := a.RefreshSession(ctx, session.RefreshToken, device)
+		if err != nil {
+			t.Fatalf("RefreshSession: %v", err)
+		}
+
+		// The rotated token is fresh, but the sign in is past the cap
+		refresh := repo.refresh[second.RefreshToken]
+		refresh.SignedInAt = time.Now().Add(-policy.MaxLifetime - time.Minute)
+		repo.refresh[second.RefreshToken] = refresh
+
+		if _, err := a.RefreshSession(ctx, second.RefreshToken, device); !errors.Is(err, port.ErrRefreshTokenNotFound) {
+			t.Fatalf("RefreshSession after the cap = %v, want ErrRefreshTokenNotFound", err)
+		}
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		a, _ := newTestService(t)
+		if _, err := a.RefreshSession(ctx, "no-such-token", device); !errors.Is(err, port.ErrRefreshTokenNotFound) {
+			t.Fatalf("RefreshSession = %v, want ErrRefreshTokenNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,459 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// testRepo is an in-memory port.AuthRepository for service tests. It keeps
+// tokens unhashed; the Redis repository is tested on its own.
+type testRepo struct {
+	mu sync.Mutex
+
+	users    map[string]domain.User
+	sessions map[string]domain.Session
+	revoked  map[string]bool
+	refresh  map[string]domain.RefreshToken
+	events   []domain.SecurityEvent
+	clients  map[string]domain.Client
+	authCode map[string]domain.AuthorizationCode
+	codes    map[string]domain.OneTimeCode
+	limits   map[string]int64
+	failures map[string]domain.LoginFailures
+	// stalePhones are phone index entries left pointing at another user,
+	// which the Redis repository can have.
+	stalePhones map[string]string
+}
+
+func newTestRepo() *testRepo {
+	return &testRepo{
+		users:    make(map[string]domain.User),
+		sessions: make(map[string]domain.Session),
+		revoked:  make(map[string]bool),
+		refresh:  make(map[string]domain.RefreshToken),
+		clients:  make(map[string]domain.Client),
+		authCode: make(map[string]domain.AuthorizationCode),
+		codes:    make(map[string]domain.OneTimeCode),
+		limits:   make(map[string]int64),
+		failures: make(map[string]domain.LoginFailures),
+
+		stalePhones: make(map[string]string),
+	}
+}
+
+// testArgon2Params keep password hashing in tests cheap.
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+// newTestService returns a service on a fresh testRepo, with cheap password
+// hashing unless opts say otherwise.
+func newTestService(t *testing.T, opts ...Option) (*authService, *testRepo) {
+	t.Helper()
+	repo := newTestRepo()
+	opts = append([]Option{WithArgon2Params(testArgon2Params)}, opts...)
+	return NewAuthService(repo, opts...).(*authService), repo
+}
+
+// eventTypes lists the types of the recorded security events in order.
+func (r *testRepo) eventTypes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := make([]string, len(r.events))
+	for i, event := range r.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func (r *testRepo) SaveUser(ctx context.Context, user domain.User) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = user
+	return user.ID, nil
+}
+
+func (r *testRepo) ReadUserByID(ctx context.Context, id string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, port.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// ReadUserByPhone returns nil, nil for unknown numbers like the Redis
+// repository.
+func (r *testRepo) ReadUserByPhone(ctx context.Context, phone string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Phonenumber == phone {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *testRepo) PhoneOwner(ctx context.Context, phone string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.stalePhones[phone]; ok {
+		return id, nil
+	}
+	for _, user := range r.users {
+		if user.Phonenumber == phone {
+			return user.ID, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *testRepo) UpdateUser(ctx context.Context, user domain.User) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[user.ID]; !ok {
+		return nil, port.ErrUserNotFound
+	}
+	r.users[user.ID] = user
+	return &user, nil
+}
+
+func (r *testRepo) UpdatePasswordHash(ctx context.Context, userID string, oldHash string, newHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return false, port.ErrUserNotFound
+	}
+	if user.Password != oldHash {
+		return false, nil
+	}
+	user.Password = newHash
+	r.users[userID] = user
+	return true, nil
+}
+
+func (r *testRepo) DeleteUser(ctx context.Context, user domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users, user.ID)
+	return nil
+}
+
+func (r *testRepo) ListUsers(ctx context.Context) ([]domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := make([]domain.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (r *testRepo) SaveSession(ctx context.Context, session domain.Session, userid string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.UserID = userid
+	r.sessions[session.Token] = session
+	return session.Token, nil
+}
+
+func (r *testRepo) FindSessionByToken(ctx context.Context, token string) (*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[token]
+	if !ok {
+		return nil, port.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (r *testRepo) ExtendSession(ctx context.Context, session domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.sessions[session.Token]
+	if !ok {
+		return port.ErrSessionNotFound
+	}
+	stored.ExpiresAt = session.ExpiresAt
+	r.sessions[session.Token] = stored
+	return nil
+}
+
+func (r *testRepo) ListSessions(ctx context.Context, userid string) ([]domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []domain.Session
+	for _, session := range r.sessions {
+		if session.UserID == userid {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *testRepo) DeleteSession(ctx context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[token]
+	if !ok {
+		return port.ErrSessionNotFound
+	}
+	r.endSession(token)
+	r.revokeFamily(session.FamilyID)
+	return nil
+}
+
+func (r *testRepo) RevokeAllSessions(ctx context.Context, userid string, exceptToken string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keepFamily := r.sessions[exceptToken].FamilyID
+	revoked := 0
+	for token, session := range r.sessions {
+		if session.UserID == userid && token != exceptToken {
+			r.endSession(token)
+			revoked++
+		}
+	}
+	for _, refresh := range r.refresh {
+		if refresh.UserID == userid && refresh.FamilyID != keepFamily {
+			r.revokeFamily(refresh.FamilyID)
+		}
+	}
+	return revoked, nil
+}
+
+func (r *testRepo) RevokeSessionByID(ctx context.Context, userid string, sessionid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for token, session := range r.sessions {
+		if session.UserID == userid && session.ID == sessionid {
+			r.endSession(token)
+			r.revokeFamily(session.FamilyID)
+			return nil
+		}
+	}
+	return port.ErrSessionNotFound
+}
+
+func (r *testRepo) PruneSessions(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (r *testRepo) IsSessionRevoked(ctx context.Context, sessionid string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.revoked[sessionid], nil
+}
+
+// endSession deletes a session and denylists its ID. r.mu must be held.
+func (r *testRepo) endSession(token string) {
+	r.revoked[r.sessions[token].ID] = true
+	delete(r.sessions, token)
+}
+
+// revokeFamily deletes the refresh tokens of a family. r.mu must be held.
+func (r *testRepo) revokeFamily(familyid string) {
+	for token, refresh := range r.refresh {
+		if refresh.FamilyID == familyid {
+			delete(r.refresh, token)
+		}
+	}
+}
+
+func (r *testRepo) SaveRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refresh[token.Token] = token
+	return nil
+}
+
+func (r *testRepo) UseRefreshToken(ctx context.Context, token string) (*domain.RefreshToken, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refresh, ok := r.refresh[token]
+	if !ok {
+		return nil, false, port.ErrRefreshTokenNotFound
+	}
+	if refresh.UsedAt != nil {
+		return &refresh, false, nil
+	}
+
+	used := refresh
+	now := time.Now()
+	used.UsedAt = &now
+	r.refresh[token] = used
+	for sessionToken, session := range r.sessions {
+		if session.ID == refresh.SessionID {
+			r.endSession(sessionToken)
+		}
+	}
+	return &refresh, true, nil
+}
+
+func (r *testRepo) FindRefreshToken(ctx context.Context, token string) (*domain.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refresh, ok := r.refresh[token]
+	if !ok {
+		return nil, port.ErrRefreshTokenNotFound
+	}
+	return &refresh, nil
+}
+
+func (r *testRepo) RevokeRefreshFamily(ctx context.Context, userid string, familyid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revokeFamily(familyid)
+	for token, session := range r.sessions {
+		if session.UserID == userid && session.FamilyID == familyid {
+			r.endSession(token)
+		}
+	}
+	return nil
+}
+
+func (r *testRepo) SaveEvent(ctx context.Context, event domain.SecurityEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *testRepo) SaveClient(ctx context.Context, client domain.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[client.ID] = client
+	return nil
+}
+
+func (r *testRepo) ReadClient(ctx context.Context, id string) (*domain.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[id]
+	if !ok {
+		return nil, port.ErrClientNotFound
+	}
+	return &client, nil
+}
+
+func (r *testRepo) ListClients(ctx context.Context) ([]domain.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := make([]domain.Client, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+func (r *testRepo) DeleteClient(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clients[id]; !ok {
+		return port.ErrClientNotFound
+	}
+	delete(r.clients, id)
+	return nil
+}
+
+func (r *testRepo) SaveAuthorizationCode(ctx context.Context, code domain.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.authCode[code.Code] = code
+	return nil
+}
+
+func (r *testRepo) ConsumeAuthorizationCode(ctx context.Context, code string) (*domain.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.authCode[code]
+	if !ok {
+		return nil, port.ErrAuthorizationCodeNotFound
+	}
+	delete(r.authCode, code)
+	return &stored, nil
+}
+
+func (r *testRepo) SaveOneTimeCode(ctx context.Context, code domain.OneTimeCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes[code.Purpose+":"+code.Subject] = code
+	return nil
+}
+
+// AttemptOneTimeCode treats codes past ExpiresAt as gone, as Redis expires
+// them.
+func (r *testRepo) AttemptOneTimeCode(ctx context.Context, purpose string, subject string) (*domain.OneTimeCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	code, ok := r.codes[purpose+":"+subject]
+	if !ok || time.Now().After(code.ExpiresAt) {
+		return nil, port.ErrOneTimeCodeNotFound
+	}
+	code.Attempts++
+	r.codes[purpose+":"+subject] = code
+	return &code, nil
+}
+
+func (r *testRepo) DeleteOneTimeCode(ctx context.Context, purpose string, subject string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.codes[purpose+":"+subject]
+	delete(r.codes, purpose+":"+subject)
+	return ok, nil
+}
+
+func (r *testRepo) HitRateLimit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limits[key]++
+	return r.limits[key], window, nil
+}
+
+func (r *testRepo) ReserveLoginAttempt(ctx context.Context, phonenumber string, expected int, at time.Time, ttl time.Duration) (*domain.LoginFailures, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failures := r.failures[phonenumber]
+	if failures.Count != expected {
+		return nil, nil
+	}
+	failures.Count++
+	failures.LastFailureAt = at
+	r.failures[phonenumber] = failures
+	return &failures, nil
+}
+
+func (r *testRepo) ReleaseLoginAttempt(ctx context.Context, phonenumber string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failures := r.failures[phonenumber]
+	if failures.Count--; failures.Count <= 0 {
+		delete(r.failures, phonenumber)
+	} else {
+		r.failures[phonenumber] = failures
+	}
+	return nil
+}
+
+func (r *testRepo) ReadLoginFailures(ctx context.Context, phonenumber string) (*domain.LoginFailures, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failures := r.failures[phonenumber]
+	return &failures, nil
+}
+
+func (r *testRepo) ClearLoginFailures(ctx context.Context, phonenumber string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.failures[phonenumber]
+	delete(r.failures, phonenumber)
+	return ok, nil
+}
+
+var _ port.AuthRepository = (*testRepo)(nil)
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+// sessionExtensionGranularity keeps ReadSession from rewriting a session on
+// every request; the expiry only moves once it would gain at least this much.
+const sessionExtensionGranularity = time.Minute
+
+// SessionPolicy bounds how long a session lives. Every successful read pushes
+// the expiry out to IdleTimeout from now, but never past MaxLifetime from the
+// moment the user signed in. Refresh tokens live until RefreshLifetime after
+// the sign in too; rotating them does not start either clock again.
+type SessionPolicy struct {
+	IdleTimeout     time.Duration
+	MaxLifetime     time.Duration
+	RefreshLifetime time.Duration
+}
+
+func DefaultSessionPolicy() SessionPolicy {
+	return SessionPolicy{
+		IdleTimeout:     30 * time.Minute,
+		MaxLifetime:     12 * time.Hour,
+		RefreshLifetime: 30 * 24 * time.Hour,
+	}
+}
+
+// Validate reports whether every duration of the policy is positive.
+func (p SessionPolicy) Validate() error {
+	switch {
+	case p.IdleTimeout <= 0:
+		return errors.New("session idle timeout must be positive")
+	case p.MaxLifetime <= 0:
+		return errors.New("session maximum lifetime must be positive")
+	case p.RefreshLifetime <= 0:
+		return errors.New("session refresh lifetime must be positive")
+	}
+	return nil
+}
+
+// expiry returns when a session created at createdAt and last used at now
+// should expire.
+func (p SessionPolicy) expiry(createdAt, now time.Time) time.Time {
+	idle := now.Add(p.IdleTimeout)
+	absolute := createdAt.Add(p.MaxLifetime)
+	if idle.After(absolute) {
+		return absolute
+	}
+	return idle
+}
+
+// sessionSignedInAt is when the user signed in to get a session. Sessions
+// stored before that was recorded count from their creation.
+func sessionSignedInAt(session *domain.Session) time.Time {
+	if session.SignedInAt.IsZero() {
+		return session.CreatedAt
+	}
+	return session.SignedInAt
+}
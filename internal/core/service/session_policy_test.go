@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestSessionPolicyValidate(t *testing.T) {
+	if err := DefaultSessionPolicy().Validate(); err != nil {
+		t.Fatalf("default policy: %v", err)
+	}
+
+	for name, policy := range map[string]SessionPolicy{
+		"idle timeout":     {MaxLifetime: time.Hour, RefreshLifetime: time.Hour},
+		"max lifetime":     {IdleTimeout: time.Hour, MaxLifetime: -time.Hour, RefreshLifetime: time.Hour},
+		"refresh lifetime": {IdleTimeout: time.Hour, MaxLifetime: time.Hour},
+	} {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("expected a policy without a positive %s to be rejected", name)
+		}
+	}
+}
+
+func TestReadSession(t *testing.T) {
+	ctx := context.Background()
+	device := domain.Device{UserAgent: "test", IPAddress: "192.0.2.1"}
+	policy := SessionPolicy{
+		IdleTimeout:     30 * time.Minute,
+		MaxLifetime:     2 * time.Hour,
+		RefreshLifetime: 24 * time.Hour,
+	}
+
+	// setup returns a session whose stored times are moved by adjust
+	setup := func(t *testing.T, adjust func(session *domain.Session)) (*authService, *testRepo, *domain.Session) {
+		t.Helper()
+		a, repo := newTestService(t, WithSessionPolicy(policy))
+		session, err := a.CreateSession(ctx, "user-1", device)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+
+		stored := repo.sessions[session.Token]
+		adjust(&stored)
+		repo.sessions[session.Token] = stored
+		return a, repo, session
+	}
+
+	t.Run("slides the idle timeout", func(t *testing.T) {
+		a, repo, session := setup(t, func(session *domain.Session) {
+			session.ExpiresAt = time.Now().Add(10 * time.Minute)
+		})
+
+		before := time.Now()
+		read, err := a.ReadSession(ctx, session.Token)
+		if err != nil {
+			t.Fatalf("ReadSession: %v", err)
+		}
+		if read.ExpiresAt.Before(before.Add(policy.IdleTimeout)) {
+			t.Errorf("expires at %v, want at least %v", read.ExpiresAt, before.Add(policy.IdleTimeout))
+		}
+		if stored := repo.sessions[session.Token]; !stored.ExpiresAt.Equal(read.ExpiresAt) {
+			t.Errorf("stored expiry %v, want %v", stored.ExpiresAt, read.ExpiresAt)
+		}
+	})
+
+	t.Run("leaves small extensions unwritten", func(t *testing.T) {
+		var expiresAt time.Time
+		a, repo, session := setup(t, func(session *domain.Session) {
+			expiresAt = time.Now().Add(policy.IdleTimeout - sessionExtensionGranularity/2)
+			session.ExpiresAt = expiresAt
+		})
+
+		read, err := a.ReadSession(ctx, session.Token)
+		if err != nil {
+			t.Fatalf("ReadSession: %v", err)
+		}
+		if !read.ExpiresAt.Equal(expiresAt) || !repo.sessions[session.Token].ExpiresAt.Equal(expiresAt) {
+			t.Errorf("expiry moved from %v to %v", expiresAt, read.ExpiresAt)
+		}
+	})
+
+	t.Run("never passes the maximum lifetime", func(t *testing.T) {
+		var signedInAt time.Time
+		a, repo, session := setup(t, func(session *domain.Session) {
+			signedInAt = time.Now().Add(-policy.MaxLifetime + 10*time.Minute)
+			session.SignedInAt = signedInAt
+			session.ExpiresAt = time.Now().Add(5 * time.Minute)
+		})
+
+		read, err := a.ReadSession(ctx, session.Token)
+		if err != nil {
+			t.Fatalf("ReadSession: %v", err)
+		}
+		want := signedInAt.Add(policy.MaxLifetime)
+		if !read.ExpiresAt.Equal(want) || !repo.sessions[session.Token].ExpiresAt.Equal(want) {
+			t.Errorf("expires at %v, want the cap at %v", read.ExpiresAt, want)
+		}
+	})
+
+	t.Run("deletes expired sessions", func(t *testing.T) {
+		a, repo, session := setup(t, func(session *domain.Session) {
+			session.ExpiresAt = time.Now().Add(-time.Second)
+		})
+
+		if _, err := a.ReadSession(ctx, session.Token); !errors.Is(err, port.ErrSessionExpired) {
+			t.Fatalf("expected ErrSessionExpired, got %v", err)
+		}
+		if _, ok := repo.sessions[session.Token]; ok {
+			t.Error("expired session still stored")
+		}
+	})
+}
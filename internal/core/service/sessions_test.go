@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+func TestListSessions(t *testing.T) {
+	ctx := context.Background()
+	a, repo := newTestService(t)
+
+	phone, err := a.CreateSession(ctx, "user-1", domain.Device{UserAgent: "phone", IPAddress: "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	laptop, err := a.CreateSession(ctx, "user-1", domain.Device{UserAgent: "laptop", IPAddress: "192.0.2.2"})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if _, err := a.CreateSession(ctx, "user-2", domain.Device{UserAgent: "other", IPAddress: "192.0.2.3"}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	// Expired sessions can linger in the user's session index
+	expired := repo.sessions[laptop.Token]
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	repo.sessions[laptop.Token] = expired
+
+	sessions, err := a.ListSessions(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != phone.ID {
+		t.Fatalf("expected only the unexpired session %s, got %+v", phone.ID, sessions)
+	}
+	if sessions[0].Device.UserAgent != "phone" || sessions[0].CreatedAt.IsZero() {
+		t.Errorf("expected the session's device and creation time, got %+v", sessions[0])
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	ctx := context.Background()
+	device := domain.Device{UserAgent: "test", IPAddress: "192.0.2.1"}
+
+	// setup signs user-1 in on two devices and user-2 on one
+	setup := func(t *testing.T) (a *authService, repo *testRepo, current, other, stranger *domain.Session) {
+		t.Helper()
+		a, repo = newTestService(t)
+		sessions := make([]*domain.Session, 3)
+		for i, userID := range []string{"user-1", "user-1", "user-2"} {
+			session, err := a.CreateSession(ctx, userID, device)
+			if err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+			sessions[i] = session
+		}
+		return a, repo, sessions[0], sessions[1], sessions[2]
+	}
+
+	t.Run("sign out everywhere but here", func(t *testing.T) {
+		a, repo, current, other, stranger := setup(t)
+
+		revoked, err := a.RevokeAllSessions(ctx, "user-1", current.Token)
+		if err != nil {
+			t.Fatalf("RevokeAllSessions: %v", err)
+		}
+		if revoked != 1 {
+			t.Errorf("revoked %d sessions, want 1", revoked)
+		}
+
+		if _, err := a.ReadSession(ctx, current.Token); err != nil {
+			t.Errorf("current session ended: %v", err)
+		}
+		if _, err := a.ReadSession(ctx, stranger.Token); err != nil {
+			t.Errorf("another user's session ended: %v", err)
+		}
+		if _, err := a.ReadSession(ctx, other.Token); err == nil {
+			t.Error("other session still readable")
+		}
+		if !repo.revoked[other.ID] {
+			t.Error("other session not denylisted")
+		}
+		if _, err := a.RefreshSession(ctx, other.RefreshToken, device); err == nil {
+			t.Error("other session's refresh token still works")
+		}
+		if _, err := a.RefreshSession(ctx, current.RefreshToken, device); err != nil {
+			t.Errorf("current session's refresh token stopped working: %v", err)
+		}
+	})
+
+	t.Run("sign out everywhere", func(t *testing.T) {
+		a, _, current, other, _ := setup(t)
+
+		revoked, err := a.RevokeAllSessions(ctx, "user-1", "")
+		if err != nil {
+			t.Fatalf("RevokeAllSessions: %v", err)
+		}
+		if revoked != 2 {
+			t.Errorf("revoked %d sessions, want 2", revoked)
+		}
+		for _, session := range []*domain.Session{current, other} {
+			if _, err := a.ReadSession(ctx, session.Token); err == nil {
+				t.Errorf("session %s still readable", session.ID)
+			}
+		}
+	})
+
+	t.Run("revoke one session", func(t *testing.T) {
+		a, repo, current, other, _ := setup(t)
+
+		if err := a.RevokeSessionByID(ctx, "user-1", other.ID); err != nil {
+			t.Fatalf("RevokeSessionByID: %v", err)
+		}
+		if _, err := a.ReadSession(ctx, other.Token); err == nil {
+			t.Error("revoked session still readable")
+		}
+		if !repo.revoked[other.ID] {
+			t.Error("revoked session not denylisted")
+		}
+		if _, err := a.RefreshSession(ctx, other.RefreshToken, device); err == nil {
+			t.Error("revoked session's refresh token still works")
+		}
+		if _, err := a.ReadSession(ctx, current.Token); err != nil {
+			t.Errorf("current session ended: %v", err)
+		}
+	})
+
+	t.Run("only the user's own sessions", func(t *testing.T) {
+		a, _, _, _, stranger := setup(t)
+
+		if err := a.RevokeSessionByID(ctx, "user-1", stranger.ID); !errors.Is(err, port.ErrSessionNotFound) {
+			t.Fatalf("expected ErrSessionNotFound, got %v", err)
+		}
+		if _, err := a.ReadSession(ctx, stranger.Token); err != nil {
+			t.Errorf("another user's session ended: %v", err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// TokenIssuer mints and verifies the JWT access tokens handed out alongside
+// sessions.
+type TokenIssuer struct {
+	issuer string
+	ttl    time.Duration
+	keys   *KeyManager
+}
+
+// NewTokenIssuer signs tokens for issuer with the active key of keys. Tokens
+// live for ttl, or less if their session expires sooner.
+func NewTokenIssuer(issuer string, keys *KeyManager, ttl time.Duration) *TokenIssuer {
+	return &TokenIssuer{
+		issuer: issuer,
+		ttl:    ttl,
+		keys:   keys,
+	}
+}
+
+// Issue signs access token claims, filling in the issuer, issue time and
+// token ID. An unset expiry defaults to the issuer's TTL.
+func (t *TokenIssuer) Issue(ctx context.Context, claims domain.TokenClaims) (string, error) {
+	return t.sign(ctx, accessTokenType, claims)
+}
+
+// IssueIDToken signs OpenID Connect ID token claims like Issue does.
+func (t *TokenIssuer) IssueIDToken(ctx context.Context, claims domain.TokenClaims) (string, error) {
+	return t.sign(ctx, idTokenType, claims)
+}
+
+func (t *TokenIssuer) sign(ctx context.Context, tokenType string, claims domain.TokenClaims) (string, error) {
+	key, err := t.keys.SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	claims.Issuer = t.issuer
+	claims.ID = generateUniqueID()
+	claims.IssuedAt = now.Unix()
+	if claims.ExpiresAt == 0 {
+		claims.ExpiresAt = now.Add(t.ttl).Unix()
+	}
+
+	return signJWT(ed25519.PrivateKey(key.PrivateKey), key.ID, tokenType, claims)
+}
+
+// Verify checks the signature, issuer and expiry of an access token.
+func (t *TokenIssuer) Verify(ctx context.Context, token string) (*domain.TokenClaims, error) {
+	published, err := t.keys.PublishedKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &domain.TokenClaims{}
+	err = parseJWT(token, accessTokenType, func(keyID string) (ed25519.PublicKey, bool) {
+		for _, key := range published {
+			if key.ID == keyID {
+				return ed25519.PublicKey(key.PublicKey), true
+			}
+		}
+		return nil, false
+	}, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Issuer != t.issuer || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, port.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// KeySet returns the public keys tokens may be signed with.
+func (t *TokenIssuer) KeySet(ctx context.Context) (*domain.JSONWebKeySet, error) {
+	published, err := t.keys.PublishedKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &domain.JSONWebKeySet{Keys: make([]domain.JSONWebKey, 0, len(published))}
+	for _, key := range published {
+		set.Keys = append(set.Keys, publicJWK(ed25519.PublicKey(key.PublicKey), key.ID))
+	}
+	return set, nil
+}
+
+// issueAccessToken mints the JWT for a new session. It never outlives the
+// session, so a denylist entry kept for the session's remaining lifetime
+// covers the token too.
+func (a *authService) issueAccessToken(ctx context.Context, session *domain.Session) (string, error) {
+	user, err := a.authRepo.ReadUserByID(ctx, session.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(a.tokens.ttl)
+	if session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt
+	}
+
+	return a.tokens.Issue(ctx, domain.TokenClaims{
+		Subject:   session.UserID,
+		SessionID: session.ID,
+		Roles:     user.Roles,
+		ExpiresAt: expiresAt.Unix(),
+	})
+}
+
+// VerifyAccessToken checks an access token JWT and that its session has not
+// been revoked.
+func (a *authService) VerifyAccessToken(ctx context.Context, token string) (*domain.TokenClaims, error) {
+	if a.tokens == nil {
+		return nil, port.ErrInvalidToken
+	}
+
+	claims, err := a.tokens.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.SessionID != "" {
+		revoked, err := a.authRepo.IsSessionRevoked(ctx, claims.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("revocation check failed: %w", err)
+		}
+		if revoked {
+			return nil, port.ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
+
+// PublicKeys returns the JWKS access tokens can be verified against.
+func (a *authService) PublicKeys(ctx context.Context) (*domain.JSONWebKeySet, error) {
+	if a.tokens == nil {
+		return &domain.JSONWebKeySet{Keys: []domain.JSONWebKey{}}, nil
+	}
+
+	keys, err := a.tokens.KeySet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("key set retrieval failed: %w", err)
+	}
+	return keys, nil
+}
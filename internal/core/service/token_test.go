@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// testKeyStore is an in-memory port.KeyStore.
+type testKeyStore struct {
+	keys map[string]domain.SigningKey
+	// lock is the token of the rotation lock holder, if any.
+	lock string
+}
+
+func newTestKeyStore() *testKeyStore {
+	return &testKeyStore{keys: make(map[string]domain.SigningKey)}
+}
+
+func (s *testKeyStore) SaveKey(ctx context.Context, key domain.SigningKey) error {
+	s.keys[key.ID] = key
+	return nil
+}
+
+func (s *testKeyStore) ListKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	keys := make([]domain.SigningKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *testKeyStore) DeleteKey(ctx context.Context, kid string) error {
+	delete(s.keys, kid)
+	return nil
+}
+
+func (s *testKeyStore) LockRotation(ctx context.Context, ttl time.Duration) (string, bool, error) {
+	if s.lock != "" {
+		return "", false, nil
+	}
+	s.lock = "test-lock"
+	return s.lock, true, nil
+}
+
+func (s *testKeyStore) UnlockRotation(ctx context.Context, token string) error {
+	if s.lock == token {
+		s.lock = ""
+	}
+	return nil
+}
+
+func newTestIssuer(t *testing.T) *TokenIssuer {
+	t.Helper()
+
+	keys := NewKeyManager(newTestKeyStore(), DefaultKeyRotationPolicy())
+	return NewTokenIssuer("https://auth.test", keys, time.Minute)
+}
+
+func TestTokenIssuer(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t)
+
+	t.Run("round trip", func(t *testing.T) {
+		token, err := issuer.Issue(ctx, domain.TokenClaims{Subject: "user-1", SessionID: "session-1"})
+		if err != nil {
+			t.Fatalf("issuing token: %v", err)
+		}
+
+		claims, err := issuer.Verify(ctx, token)
+		if err != nil {
+			t.Fatalf("verifying token: %v", err)
+		}
+		if claims.Subject != "user-1" || claims.SessionID != "session-1" || claims.Issuer != "https://auth.test" {
+			t.Fatalf("unexpected claims: %+v", claims)
+		}
+	})
+
+	t.Run("signature of another token", func(t *testing.T) {
+		token, _ := issuer.Issue(ctx, domain.TokenClaims{Subject: "user-1"})
+		other, _ := issuer.Issue(ctx, domain.TokenClaims{Subject: "user-2"})
+
+		forged := token[:strings.LastIndex(token, ".")] + other[strings.LastIndex(other, "."):]
+		if _, err := issuer.Verify(ctx, forged); !errors.Is(err, port.ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("signed by another key", func(t *testing.T) {
+		token, _ := newTestIssuer(t).Issue(ctx, domain.TokenClaims{Subject: "user-1"})
+		if _, err := issuer.Verify(ctx, token); !errors.Is(err, port.ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		token, _ := issuer.Issue(ctx, domain.TokenClaims{Subject: "user-1"})
+
+		key, err := issuer.keys.SigningKey(ctx)
+		if err != nil {
+			t.Fatalf("reading signing key: %v", err)
+		}
+		if err := issuer.keys.Revoke(ctx, key.ID); err != nil {
+			t.Fatalf("revoking key: %v", err)
+		}
+
+		if _, err := issuer.Verify(ctx, token); !errors.Is(err, port.ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+
+		replacement, err := issuer.keys.SigningKey(ctx)
+		if err != nil {
+			t.Fatalf("reading replacement key: %v", err)
+		}
+		if replacement.ID == key.ID {
+			t.Fatal("revoked key is still signing")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token, _ := issuer.Issue(ctx, domain.TokenClaims{
+			Subject:   "user-1",
+			ExpiresAt: time.Now().Add(-time.Second).Unix(),
+		})
+		if _, err := issuer.Verify(ctx, token); !errors.Is(err, port.ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+}
+
+func TestKeyManagerPrePublishesSuccessor(t *testing.T) {
+	ctx := context.Background()
+	store := newTestKeyStore()
+	keys := NewKeyManager(store, KeyRotationPolicy{
+		RotationPeriod: time.Hour,
+		PrePublish:     2 * time.Hour,
+		RetiredGrace:   time.Minute,
+	})
+
+	if err := keys.Maintain(ctx); err != nil {
+		t.Fatalf("maintaining keys: %v", err)
+	}
+
+	published, err := keys.PublishedKeys(ctx)
+	if err != nil {
+		t.Fatalf("reading published keys: %v", err)
+	}
+	if len(published) != 2 {
+		t.Fatalf("expected the active key and its successor, got %d keys", len(published))
+	}
+
+	active, err := keys.SigningKey(ctx)
+	if err != nil {
+		t.Fatalf("reading signing key: %v", err)
+	}
+	for _, key := range published {
+		if key.ID != active.ID && !key.ActivatesAt.Equal(active.RetiresAt) {
+			t.Fatalf("successor activates at %s, want %s", key.ActivatesAt, active.RetiresAt)
+		}
+	}
+}
+
+func TestKeyManagerWaitsForRotationLock(t *testing.T) {
+	ctx := context.Background()
+
+	wait, poll := rotationLockWait, rotationLockPoll
+	rotationLockWait, rotationLockPoll = 50*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { rotationLockWait, rotationLockPoll = wait, poll })
+
+	store := newTestKeyStore()
+	store.lock = "other-process"
+	keys := NewKeyManager(store, DefaultKeyRotationPolicy())
+
+	if err := keys.Maintain(ctx); !errors.Is(err, port.ErrKeyStoreBusy) {
+		t.Fatalf("expected ErrKeyStoreBusy while another process rotates, got %v", err)
+	}
+	if len(store.keys) != 0 {
+		t.Fatalf("expected no keys created without the lock, got %d", len(store.keys))
+	}
+
+	store.lock = ""
+	if err := keys.Maintain(ctx); err != nil {
+		t.Fatalf("maintaining keys: %v", err)
+	}
+	if len(store.keys) == 0 {
+		t.Fatal("expected a key once the lock was free")
+	}
+	if store.lock != "" {
+		t.Fatalf("expected the lock released after rotating, held by %q", store.lock)
+	}
+
+	active, err := keys.SigningKey(ctx)
+	if err != nil {
+		t.Fatalf("reading signing key: %v", err)
+	}
+	if err := keys.Revoke(ctx, active.ID); err != nil {
+		t.Fatalf("revoking under the lock: %v", err)
+	}
+	if store.lock != "" {
+		t.Fatalf("expected the lock released after revoking, held by %q", store.lock)
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+// ImportUsers creates accounts for users of other systems with the password
+// hashes they had there. Hashes in formats no hasher understands, malformed
+// or beyond the cost limits, are rejected rather than locking their users
+// out.
+func (a *authService) ImportUsers(ctx context.Context, users []domain.ImportedUser, dryRun bool) (*domain.UserImport, error) {
+	report := &domain.UserImport{}
+	seen := make(map[string]bool, len(users))
+
+	for i, imported := range users {
+		reject := func(reason string) {
+			report.Rejected = append(report.Rejected, domain.ImportRejection{Index: i, Phonenumber: imported.Phonenumber, Reason: reason})
+		}
+
+		phonenumber, err := a.phones.Normalize(imported.Phonenumber)
+		if err != nil {
+			reject("invalid phone number")
+			continue
+		}
+
+		switch {
+		case imported.PasswordHash == "" && !a.loginMode.allowsOTP():
+			reject("no password hash, and code login is disabled")
+			continue
+		case imported.PasswordHash != "" && !a.hasher.recognizes(imported.PasswordHash):
+			reject("unrecognized or unsupported password hash")
+			continue
+		case seen[phonenumber]:
+			reject("duplicate phone number in the import")
+			continue
+		}
+		seen[phonenumber] = true
+
+		found, err := a.authRepo.ReadUserByPhone(ctx, phonenumber)
+		if err != nil {
+			return report, fmt.Errorf("user lookup failed: %w", err)
+		}
+		if found != nil {
+			report.Existing = append(report.Existing, domain.ImportRejection{Index: i, Phonenumber: phonenumber, Reason: "already registered as " + found.ID})
+			continue
+		}
+
+		user := domain.User{
+			ID:          generateUniqueID(),
+			Phonenumber: phonenumber,
+			Password:    imported.PasswordHash,
+			Roles:       imported.Roles,
+		}
+		if imported.PhoneVerified {
+			now := time.Now()
+			user.PhoneVerifiedAt = &now
+		}
+
+		if !dryRun {
+			if _, err := a.authRepo.SaveUser(ctx, user); err != nil {
+				return report, fmt.Errorf("user creation for %s failed: %w", phonenumber, err)
+			}
+		}
+		report.Imported = append(report.Imported, domain.ImportedAccount{Index: i, Phonenumber: phonenumber, UserID: user.ID})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+)
+
+func TestImportUsersChecksHashes(t *testing.T) {
+	ctx := context.Background()
+	a, repo := newTestService(t)
+
+	// Django's PBKDF2 key for 1000 iterations, as in TestPasswordHasherLegacyHashes
+	pbkdf2Key := "/3XZq6ExgG4tEgNl6AAiIne2pHiGJSsf7nhxZPzpzGs="
+	users := []domain.ImportedUser{
+		{Phonenumber: "+12025550100", PasswordHash: "pbkdf2_sha256$1000$saltsalt$" + pbkdf2Key},
+		{Phonenumber: "+12025550101", PasswordHash: "pbkdf2_sha256$lots$saltsalt$" + pbkdf2Key},
+		{Phonenumber: "+12025550102", PasswordHash: "pbkdf2_sha256$100000000$saltsalt$" + pbkdf2Key},
+		{Phonenumber: "+12025550103", PasswordHash: "pbkdf2_sha256$1000$saltsalt$" + strings.Repeat("AAAA", 100)},
+		{Phonenumber: "+12025550104", PasswordHash: "$2a$31$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+		{Phonenumber: "+12025550105", PasswordHash: "scrypt$1048576$salt$8$1$AAAA"},
+		{Phonenumber: "+12025550106", PasswordHash: "$scrypt$ln=10,r=8$c2FsdA$AAAA"},
+	}
+
+	report, err := a.ImportUsers(ctx, users, false)
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	if len(report.Imported) != 1 || report.Imported[0].Index != 0 {
+		t.Errorf("imported %+v, want only the first user", report.Imported)
+	}
+	if len(report.Rejected) != len(users)-1 {
+		t.Errorf("rejected %+v, want every malformed or costly hash", report.Rejected)
+	}
+	if len(repo.users) != 1 {
+		t.Errorf("saved %d users, want 1", len(repo.users))
+	}
+}
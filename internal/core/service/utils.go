@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -37,18 +38,22 @@ func generateToken() string {
 
 }
 
-// CreateSession generates a new session for a user.
-func generateSession(userID string, durationHours int) (*domain.Session, error) {
-	if durationHours <= 0 {
+// generateSession builds a new session for a user who signed in at
+// signedInAt, whose expiry follows the given policy.
+func generateSession(userID string, signedInAt time.Time, policy SessionPolicy) (*domain.Session, error) {
+	if policy.Validate() != nil {
 		return nil, ErrInvalidSessionDuration
 	}
 
+	now := time.Now()
+
 	return &domain.Session{
-		ID:        generateUniqueID(),
-		Token:     generateToken(),
-		UserID:    userID,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(time.Duration(durationHours) * time.Hour),
+		ID:         generateUniqueID(),
+		Token:      generateToken(),
+		UserID:     userID,
+		CreatedAt:  now,
+		SignedInAt: signedInAt,
+		ExpiresAt:  policy.expiry(signedInAt, now),
 	}, nil
 }
 
@@ -60,8 +65,10 @@ type Argon2Params struct {
 	KeyLength   uint32 // Derived key length in bytes
 }
 
-func defaultArgon2Params() *Argon2Params {
-	return &Argon2Params{
+// DefaultArgon2Params are the Argon2id parameters new password hashes use
+// unless configured otherwise.
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{
 		Memory:      64 * 1024, // 64 MB
 		Iterations:  1,
 		Parallelism: 4,
@@ -70,15 +77,41 @@ func defaultArgon2Params() *Argon2Params {
 	}
 }
 
+// Bounds on the cost of Argon2id hashes verify accepts, so an imported
+// hash cannot exhaust memory or tie up the server on every sign in.
+const (
+	maxArgon2Memory      = 1 << 20 // KiB
+	maxArgon2Iterations  = 64
+	maxArgon2Parallelism = 64
+	minArgon2KeyLength   = 16
+	maxArgon2KeyLength   = 128
+)
+
+// checkCost reports whether verify accepts hashes made with the parameters.
+func (p Argon2Params) checkCost() error {
+	if p.Memory > maxArgon2Memory || p.Iterations > maxArgon2Iterations ||
+		p.Parallelism > maxArgon2Parallelism || p.KeyLength > maxArgon2KeyLength {
+		return errUnsupportedHashCost
+	}
+	if p.KeyLength < minArgon2KeyLength {
+		return errors.New("argon2 key too short")
+	}
+	return nil
+}
+
 // Helper functions
-func generateFromPassword(password string, params *Argon2Params) (string, error) {
+
+// generateFromPassword hashes password with Argon2id. With a pepper, the
+// password is first keyed with it and the pepper's ID is recorded in the
+// hash as the keyid parameter.
+func generateFromPassword(password string, params *Argon2Params, pepper *Pepper) (string, error) {
 	salt, err := generateRandomBytes(params.SaltLength)
 	if err != nil {
 		return "", err
 	}
 
 	hash := argon2.IDKey(
-		[]byte(password),
+		pepper.apply(password),
 		salt,
 		params.Iterations,
 		params.Memory,
@@ -90,13 +123,19 @@ func generateFromPassword(password string, params *Argon2Params) (string, error)
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
 
-	// Format: argon2id$v=19$m=65536,t=1,p=4$salt$hash
+	keyID := ""
+	if pepper != nil {
+		keyID = ",keyid=" + pepper.ID
+	}
+
+	// Format: argon2id$v=19$m=65536,t=1,p=4[,keyid=id]$salt$hash
 	encoded := fmt.Sprintf(
-		"argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		"argon2id$v=%d$m=%d,t=%d,p=%d%s$%s$%s",
 		argon2.Version,
 		params.Memory,
 		params.Iterations,
 		params.Parallelism,
+		keyID,
 		b64Salt,
 		b64Hash,
 	)
@@ -104,56 +143,111 @@ func generateFromPassword(password string, params *Argon2Params) (string, error)
 	return encoded, nil
 }
 
-func comparePasswordAndHash(password, encodedHash string) (bool, error) {
-	// Parse encoded hash
-	parts := strings.Split(encodedHash, "$")
-	if len(parts) != 6 {
-		return false, errors.New("invalid hash format")
+// comparePasswordAndHash checks password against a hash made by
+// generateFromPassword, keying it with the pepper named in the hash.
+func comparePasswordAndHash(password, encodedHash string, peppers []Pepper) (bool, error) {
+	decoded, err := decodeArgon2Hash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	var pepper *Pepper
+	if decoded.keyID != "" {
+		if pepper = findPepper(peppers, decoded.keyID); pepper == nil {
+			return false, fmt.Errorf("hash uses unknown pepper %q", decoded.keyID)
+		}
+	}
+
+	// Derive key with same parameters
+	comparisonHash := argon2.IDKey(
+		pepper.apply(password),
+		decoded.salt,
+		decoded.params.Iterations,
+		decoded.params.Memory,
+		decoded.params.Parallelism,
+		decoded.params.KeyLength,
+	)
+
+	// Constant time comparison
+	if subtle.ConstantTimeCompare(comparisonHash, decoded.key) == 1 {
+		return true, nil
+	}
+	return false, nil
+}
+
+// argon2Hash is an encoded Argon2id hash taken apart.
+type argon2Hash struct {
+	params Argon2Params
+	keyID  string
+	salt   []byte
+	key    []byte
+}
+
+// decodeArgon2Hash reads a hash made by generateFromPassword.
+func decodeArgon2Hash(encodedHash string) (*argon2Hash, error) {
+	// Parse encoded hash, written by generateFromPassword without the
+	// leading "$" of the PHC string format, which is accepted too
+	parts := strings.Split(strings.TrimPrefix(encodedHash, "$"), "$")
+	if len(parts) != 5 || parts[0] != "argon2id" {
+		return nil, errors.New("invalid hash format")
 	}
 
 	var version int
-	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	_, err := fmt.Sscanf(parts[1], "v=%d", &version)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	if version != argon2.Version {
-		return false, errors.New("incompatible argon2 version")
+		return nil, errors.New("incompatible argon2 version")
 	}
 
-	params := &Argon2Params{}
-	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
-	if err != nil {
-		return false, err
+	decoded := &argon2Hash{}
+	for _, param := range strings.Split(parts[2], ",") {
+		name, value, _ := strings.Cut(param, "=")
+		var n uint64
+		switch name {
+		case "m":
+			n, err = strconv.ParseUint(value, 10, 32)
+			decoded.params.Memory = uint32(n)
+		case "t":
+			n, err = strconv.ParseUint(value, 10, 32)
+			decoded.params.Iterations = uint32(n)
+		case "p":
+			n, err = strconv.ParseUint(value, 10, 8)
+			decoded.params.Parallelism = uint8(n)
+		case "keyid":
+			if value == "" {
+				err = errors.New("empty argon2 keyid")
+			}
+			decoded.keyID = value
+		default:
+			err = fmt.Errorf("unknown argon2 parameter %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if decoded.params.Memory == 0 || decoded.params.Iterations == 0 || decoded.params.Parallelism == 0 {
+		return nil, errors.New("invalid hash format")
 	}
 
-	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	decoded.salt, err = base64.RawStdEncoding.DecodeString(parts[3])
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	storedHash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	decoded.key, err = base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	params.SaltLength = uint32(len(salt))
-	params.KeyLength = uint32(len(storedHash))
-
-	// Derive key with same parameters
-	comparisonHash := argon2.IDKey(
-		[]byte(password),
-		salt,
-		params.Iterations,
-		params.Memory,
-		params.Parallelism,
-		params.KeyLength,
-	)
-
-	// Constant time comparison
-	if subtle.ConstantTimeCompare(comparisonHash, storedHash) == 1 {
-		return true, nil
+	decoded.params.SaltLength = uint32(len(decoded.salt))
+	decoded.params.KeyLength = uint32(len(decoded.key))
+	if err := decoded.params.checkCost(); err != nil {
+		return nil, err
 	}
-	return false, nil
+
+	return decoded, nil
 }
 
 func generateRandomBytes(n uint32) ([]byte, error) {
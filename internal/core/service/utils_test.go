@@ -0,0 +1,41 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComparePasswordAndHash(t *testing.T) {
+	params := &Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	encodedHash, err := generateFromPassword("correct-horse-battery", params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, hash := range []string{encodedHash, "$" + encodedHash} {
+		if match, err := comparePasswordAndHash("correct-horse-battery", hash, nil); err != nil || !match {
+			t.Errorf("comparePasswordAndHash(%q) = %v, %v, want a match", hash, match, err)
+		}
+		if match, err := comparePasswordAndHash("wrong-horse-battery", hash, nil); err != nil || match {
+			t.Errorf("comparePasswordAndHash with a wrong password = %v, %v, want no match", match, err)
+		}
+	}
+
+	if _, err := comparePasswordAndHash("correct-horse-battery", "bcrypt$v=19$m=64,t=1,p=1$c2FsdA$a2V5", nil); err == nil {
+		t.Error("comparePasswordAndHash accepted a hash of another algorithm")
+	}
+
+	// Keys are 16 bytes, base64 "MDEyMzQ1Njc4OWFiY2RlZg", unless testing the length
+	for name, hash := range map[string]string{
+		"memory":      "argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHQ$MDEyMzQ1Njc4OWFiY2RlZg",
+		"iterations":  "argon2id$v=19$m=64,t=100000,p=1$c2FsdHNhbHQ$MDEyMzQ1Njc4OWFiY2RlZg",
+		"parallelism": "argon2id$v=19$m=2048,t=1,p=255$c2FsdHNhbHQ$MDEyMzQ1Njc4OWFiY2RlZg",
+		"long key":    "argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$" + strings.Repeat("A", 200),
+		"short key":   "argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"empty keyid": "argon2id$v=19$m=64,t=1,p=1,keyid=$c2FsdHNhbHQ$MDEyMzQ1Njc4OWFiY2RlZg",
+	} {
+		if _, err := comparePasswordAndHash("correct-horse-battery", hash, nil); err == nil {
+			t.Errorf("%s: comparePasswordAndHash accepted a hash out of bounds", name)
+		}
+	}
+}
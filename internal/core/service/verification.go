@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mar-cial/space-auth/internal/core/domain"
+	"github.com/mar-cial/space-auth/internal/core/port"
+)
+
+// StartPhoneVerification texts the user a code proving they own their phone
+// number.
+func (a *authService) StartPhoneVerification(ctx context.Context, userid string) (time.Time, error) {
+	user, err := a.ReadUserById(ctx, userid)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if user.PhoneVerifiedAt != nil {
+		return time.Time{}, port.ErrPhoneAlreadyVerified
+	}
+
+	return a.issueCode(ctx, domain.OneTimeCode{
+		Purpose:     domain.CodePurposeVerifyPhone,
+		Subject:     user.ID,
+		UserID:      user.ID,
+		Phonenumber: user.Phonenumber,
+	}, func(code string) string {
+		return fmt.Sprintf("%s is your verification code. It expires in %d minutes.", code, int(a.codePolicy.TTL.Minutes()))
+	})
+}
+
+// ConfirmPhoneVerification marks the user's phone number verified when code
+// is the one texted to it.
+func (a *authService) ConfirmPhoneVerification(ctx context.Context, userid string, code string) error {
+	record, err := a.redeemCode(ctx, domain.CodePurposeVerifyPhone, userid, code)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.ReadUserById(ctx, userid)
+	if err != nil {
+		return err
+	}
+
+	// The number changed since the code was sent
+	if user.Phonenumber != record.Phonenumber {
+		return port.ErrInvalidCode
+	}
+
+	now := time.Now()
+	user.PhoneVerifiedAt = &now
+	if _, err := a.authRepo.UpdateUser(ctx, *user); err != nil {
+		return fmt.Errorf("user update failed: %w", err)
+	}
+
+	return nil
+}